@@ -3,15 +3,25 @@ package auth
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
 	"os"
 	"path/filepath"
 	"time"
 )
 
 const (
-	DefaultBaseURL    = "https://alpha.lollipopai.com"
-	OAuthRedirectPort = 9876
-	OAuthRedirectURI  = "http://127.0.0.1:9876/callback"
+	DefaultBaseURL = "https://alpha.lollipopai.com"
+
+	// OAuthLoopbackRedirectURI is the redirect URI registered for new clients.
+	// Per RFC 8252 §7.3 the authorization server ignores the port when matching
+	// loopback redirects, so the callback server can bind any free port.
+	OAuthLoopbackRedirectURI = "http://127.0.0.1/callback"
+
+	// legacyOAuthRedirectURI is the fixed redirect URI that clients registered
+	// before ephemeral ports were supported (no oauth_redirect_uri stored).
+	legacyOAuthRedirectURI = "http://127.0.0.1:9876/callback"
 )
 
 var (
@@ -37,37 +47,118 @@ type Credentials struct {
 	OAuthRefreshToken string `json:"oauth_refresh_token,omitempty"`
 	OAuthExpiresAt    int64  `json:"oauth_expires_at,omitempty"`
 	OAuthClientID     string `json:"oauth_client_id,omitempty"`
+	OAuthRedirectURI  string `json:"oauth_redirect_uri,omitempty"`
+	OAuthTokenType    string `json:"oauth_token_type,omitempty"`
+
+	// Registration management (RFC 7592) for the dynamically registered client.
+	OAuthRegistrationToken string `json:"oauth_registration_access_token,omitempty"`
+	OAuthRegistrationURI   string `json:"oauth_registration_client_uri,omitempty"`
+
+	// DPoPPrivateKey is the profile's DPoP key (base64 PKCS#8), set by
+	// chp login --dpop. Tokens issued with it are bound to it.
+	DPoPPrivateKey string `json:"dpop_private_key,omitempty"`
+
+	// OAuthServer caches the discovered authorization server metadata.
+	OAuthServer *OAuthConfig `json:"oauth_server,omitempty"`
+
+	// profile is the profile the credentials were loaded from or saved to.
+	profile       string
+	helperQueried bool
 }
 
-// LoadCredentials reads credentials from disk. Returns zero-value on missing/corrupt file.
+// credentialsFile is the on-disk layout of credentials.json. The default
+// profile lives at the top level so files written by the Python CLI load
+// unchanged; named profiles are nested under "profiles".
+type credentialsFile struct {
+	Credentials
+	CurrentProfile string                  `json:"current_profile,omitempty"`
+	Profiles       map[string]*Credentials `json:"profiles,omitempty"`
+}
+
+// LoadCredentials reads credentials for the active profile from disk.
+// Returns zero-value on missing/corrupt file.
 func LoadCredentials() *Credentials {
-	data, err := os.ReadFile(CredentialsFile)
+	return LoadProfile(ActiveProfile())
+}
+
+// SaveCredentials writes credentials for the active profile to disk with 0600 permissions.
+func SaveCredentials(creds *Credentials) error {
+	return SaveProfile(ActiveProfile(), creds)
+}
+
+// loadCredentialsFile reads the credentials document from the active store.
+// A missing document is not an error; an unreadable or corrupt one is.
+func loadCredentialsFile() (*credentialsFile, error) {
+	data, err := backend.Read()
+	if errors.Is(err, os.ErrNotExist) {
+		return &credentialsFile{}, nil
+	}
 	if err != nil {
-		return &Credentials{}
+		return nil, fmt.Errorf("could not read credentials from %s store: %w", backend.Name(), err)
 	}
-	var creds Credentials
-	if err := json.Unmarshal(data, &creds); err != nil {
-		return &Credentials{}
+	var file credentialsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("credentials in %s store are corrupt: %w", backend.Name(), err)
 	}
-	return &creds
+	return &file, nil
 }
 
-// SaveCredentials writes credentials to disk with 0600 permissions.
-func SaveCredentials(creds *Credentials) error {
-	if err := os.MkdirAll(ConfigDir, 0700); err != nil {
-		return err
+// readCredentialsFile is the lenient form of loadCredentialsFile used on read
+// paths: problems are reported as a warning and treated as logged out.
+func readCredentialsFile() *credentialsFile {
+	file, err := loadCredentialsFile()
+	if err != nil {
+		slog.Warn(fmt.Sprintf("%v. Treating as logged out.", err))
+		return &credentialsFile{}
 	}
-	data, err := json.MarshalIndent(creds, "", "  ")
+	return file
+}
+
+// writeCredentialsFile writes the credentials document to the active store.
+func writeCredentialsFile(file *credentialsFile) error {
+	data, err := json.MarshalIndent(file, "", "  ")
 	if err != nil {
 		return err
 	}
-	fd, err := os.OpenFile(CredentialsFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	return backend.Write(data)
+}
+
+// updateCredentialsFile applies fn to the credentials document under the
+// cross-process lock and writes the result back.
+func updateCredentialsFile(fn func(file *credentialsFile) error) error {
+	return withCredentialsLock(func() error {
+		return updateCredentialsFileLocked(fn)
+	})
+}
+
+// updateCredentialsFileLocked is updateCredentialsFile for callers already
+// holding the lock. An unreadable document is never overwritten, so a wrong
+// passphrase or a half-written file can't wipe out other profiles.
+func updateCredentialsFileLocked(fn func(file *credentialsFile) error) error {
+	file, err := loadCredentialsFile()
 	if err != nil {
+		return fmt.Errorf("%w; fix or remove it before saving", err)
+	}
+	if err := fn(file); err != nil {
 		return err
 	}
-	defer fd.Close()
-	_, err = fd.Write(data)
-	return err
+	if file.isEmpty() {
+		return backend.Delete()
+	}
+	return writeCredentialsFile(file)
+}
+
+func (f *credentialsFile) isEmpty() bool {
+	return f.Credentials == (Credentials{}) && len(f.Profiles) == 0 && f.CurrentProfile == ""
+}
+
+// Profile returns the profile the credentials were loaded from or last saved
+// to, or the active profile for credentials that were neither.
+func (c *Credentials) Profile() string {
+	if c.profile != "" {
+		return c.profile
+	}
+	return ActiveProfile()
 }
 
 // GetBaseURL returns the stored base URL or the default.
@@ -79,8 +170,19 @@ func (c *Credentials) GetBaseURL() string {
 }
 
 // GetToken returns the best available auth token.
-// It prefers OAuth, then falls back to JWT.
+// CHP_TOKEN overrides everything stored. Otherwise it prefers OAuth, then
+// falls back to JWT. When a credential helper is configured it is asked for
+// tokens first (once per Credentials value).
 func (c *Credentials) GetToken() (string, error) {
+	if token := EnvToken(); token != "" {
+		return token, nil
+	}
+	if CredentialHelper != "" && !c.helperQueried {
+		c.helperQueried = true
+		if err := c.loadFromHelper(); err != nil {
+			slog.Warn(err.Error())
+		}
+	}
 	if c.OAuthAccessToken != "" {
 		return c.OAuthAccessToken, nil
 	}
@@ -90,9 +192,50 @@ func (c *Credentials) GetToken() (string, error) {
 	return "", errors.New("not logged in. Run: chp login")
 }
 
+// ApplyTokenResponse copies tokens and expiry from a token endpoint response.
+// Fields missing from the response are left untouched.
+func (c *Credentials) ApplyTokenResponse(resp map[string]any) {
+	if at, ok := resp["access_token"].(string); ok {
+		c.OAuthAccessToken = at
+	}
+	if rt, ok := resp["refresh_token"].(string); ok {
+		c.OAuthRefreshToken = rt
+	}
+	if tt, ok := resp["token_type"].(string); ok {
+		c.OAuthTokenType = tt
+	}
+	if ei, ok := resp["expires_in"].(float64); ok {
+		c.OAuthExpiresAt = time.Now().Unix() + int64(ei)
+	}
+}
+
+// ClientAcceptsRedirect reports whether the stored OAuth client was registered
+// with a redirect URI that matches redirectURI.
+func (c *Credentials) ClientAcceptsRedirect(redirectURI string) bool {
+	if c.OAuthClientID == "" {
+		return false
+	}
+	switch c.OAuthRedirectURI {
+	case "":
+		return redirectURI == legacyOAuthRedirectURI
+	case OAuthLoopbackRedirectURI:
+		u, err := url.Parse(redirectURI)
+		return err == nil && u.Scheme == "http" && u.Hostname() == "127.0.0.1" && u.Path == "/callback"
+	default:
+		return redirectURI == c.OAuthRedirectURI
+	}
+}
+
+// CallbackRedirectURI returns the loopback redirect URI for a callback server port.
+func CallbackRedirectURI(port int) string {
+	return fmt.Sprintf("http://127.0.0.1:%d/callback", port)
+}
+
 // IsOAuthTokenExpiring returns true if the OAuth token is expired or within 60s of expiry.
+// A CHP_TOKEN override is never considered expiring: chp can't refresh it.
 func (c *Credentials) IsOAuthTokenExpiring() bool {
-	return c.OAuthAccessToken != "" &&
+	return EnvToken() == "" &&
+		c.OAuthAccessToken != "" &&
 		c.OAuthExpiresAt > 0 &&
 		time.Now().Unix() > c.OAuthExpiresAt-60
 }
@@ -14,14 +14,19 @@ func setupTestCreds(t *testing.T) (cleanup func()) {
 	t.Helper()
 	origDir := ConfigDir
 	origFile := CredentialsFile
+	origProfile := ProfileOverride
 
 	tmp := t.TempDir()
 	ConfigDir = tmp
 	CredentialsFile = filepath.Join(tmp, "credentials.json")
+	ProfileOverride = ""
+	t.Setenv(ProfileEnvVar, "")
+	t.Setenv(TokenEnvVar, "")
 
 	return func() {
 		ConfigDir = origDir
 		CredentialsFile = origFile
+		ProfileOverride = origProfile
 	}
 }
 
@@ -30,7 +35,7 @@ func TestLoadCredentials_Missing(t *testing.T) {
 	defer cleanup()
 
 	creds := LoadCredentials()
-	assert.Equal(t, &Credentials{}, creds)
+	assert.Equal(t, &Credentials{profile: DefaultProfile}, creds)
 }
 
 func TestLoadCredentials_Corrupt(t *testing.T) {
@@ -39,7 +44,7 @@ func TestLoadCredentials_Corrupt(t *testing.T) {
 
 	os.WriteFile(CredentialsFile, []byte("not json{{{"), 0600)
 	creds := LoadCredentials()
-	assert.Equal(t, &Credentials{}, creds)
+	assert.Equal(t, &Credentials{profile: DefaultProfile}, creds)
 }
 
 func TestSaveCredentials_FilePermissions(t *testing.T) {
@@ -122,6 +127,19 @@ func TestCredentials_GetToken_None(t *testing.T) {
 	assert.Contains(t, err.Error(), "not logged in")
 }
 
+func TestCredentials_GetToken_EnvOverride(t *testing.T) {
+	t.Setenv(TokenEnvVar, "env-tok\n")
+	creds := &Credentials{OAuthAccessToken: "oauth-tok", OAuthExpiresAt: 1}
+	tok, err := creds.GetToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "env-tok", tok, "CHP_TOKEN should override stored tokens")
+	assert.False(t, creds.IsOAuthTokenExpiring(), "stored expiry doesn't apply to CHP_TOKEN")
+
+	tok, err = (&Credentials{}).GetToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "env-tok", tok, "CHP_TOKEN works without stored credentials")
+}
+
 func TestCredentials_IsOAuthTokenExpiring(t *testing.T) {
 	assert.False(t, (&Credentials{}).IsOAuthTokenExpiring(), "no token")
 	assert.False(t, (&Credentials{OAuthAccessToken: "tok"}).IsOAuthTokenExpiring(), "no expiry set")
@@ -130,3 +148,27 @@ func TestCredentials_IsOAuthTokenExpiring(t *testing.T) {
 		OAuthExpiresAt:   1, // way in the past
 	}).IsOAuthTokenExpiring())
 }
+
+func TestCredentials_ApplyTokenResponse(t *testing.T) {
+	creds := &Credentials{OAuthRefreshToken: "keep-me"}
+	creds.ApplyTokenResponse(map[string]any{
+		"access_token": "new-access",
+		"expires_in":   float64(3600),
+	})
+	assert.Equal(t, "new-access", creds.OAuthAccessToken)
+	assert.Equal(t, "keep-me", creds.OAuthRefreshToken, "refresh token not rotated")
+	assert.Greater(t, creds.OAuthExpiresAt, int64(0))
+}
+
+func TestCredentials_ClientAcceptsRedirect(t *testing.T) {
+	assert.False(t, (&Credentials{}).ClientAcceptsRedirect(CallbackRedirectURI(54321)), "no client")
+
+	legacy := &Credentials{OAuthClientID: "legacy"}
+	assert.True(t, legacy.ClientAcceptsRedirect("http://127.0.0.1:9876/callback"))
+	assert.False(t, legacy.ClientAcceptsRedirect(CallbackRedirectURI(54321)))
+
+	loopback := &Credentials{OAuthClientID: "new", OAuthRedirectURI: OAuthLoopbackRedirectURI}
+	assert.True(t, loopback.ClientAcceptsRedirect(CallbackRedirectURI(54321)))
+	assert.True(t, loopback.ClientAcceptsRedirect(CallbackRedirectURI(9876)))
+	assert.False(t, loopback.ClientAcceptsRedirect("http://localhost:54321/callback"))
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/lollipopai/cli/internal/httpclient"
+)
+
+// DeviceCodeGrantType is the RFC 8628 grant type used when polling the token endpoint.
+const DeviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// defaultDevicePollInterval is used when the server omits "interval" (RFC 8628 §3.2).
+const defaultDevicePollInterval = 5 * time.Second
+
+// pollSleep waits between polls, returning early with ctx's error if it is
+// cancelled. It is swapped out in tests so polling doesn't block on real time.
+var pollSleep = func(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// DeviceAuthorization is the device authorization response (RFC 8628 §3.2).
+type DeviceAuthorization struct {
+	DeviceCode              string
+	UserCode                string
+	VerificationURI         string
+	VerificationURIComplete string
+	ExpiresIn               int
+	Interval                int
+}
+
+// RequestDeviceCode starts a device authorization grant.
+func RequestDeviceCode(ctx context.Context, client *httpclient.Client, endpoint, clientID, scope, resource string) (*DeviceAuthorization, error) {
+	params := url.Values{
+		"client_id": {clientID},
+	}
+	if scope != "" {
+		params.Set("scope", scope)
+	}
+	if resource != "" {
+		params.Set("resource", resource)
+	}
+	body, err := client.PostForm(ctx, endpoint, params, nil)
+	if err != nil {
+		return nil, fmt.Errorf("device authorization request failed: %w", classifyClientError(body, err))
+	}
+	var resp map[string]any
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("invalid device authorization response: %w", err)
+	}
+
+	da := &DeviceAuthorization{
+		DeviceCode:              stringOrDefault(resp, "device_code", ""),
+		UserCode:                stringOrDefault(resp, "user_code", ""),
+		VerificationURI:         stringOrDefault(resp, "verification_uri", ""),
+		VerificationURIComplete: stringOrDefault(resp, "verification_uri_complete", ""),
+	}
+	if da.DeviceCode == "" || da.UserCode == "" || da.VerificationURI == "" {
+		return nil, fmt.Errorf("device authorization response missing device_code, user_code or verification_uri")
+	}
+	if ei, ok := resp["expires_in"].(float64); ok {
+		da.ExpiresIn = int(ei)
+	}
+	if iv, ok := resp["interval"].(float64); ok {
+		da.Interval = int(iv)
+	}
+	return da, nil
+}
+
+// PollDeviceToken polls the token endpoint until the user approves or denies the
+// device authorization, or the device code expires. Handles authorization_pending
+// and slow_down as described in RFC 8628 §3.5. A non-nil DPoP key binds the
+// issued tokens to it, as in ExchangeCode.
+func PollDeviceToken(ctx context.Context, client *httpclient.Client, tokenEndpoint, clientID, resource string, da *DeviceAuthorization, dpop *DPoPKey) (map[string]any, error) {
+	interval := defaultDevicePollInterval
+	if da.Interval > 0 {
+		interval = time.Duration(da.Interval) * time.Second
+	}
+	var deadline time.Time
+	if da.ExpiresIn > 0 {
+		deadline = time.Now().Add(time.Duration(da.ExpiresIn) * time.Second)
+	}
+
+	params := url.Values{
+		"grant_type":  {DeviceCodeGrantType},
+		"device_code": {da.DeviceCode},
+		"client_id":   {clientID},
+	}
+	if resource != "" {
+		params.Set("resource", resource)
+	}
+
+	for {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return nil, fmt.Errorf("device code expired before authorization completed")
+		}
+		if err := pollSleep(ctx, interval); err != nil {
+			return nil, err
+		}
+
+		body, err := dpop.Do("POST", tokenEndpoint, "", func(headers map[string]string) ([]byte, error) {
+			return client.PostForm(ctx, tokenEndpoint, params, headers)
+		})
+		if err == nil {
+			var resp map[string]any
+			if err := json.Unmarshal(body, &resp); err != nil {
+				return nil, fmt.Errorf("invalid token response: %w", err)
+			}
+			return resp, nil
+		}
+
+		var errResp map[string]any
+		if json.Unmarshal(body, &errResp) != nil {
+			return nil, fmt.Errorf("token polling failed: %w", err)
+		}
+		switch stringOrDefault(errResp, "error", "") {
+		case "authorization_pending":
+			continue
+		case "slow_down":
+			interval += 5 * time.Second
+			continue
+		case "access_denied":
+			return nil, fmt.Errorf("authorization was denied")
+		case "expired_token":
+			return nil, fmt.Errorf("device code expired before authorization completed")
+		default:
+			return nil, fmt.Errorf("token polling failed: %w", classifyClientError(body, err))
+		}
+	}
+}
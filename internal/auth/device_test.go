@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lollipopai/cli/internal/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stubPollSleep(t *testing.T) *[]time.Duration {
+	t.Helper()
+	var slept []time.Duration
+	orig := pollSleep
+	pollSleep = func(_ context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+	t.Cleanup(func() { pollSleep = orig })
+	return &slept
+}
+
+func TestRequestDeviceCode(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		assert.Equal(t, "client-123", r.FormValue("client_id"))
+		assert.Equal(t, "read write", r.FormValue("scope"))
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"device_code":               "dev-code",
+			"user_code":                 "ABCD-EFGH",
+			"verification_uri":          "https://example.com/device",
+			"verification_uri_complete": "https://example.com/device?user_code=ABCD-EFGH",
+			"expires_in":                600,
+			"interval":                  3,
+		})
+	}))
+	defer srv.Close()
+
+	da, err := RequestDeviceCode(t.Context(), httpclient.New(), srv.URL, "client-123", "read write", "")
+	require.NoError(t, err)
+	assert.Equal(t, "dev-code", da.DeviceCode)
+	assert.Equal(t, "ABCD-EFGH", da.UserCode)
+	assert.Equal(t, "https://example.com/device", da.VerificationURI)
+	assert.Equal(t, "https://example.com/device?user_code=ABCD-EFGH", da.VerificationURIComplete)
+	assert.Equal(t, 600, da.ExpiresIn)
+	assert.Equal(t, 3, da.Interval)
+}
+
+func TestRequestDeviceCode_MissingFields(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"device_code": "dev-code"})
+	}))
+	defer srv.Close()
+
+	_, err := RequestDeviceCode(t.Context(), httpclient.New(), srv.URL, "client-123", "", "")
+	assert.Error(t, err)
+}
+
+func TestPollDeviceToken_PendingThenSlowDownThenSuccess(t *testing.T) {
+	slept := stubPollSleep(t)
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		assert.Equal(t, DeviceCodeGrantType, r.FormValue("grant_type"))
+		assert.Equal(t, "dev-code", r.FormValue("device_code"))
+		assert.Equal(t, "client-123", r.FormValue("client_id"))
+
+		calls++
+		switch calls {
+		case 1:
+			w.WriteHeader(400)
+			w.Write([]byte(`{"error":"authorization_pending"}`))
+		case 2:
+			w.WriteHeader(400)
+			w.Write([]byte(`{"error":"slow_down"}`))
+		default:
+			json.NewEncoder(w).Encode(map[string]any{
+				"access_token":  "device-access",
+				"refresh_token": "device-refresh",
+				"expires_in":    3600,
+			})
+		}
+	}))
+	defer srv.Close()
+
+	da := &DeviceAuthorization{DeviceCode: "dev-code", Interval: 2, ExpiresIn: 600}
+	resp, err := PollDeviceToken(t.Context(), httpclient.New(), srv.URL, "client-123", "", da, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "device-access", resp["access_token"])
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []time.Duration{2 * time.Second, 2 * time.Second, 7 * time.Second}, *slept)
+}
+
+func TestPollDeviceToken_DefaultInterval(t *testing.T) {
+	slept := stubPollSleep(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "tok"})
+	}))
+	defer srv.Close()
+
+	_, err := PollDeviceToken(t.Context(), httpclient.New(), srv.URL, "client-123", "", &DeviceAuthorization{DeviceCode: "dev-code"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []time.Duration{5 * time.Second}, *slept)
+}
+
+func TestPollDeviceToken_Denied(t *testing.T) {
+	stubPollSleep(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		w.Write([]byte(`{"error":"access_denied"}`))
+	}))
+	defer srv.Close()
+
+	_, err := PollDeviceToken(t.Context(), httpclient.New(), srv.URL, "client-123", "", &DeviceAuthorization{DeviceCode: "dev-code"}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "denied")
+}
+
+func TestPollDeviceToken_Expired(t *testing.T) {
+	stubPollSleep(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		w.Write([]byte(`{"error":"expired_token"}`))
+	}))
+	defer srv.Close()
+
+	_, err := PollDeviceToken(t.Context(), httpclient.New(), srv.URL, "client-123", "", &DeviceAuthorization{DeviceCode: "dev-code"}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "expired")
+}
+
+func TestPollDeviceToken_Cancelled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("no poll after cancellation")
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err := PollDeviceToken(ctx, httpclient.New(), srv.URL, "client-123", "", &DeviceAuthorization{DeviceCode: "dev-code", Interval: 60}, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
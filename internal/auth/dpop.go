@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lollipopai/cli/internal/httpclient"
+)
+
+// DPoPTokenType is the token_type of sender-constrained tokens (RFC 9449).
+const DPoPTokenType = "DPoP"
+
+// dpopAlg is the only proof signing algorithm chp uses.
+const dpopAlg = "ES256"
+
+// DPoPKey signs DPoP proofs (RFC 9449) with a profile's P-256 key. It
+// remembers the latest nonce each server handed out. Safe for concurrent use.
+type DPoPKey struct {
+	priv *ecdsa.PrivateKey
+
+	mu     sync.Mutex
+	nonces map[string]string // by origin
+}
+
+// dpopKeys caches parsed keys so nonces survive across Credentials values.
+var dpopKeys sync.Map // encoded key -> *DPoPKey
+
+// GenerateDPoPKey creates a new P-256 key, encoded for Credentials.DPoPPrivateKey.
+func GenerateDPoPKey() (string, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(der), nil
+}
+
+// ParseDPoPKey decodes a key produced by GenerateDPoPKey.
+func ParseDPoPKey(encoded string) (*DPoPKey, error) {
+	if k, ok := dpopKeys.Load(encoded); ok {
+		return k.(*DPoPKey), nil
+	}
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DPoP key: %w", err)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DPoP key: %w", err)
+	}
+	priv, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok || priv.Curve != elliptic.P256() {
+		return nil, errors.New("invalid DPoP key: not a P-256 EC key")
+	}
+	k, _ := dpopKeys.LoadOrStore(encoded, &DPoPKey{priv: priv, nonces: map[string]string{}})
+	return k.(*DPoPKey), nil
+}
+
+// publicJWK returns the public key as a JWK, with members in the
+// lexicographic order RFC 7638 requires for thumbprints.
+func (k *DPoPKey) publicJWK() map[string]string {
+	pub, err := k.priv.PublicKey.ECDH()
+	if err != nil {
+		panic(err) // P-256 keys always convert
+	}
+	raw := pub.Bytes() // 0x04 || X || Y
+	return map[string]string{
+		"crv": "P-256",
+		"kty": "EC",
+		"x":   base64.RawURLEncoding.EncodeToString(raw[1:33]),
+		"y":   base64.RawURLEncoding.EncodeToString(raw[33:65]),
+	}
+}
+
+// Thumbprint returns the RFC 7638 JWK SHA-256 thumbprint of the public key,
+// which servers record as the token's cnf.jkt.
+func (k *DPoPKey) Thumbprint() string {
+	jwk, _ := json.Marshal(k.publicJWK()) // map keys marshal sorted
+	sum := sha256.Sum256(jwk)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Proof returns a DPoP proof JWT for a request. accessToken is bound via the
+// ath claim when non-empty (resource requests); leave it empty for token
+// endpoint requests.
+func (k *DPoPKey) Proof(method, rawURL, accessToken string) (string, error) {
+	htu, origin, err := dpopTarget(rawURL)
+	if err != nil {
+		return "", err
+	}
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+
+	header := map[string]any{"typ": "dpop+jwt", "alg": dpopAlg, "jwk": k.publicJWK()}
+	claims := map[string]any{
+		"jti": base64.RawURLEncoding.EncodeToString(jti),
+		"htm": method,
+		"htu": htu,
+		"iat": time.Now().Unix(),
+	}
+	if nonce := k.nonce(origin); nonce != "" {
+		claims["nonce"] = nonce
+	}
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		claims["ath"] = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, k.priv, digest[:])
+	if err != nil {
+		return "", err
+	}
+	// JWS ES256 signatures are the fixed-width R || S, not ASN.1.
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// Do sends a request with a fresh proof in the DPoP header. If the server
+// rejects it asking for a nonce (use_dpop_nonce plus a DPoP-Nonce header),
+// the nonce is remembered and the request is retried once. A nil key sends
+// the request as is.
+func (k *DPoPKey) Do(method, rawURL, accessToken string, send func(headers map[string]string) ([]byte, error)) ([]byte, error) {
+	if k == nil {
+		return send(map[string]string{})
+	}
+	_, origin, err := dpopTarget(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	for attempt := 0; ; attempt++ {
+		proof, err := k.Proof(method, rawURL, accessToken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create DPoP proof: %w", err)
+		}
+		body, err := send(map[string]string{"DPoP": proof})
+
+		var apiErr *httpclient.APIError
+		if !errors.As(err, &apiErr) || apiErr.Header == nil {
+			return body, err
+		}
+		nonce := apiErr.Header.Get("DPoP-Nonce")
+		if nonce == "" {
+			return body, err
+		}
+		k.setNonce(origin, nonce)
+		if attempt > 0 || !isNonceChallenge(apiErr, body) {
+			return body, err
+		}
+	}
+}
+
+// isNonceChallenge reports whether an error response demands a DPoP nonce:
+// a use_dpop_nonce error from a token endpoint (400) or resource server (401).
+func isNonceChallenge(apiErr *httpclient.APIError, body []byte) bool {
+	switch apiErr.StatusCode {
+	case 400:
+		var resp map[string]any
+		return json.Unmarshal(body, &resp) == nil && resp["error"] == "use_dpop_nonce"
+	case 401:
+		return strings.Contains(apiErr.Header.Get("WWW-Authenticate"), "use_dpop_nonce")
+	}
+	return false
+}
+
+func (k *DPoPKey) nonce(origin string) string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.nonces[origin]
+}
+
+func (k *DPoPKey) setNonce(origin, nonce string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.nonces[origin] = nonce
+}
+
+// dpopTarget returns the htu claim for a URL (no query or fragment) and the
+// origin its nonce is scoped to.
+func dpopTarget(rawURL string) (htu, origin string, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid DPoP target URL: %w", err)
+	}
+	origin = strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Host)
+	return origin + u.EscapedPath(), origin, nil
+}
+
+// DPoP returns the profile's DPoP key, or nil if DPoP is not in use.
+func (c *Credentials) DPoP() (*DPoPKey, error) {
+	if c.DPoPPrivateKey == "" {
+		return nil, nil
+	}
+	return ParseDPoPKey(c.DPoPPrivateKey)
+}
+
+// UsesDPoP reports whether the stored access token is DPoP-bound, so
+// requests must carry "Authorization: DPoP" and a proof.
+func (c *Credentials) UsesDPoP() bool {
+	return c.DPoPPrivateKey != "" && strings.EqualFold(c.OAuthTokenType, DPoPTokenType)
+}
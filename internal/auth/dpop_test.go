@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lollipopai/cli/internal/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// verifyDPoPProof checks a proof's header and signature the way a server
+// would, and returns its claims.
+func verifyDPoPProof(t *testing.T, proof string) (header, claims map[string]any) {
+	t.Helper()
+	parts := strings.Split(proof, ".")
+	require.Len(t, parts, 3)
+
+	decode := func(s string) []byte {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		require.NoError(t, err)
+		return b
+	}
+	require.NoError(t, json.Unmarshal(decode(parts[0]), &header))
+	require.NoError(t, json.Unmarshal(decode(parts[1]), &claims))
+	assert.Equal(t, "dpop+jwt", header["typ"])
+	assert.Equal(t, "ES256", header["alg"])
+
+	jwk := header["jwk"].(map[string]any)
+	assert.Equal(t, "EC", jwk["kty"])
+	assert.Equal(t, "P-256", jwk["crv"])
+	assert.NotContains(t, jwk, "d", "proof must not leak the private key")
+	pub := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(decode(jwk["x"].(string))),
+		Y:     new(big.Int).SetBytes(decode(jwk["y"].(string))),
+	}
+	sig := decode(parts[2])
+	require.Len(t, sig, 64)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	assert.True(t, ecdsa.Verify(pub, digest[:], r, s), "proof signature must verify against its jwk")
+	return header, claims
+}
+
+func newTestDPoPKey(t *testing.T) (string, *DPoPKey) {
+	t.Helper()
+	encoded, err := GenerateDPoPKey()
+	require.NoError(t, err)
+	key, err := ParseDPoPKey(encoded)
+	require.NoError(t, err)
+	return encoded, key
+}
+
+func TestDPoPProof_Claims(t *testing.T) {
+	_, key := newTestDPoPKey(t)
+
+	proof, err := key.Proof("POST", "https://API.example.com/oauth/token?x=1#frag", "")
+	require.NoError(t, err)
+	_, claims := verifyDPoPProof(t, proof)
+	assert.Equal(t, "POST", claims["htm"])
+	assert.Equal(t, "https://api.example.com/oauth/token", claims["htu"], "htu drops query and fragment")
+	assert.NotEmpty(t, claims["jti"])
+	assert.InDelta(t, time.Now().Unix(), claims["iat"], 5)
+	assert.NotContains(t, claims, "ath", "token endpoint proofs carry no ath")
+	assert.NotContains(t, claims, "nonce")
+
+	proof, err = key.Proof("POST", "https://api.example.com/api/twirp/svc/Method", "the-token")
+	require.NoError(t, err)
+	_, claims = verifyDPoPProof(t, proof)
+	sum := sha256.Sum256([]byte("the-token"))
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(sum[:]), claims["ath"])
+
+	other, _ := key.Proof("POST", "https://api.example.com/api/twirp/svc/Method", "the-token")
+	_, otherClaims := verifyDPoPProof(t, other)
+	assert.NotEqual(t, claims["jti"], otherClaims["jti"], "jti must be unique per proof")
+}
+
+func TestDPoPKey_ParseRoundTripAndThumbprint(t *testing.T) {
+	encoded, key := newTestDPoPKey(t)
+	again, err := ParseDPoPKey(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, key.Thumbprint(), again.Thumbprint())
+	assert.Len(t, key.Thumbprint(), 43, "base64url SHA-256")
+
+	_, err = ParseDPoPKey("not-a-key")
+	assert.Error(t, err)
+}
+
+func TestExchangeCode_DPoPNonceRetry(t *testing.T) {
+	_, key := newTestDPoPKey(t)
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, claims := verifyDPoPProof(t, r.Header.Get("DPoP"))
+		assert.Equal(t, "http://"+r.Host+"/token", claims["htu"])
+		if claims["nonce"] != "server-nonce" {
+			w.Header().Set("DPoP-Nonce", "server-nonce")
+			w.WriteHeader(400)
+			w.Write([]byte(`{"error":"use_dpop_nonce"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "bound", "token_type": "DPoP"})
+	}))
+	defer srv.Close()
+
+	resp, err := ExchangeCode(t.Context(), httpclient.New(), srv.URL+"/token", "code", "verifier", "client", "http://127.0.0.1/callback", "", key)
+	require.NoError(t, err)
+	assert.Equal(t, "DPoP", resp["token_type"])
+	assert.Equal(t, int32(2), requests.Load())
+
+	// The nonce is remembered for the next request to the same server.
+	_, err = ExchangeCode(t.Context(), httpclient.New(), srv.URL+"/token", "code", "verifier", "client", "http://127.0.0.1/callback", "", key)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func TestDPoPDo_RetriesNonceOnlyOnce(t *testing.T) {
+	_, key := newTestDPoPKey(t)
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		w.Header().Set("DPoP-Nonce", "nonce-"+string(rune('0'+n)))
+		w.WriteHeader(400)
+		w.Write([]byte(`{"error":"use_dpop_nonce"}`))
+	}))
+	defer srv.Close()
+
+	client := httpclient.New()
+	_, err := key.Do("POST", srv.URL, "", func(headers map[string]string) ([]byte, error) {
+		return client.PostForm(t.Context(), srv.URL, url.Values{}, headers)
+	})
+	require.Error(t, err)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestDPoPDo_OtherErrorsNotRetried(t *testing.T) {
+	_, key := newTestDPoPKey(t)
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("DPoP-Nonce", "fresh-nonce")
+		w.WriteHeader(400)
+		w.Write([]byte(`{"error":"invalid_grant"}`))
+	}))
+	defer srv.Close()
+
+	client := httpclient.New()
+	_, err := key.Do("POST", srv.URL, "", func(headers map[string]string) ([]byte, error) {
+		return client.PostForm(t.Context(), srv.URL, url.Values{}, headers)
+	})
+	require.Error(t, err)
+	assert.Equal(t, int32(1), requests.Load(), "a rotated refresh token must not be replayed on invalid_grant")
+}
+
+func TestRefreshOAuthToken_SendsDPoPProof(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+
+	encoded, _ := newTestDPoPKey(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/oauth/token" {
+			w.WriteHeader(404)
+			return
+		}
+		_, claims := verifyDPoPProof(t, r.Header.Get("DPoP"))
+		assert.Equal(t, "POST", claims["htm"])
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "new", "token_type": "DPoP", "expires_in": 3600})
+	}))
+	defer srv.Close()
+
+	creds := &Credentials{
+		BaseURL:           srv.URL,
+		OAuthAccessToken:  "old",
+		OAuthRefreshToken: "refresh",
+		OAuthClientID:     "client",
+		OAuthTokenType:    "DPoP",
+		DPoPPrivateKey:    encoded,
+	}
+	require.NoError(t, RefreshOAuthToken(t.Context(), httpclient.New(), creds))
+	assert.Equal(t, "new", creds.OAuthAccessToken)
+	assert.True(t, creds.UsesDPoP())
+	assert.Equal(t, encoded, LoadCredentials().DPoPPrivateKey, "key is persisted with the profile")
+}
@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CredentialHelper is the external command that owns tokens, set from the
+// credential-helper config setting. Like git credential helpers it is run
+// through the shell with an action argument (get, store or erase) and
+// exchanges key=value lines on stdin/stdout. When set, tokens are fetched
+// with get, pushed with store whenever credentials are saved (including after
+// a refresh), and never written to the credential store.
+var CredentialHelper string
+
+// helperAttrs is a set of key=value attributes exchanged with a helper.
+type helperAttrs map[string]string
+
+// runCredentialHelper invokes the helper with an action and attributes.
+func runCredentialHelper(action string, attrs helperAttrs) (helperAttrs, error) {
+	var stdin bytes.Buffer
+	for _, k := range sortedKeys(attrs) {
+		if attrs[k] != "" {
+			fmt.Fprintf(&stdin, "%s=%s\n", k, attrs[k])
+		}
+	}
+	stdin.WriteString("\n")
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", CredentialHelper+" "+action)
+	} else {
+		cmd = exec.Command("sh", "-c", CredentialHelper+" "+action)
+	}
+	cmd.Stdin = &stdin
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("credential helper %q %s failed: %w", CredentialHelper, action, err)
+	}
+
+	return parseAttrs(out), nil
+}
+
+// parseAttrs reads key=value lines up to the first blank line.
+func parseAttrs(data []byte) helperAttrs {
+	result := helperAttrs{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			break
+		}
+		if k, v, ok := strings.Cut(line, "="); ok {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// helperQuery identifies the account a helper is asked about.
+func helperQuery(profile, baseURL string) helperAttrs {
+	attrs := helperAttrs{"profile": profile}
+	if u, err := url.Parse(baseURL); err == nil {
+		attrs["protocol"] = u.Scheme
+		attrs["host"] = u.Host
+	}
+	return attrs
+}
+
+// loadFromHelper asks the helper for the tokens of c's profile and copies
+// any it returns into c.
+func (c *Credentials) loadFromHelper() error {
+	resp, err := runCredentialHelper("get", helperQuery(c.Profile(), c.GetBaseURL()))
+	if err != nil {
+		return err
+	}
+	c.applyAttrs(resp)
+	return nil
+}
+
+// applyAttrs copies the token attributes present in attrs into c.
+func (c *Credentials) applyAttrs(attrs helperAttrs) {
+	if v := attrs["access_token"]; v != "" {
+		c.OAuthAccessToken = v
+	}
+	if v := attrs["refresh_token"]; v != "" {
+		c.OAuthRefreshToken = v
+	}
+	if v := attrs["client_id"]; v != "" {
+		c.OAuthClientID = v
+	}
+	if v, err := strconv.ParseInt(attrs["expires_at"], 10, 64); err == nil {
+		c.OAuthExpiresAt = v
+	}
+}
+
+// storeInHelper pushes tokens for a profile to the helper.
+func storeInHelper(profile string, c *Credentials) error {
+	if c.OAuthAccessToken == "" && c.OAuthRefreshToken == "" {
+		return nil
+	}
+	attrs := helperQuery(profile, c.GetBaseURL())
+	attrs["access_token"] = c.OAuthAccessToken
+	attrs["refresh_token"] = c.OAuthRefreshToken
+	attrs["client_id"] = c.OAuthClientID
+	if c.OAuthExpiresAt > 0 {
+		attrs["expires_at"] = strconv.FormatInt(c.OAuthExpiresAt, 10)
+	}
+	_, err := runCredentialHelper("store", attrs)
+	return err
+}
+
+// withoutTokens returns a copy of c with secrets removed, for storing
+// alongside a credential helper.
+func (c *Credentials) withoutTokens() *Credentials {
+	stripped := *c
+	stripped.JWT = ""
+	stripped.OAuthAccessToken = ""
+	stripped.OAuthRefreshToken = ""
+	stripped.helperQueried = false
+	return &stripped
+}
+
+// stored returns a copy of c without in-memory state, as kept in the
+// credentials document.
+func (c *Credentials) stored() *Credentials {
+	stored := *c
+	stored.profile = ""
+	stored.helperQueried = false
+	return &stored
+}
+
+// eraseFromHelper asks the helper to forget a profile's tokens.
+func eraseFromHelper(profile string, c *Credentials) error {
+	_, err := runCredentialHelper("erase", helperQuery(profile, c.GetBaseURL()))
+	return err
+}
+
+func sortedKeys(m helperAttrs) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
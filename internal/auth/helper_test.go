@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/lollipopai/cli/internal/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useTestHelper installs a shell script as the credential helper. The script
+// records each invocation's action and stdin in log, and answers get with
+// getResponse.
+func useTestHelper(t *testing.T, getResponse string) (log string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("credential helper tests use sh")
+	}
+	dir := t.TempDir()
+	log = filepath.Join(dir, "helper.log")
+	script := filepath.Join(dir, "helper.sh")
+	body := "#!/bin/sh\n" +
+		"echo \"action=$1\" >> " + log + "\n" +
+		"cat >> " + log + "\n" +
+		"if [ \"$1\" = get ]; then printf '" + getResponse + "'; fi\n"
+	require.NoError(t, os.WriteFile(script, []byte(body), 0700))
+
+	orig := CredentialHelper
+	CredentialHelper = script
+	t.Cleanup(func() { CredentialHelper = orig })
+	return log
+}
+
+func readHelperLog(t *testing.T, log string) string {
+	t.Helper()
+	data, err := os.ReadFile(log)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestGetToken_FromHelper(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+	log := useTestHelper(t, `access_token=helper-tok\nrefresh_token=helper-refresh\nexpires_at=1700000000\nclient_id=helper-client\n`)
+
+	creds := &Credentials{BaseURL: "https://api.example.com"}
+	tok, err := creds.GetToken()
+	require.NoError(t, err)
+	assert.Equal(t, "helper-tok", tok)
+	assert.Equal(t, "helper-refresh", creds.OAuthRefreshToken)
+	assert.Equal(t, "helper-client", creds.OAuthClientID)
+	assert.Equal(t, int64(1700000000), creds.OAuthExpiresAt)
+
+	got := readHelperLog(t, log)
+	assert.Contains(t, got, "action=get\n")
+	assert.Contains(t, got, "host=api.example.com\n")
+	assert.Contains(t, got, "profile=default\n")
+	assert.Contains(t, got, "protocol=https\n")
+
+	// Helper is only asked once per Credentials value
+	_, err = creds.GetToken()
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(readHelperLog(t, log), "action=get"))
+}
+
+func TestGetToken_HelperEmptyFallsBackToStored(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+	useTestHelper(t, ``)
+
+	tok, err := (&Credentials{JWT: "stored-jwt"}).GetToken()
+	require.NoError(t, err)
+	assert.Equal(t, "stored-jwt", tok)
+}
+
+func TestSaveCredentials_HelperStoresTokensOffDisk(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+	log := useTestHelper(t, ``)
+
+	require.NoError(t, SaveCredentials(&Credentials{
+		BaseURL:           "https://api.example.com",
+		OAuthAccessToken:  "secret-access",
+		OAuthRefreshToken: "secret-refresh",
+		OAuthClientID:     "client-123",
+	}))
+
+	got := readHelperLog(t, log)
+	assert.Contains(t, got, "action=store\n")
+	assert.Contains(t, got, "access_token=secret-access\n")
+	assert.Contains(t, got, "refresh_token=secret-refresh\n")
+
+	data, err := os.ReadFile(CredentialsFile)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "secret-")
+	assert.Equal(t, "client-123", LoadCredentials().OAuthClientID)
+}
+
+func TestDeleteProfile_HelperErase(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+	log := useTestHelper(t, ``)
+
+	require.NoError(t, SaveCredentials(&Credentials{BaseURL: "https://api.example.com", OAuthAccessToken: "tok"}))
+	require.NoError(t, DeleteProfile(DefaultProfile))
+	assert.Contains(t, readHelperLog(t, log), "action=erase\n")
+}
+
+func TestRefreshOAuthToken_PushesRotatedTokensToHelper(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/.well-known/oauth-authorization-server" {
+			w.WriteHeader(404)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  "rotated-access",
+			"refresh_token": "rotated-refresh",
+			"expires_in":    3600,
+		})
+	}))
+	defer srv.Close()
+
+	log := useTestHelper(t, `access_token=old-access\nrefresh_token=old-refresh\nclient_id=client-123\n`)
+
+	creds := &Credentials{BaseURL: srv.URL}
+	_, err := creds.GetToken()
+	require.NoError(t, err)
+	require.NoError(t, RefreshOAuthToken(t.Context(), httpclient.New(), creds))
+
+	got := readHelperLog(t, log)
+	assert.Contains(t, got, "action=store\n")
+	assert.Contains(t, got, "refresh_token=rotated-refresh\n")
+}
+
+// useProfileHelper installs a credential helper that answers get with tokens
+// named after the profile asked about, and records invocations like
+// useTestHelper.
+func useProfileHelper(t *testing.T) (log string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("credential helper tests use sh")
+	}
+	dir := t.TempDir()
+	log = filepath.Join(dir, "helper.log")
+	script := filepath.Join(dir, "helper.sh")
+	body := "#!/bin/sh\n" +
+		"in=$(cat)\n" +
+		"printf 'action=%s\\n%s\\n' \"$1\" \"$in\" >> " + log + "\n" +
+		"profile=$(printf '%s\\n' \"$in\" | sed -n 's/^profile=//p')\n" +
+		"if [ \"$1\" = get ]; then printf 'access_token=%s-access\\nrefresh_token=%s-refresh\\nclient_id=client-123\\n' \"$profile\" \"$profile\"; fi\n"
+	require.NoError(t, os.WriteFile(script, []byte(body), 0700))
+
+	orig := CredentialHelper
+	CredentialHelper = script
+	t.Cleanup(func() { CredentialHelper = orig })
+	return log
+}
+
+func TestLoadProfile_HelperAskedAboutThatProfile(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+	useProfileHelper(t)
+	require.NoError(t, SaveProfile("work", &Credentials{BaseURL: "https://api.example.com"}))
+
+	tok, err := LoadProfile("work").GetToken()
+	require.NoError(t, err)
+	assert.Equal(t, "work-access", tok, "not the active profile's token")
+	tok, err = LoadCredentials().GetToken()
+	require.NoError(t, err)
+	assert.Equal(t, "default-access", tok)
+}
+
+func TestRefreshOAuthToken_SavesToLoadedProfile(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/oauth/token" {
+			w.WriteHeader(404)
+			return
+		}
+		assert.Equal(t, "work-refresh", r.FormValue("refresh_token"))
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  "rotated-access",
+			"refresh_token": "rotated-refresh",
+			"expires_in":    3600,
+		})
+	}))
+	defer srv.Close()
+
+	log := useProfileHelper(t)
+	require.NoError(t, SaveProfile("work", &Credentials{BaseURL: srv.URL}))
+
+	creds := LoadProfile("work")
+	_, err := creds.GetToken()
+	require.NoError(t, err)
+	require.NoError(t, RefreshOAuthToken(t.Context(), httpclient.New(), creds))
+
+	got := readHelperLog(t, log)
+	assert.Contains(t, got, "action=store\naccess_token=rotated-access\n")
+	assert.Contains(t, got, "profile=work\nprotocol=http\nrefresh_token=rotated-refresh\n")
+	assert.Equal(t, []string{DefaultProfile, "work"}, ListProfiles())
+	assert.Empty(t, LoadProfile(DefaultProfile).OAuthClientID, "default profile untouched")
+	assert.Equal(t, "client-123", LoadProfile("work").OAuthClientID)
+}
@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeJWT(payload string) string {
+	enc := base64.RawURLEncoding.EncodeToString
+	return enc([]byte(`{"alg":"RS256"}`)) + "." + enc([]byte(payload)) + ".sig"
+}
+
+func TestDecodeJWTClaims(t *testing.T) {
+	claims, err := DecodeJWTClaims(makeJWT(`{"sub":"user-1","iss":"https://auth.example.com","exp":1700000000,"scope":"read write"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims["sub"])
+	assert.Equal(t, "https://auth.example.com", claims["iss"])
+	assert.Equal(t, float64(1700000000), claims["exp"])
+	assert.Equal(t, []string{"read", "write"}, JWTScopes(claims))
+}
+
+func TestDecodeJWTClaims_Opaque(t *testing.T) {
+	_, err := DecodeJWTClaims("opaque-access-token")
+	assert.Error(t, err)
+}
+
+func TestJWTScopes_ScpArray(t *testing.T) {
+	assert.Equal(t, []string{"read", "admin"}, JWTScopes(map[string]any{"scp": []any{"read", "admin"}}))
+	assert.Nil(t, JWTScopes(map[string]any{}))
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// lockTimeout bounds how long a process waits for another chp process to
+// finish with the credentials.
+var lockTimeout = 30 * time.Second
+
+const lockRetryInterval = 50 * time.Millisecond
+
+// LockFile returns the path of the advisory lock guarding credential updates.
+func LockFile() string {
+	return filepath.Join(ConfigDir, "credentials.lock")
+}
+
+// lockCredentials takes the cross-process advisory lock on the credentials.
+// The returned function releases it.
+func lockCredentials() (func(), error) {
+	if err := os.MkdirAll(ConfigDir, 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(LockFile(), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open credentials lock: %w", err)
+	}
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		err := tryLockFile(f)
+		if err == nil {
+			break
+		}
+		if err != errLockBusy {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock credentials: %w", err)
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("timed out waiting for another chp process to release %s", LockFile())
+		}
+		time.Sleep(lockRetryInterval)
+	}
+
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// lockHeld is read-locked while this process holds the credentials lock, so
+// WaitForCredentialUpdates can wait for it to be released.
+var lockHeld sync.RWMutex
+
+// withCredentialsLock runs fn while holding the credentials lock.
+func withCredentialsLock(fn func() error) error {
+	unlock, err := lockCredentials()
+	if err != nil {
+		return err
+	}
+	lockHeld.RLock()
+	defer lockHeld.RUnlock()
+	defer unlock()
+	return fn()
+}
+
+// WaitForCredentialUpdates blocks until no goroutine in this process holds
+// the credentials lock. Call it before exiting on Ctrl-C: a token refresh
+// can't be abandoned once sent, as the server may already have rotated the
+// refresh token, and its replacement must be saved. Refreshes that start
+// afterwards with a cancelled context stop before sending.
+func WaitForCredentialUpdates() {
+	lockHeld.Lock()
+	lockHeld.Unlock()
+}
+
+// writeFileAtomic writes data to a temp file in the same directory and renames
+// it over path, so readers never see a partially written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // no-op once renamed
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, path)
+}
@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lollipopai/cli/internal/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "credentials.json")
+	require.NoError(t, os.WriteFile(path, []byte("old"), 0600))
+
+	require.NoError(t, writeFileAtomic(path, []byte("new"), 0600))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temp file should be renamed away")
+}
+
+func TestLockCredentials_Contention(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+	orig := lockTimeout
+	lockTimeout = 200 * time.Millisecond
+	defer func() { lockTimeout = orig }()
+
+	unlock, err := lockCredentials()
+	require.NoError(t, err)
+
+	_, err = lockCredentials()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+
+	unlock()
+	unlock2, err := lockCredentials()
+	require.NoError(t, err)
+	unlock2()
+}
+
+func TestSaveCredentials_RefusesToOverwriteCorruptFile(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+
+	require.NoError(t, os.WriteFile(CredentialsFile, []byte(`{"profiles": {"work": {`), 0600))
+
+	err := SaveCredentials(&Credentials{OAuthAccessToken: "tok"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "corrupt")
+
+	data, err := os.ReadFile(CredentialsFile)
+	require.NoError(t, err)
+	assert.Equal(t, `{"profiles": {"work": {`, string(data), "corrupt file left for the user to inspect")
+}
+
+func TestRefreshOAuthToken_AdoptsTokensRefreshedByAnotherProcess(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s: stored tokens should be adopted", r.URL.Path)
+		w.WriteHeader(500)
+	}))
+	defer srv.Close()
+
+	// Another process already rotated the tokens on disk
+	require.NoError(t, SaveCredentials(&Credentials{
+		BaseURL:           srv.URL,
+		OAuthAccessToken:  "other-access",
+		OAuthRefreshToken: "other-refresh",
+		OAuthExpiresAt:    time.Now().Unix() + 3600,
+		OAuthClientID:     "client-123",
+	}))
+
+	creds := &Credentials{
+		BaseURL:           srv.URL,
+		OAuthAccessToken:  "stale-access",
+		OAuthRefreshToken: "spent-refresh",
+		OAuthClientID:     "client-123",
+	}
+	require.NoError(t, RefreshOAuthToken(t.Context(), httpclient.New(), creds))
+	assert.Equal(t, "other-access", creds.OAuthAccessToken)
+	assert.Equal(t, "other-refresh", creds.OAuthRefreshToken)
+}
+
+// TestRefreshOAuthToken_CrossProcess runs several processes that all decide to
+// refresh at once against a server that rotates refresh tokens. Exactly one
+// refresh may reach the server; the others must adopt its result, whether
+// the tokens are kept in the credentials file or by a credential helper.
+func TestRefreshOAuthToken_CrossProcess(t *testing.T) {
+	t.Run("file", func(t *testing.T) {
+		testRefreshCrossProcess(t, "")
+	})
+	t.Run("helper", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("credential helper test uses sh")
+		}
+		// A helper keeping the tokens it is given in a file, as a keychain
+		// shared by every process would.
+		helper := filepath.Join(t.TempDir(), "helper.sh")
+		require.NoError(t, os.WriteFile(helper, []byte("#!/bin/sh\n"+
+			"tokens=\"$(dirname \"$0\")/tokens\"\n"+
+			"case \"$1\" in\n"+
+			"get) cat >/dev/null; cat \"$tokens\" 2>/dev/null ;;\n"+
+			"store) cat >\"$tokens.tmp\" && mv \"$tokens.tmp\" \"$tokens\" ;;\n"+
+			"*) cat >/dev/null ;;\n"+
+			"esac\n"), 0700))
+		testRefreshCrossProcess(t, helper)
+	})
+}
+
+func testRefreshCrossProcess(t *testing.T, helper string) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+	origHelper := CredentialHelper
+	defer func() { CredentialHelper = origHelper }()
+	CredentialHelper = helper
+
+	const procs = 4
+
+	// /ready holds every process, once it has loaded the expiring tokens,
+	// until all of them have, so none starts after another has refreshed.
+	var ready sync.WaitGroup
+	ready.Add(procs)
+	release := make(chan struct{})
+	go func() {
+		ready.Wait()
+		close(release)
+	}()
+
+	var mu sync.Mutex
+	refreshes := 0
+	current := "refresh-0"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ready" {
+			ready.Done()
+			select {
+			case <-release:
+			case <-time.After(10 * time.Second): // a process failed before /ready
+			}
+			return
+		}
+		if r.URL.Path != "/oauth/token" {
+			w.WriteHeader(404)
+			return
+		}
+		r.ParseForm()
+		mu.Lock()
+		defer mu.Unlock()
+		if r.FormValue("refresh_token") != current {
+			w.WriteHeader(400)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		refreshes++
+		current = fmt.Sprintf("refresh-%d", refreshes)
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  fmt.Sprintf("access-%d", refreshes),
+			"refresh_token": current,
+			"expires_in":    3600,
+		})
+	}))
+	defer srv.Close()
+
+	require.NoError(t, SaveCredentials(&Credentials{
+		BaseURL:           srv.URL,
+		OAuthAccessToken:  "access-0",
+		OAuthRefreshToken: "refresh-0",
+		OAuthExpiresAt:    1,
+		OAuthClientID:     "client-123",
+	}))
+
+	cmds := make([]*exec.Cmd, procs)
+	for i := range cmds {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcessRefresh$")
+		cmd.Env = append(os.Environ(), "CHP_TEST_REFRESH_PROCESS=1", "CHP_TEST_CONFIG_DIR="+ConfigDir, "CHP_TEST_HELPER="+helper)
+		require.NoError(t, cmd.Start())
+		cmds[i] = cmd
+	}
+	for _, cmd := range cmds {
+		assert.NoError(t, cmd.Wait())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, refreshes)
+	creds := LoadCredentials()
+	_, err := creds.GetToken()
+	require.NoError(t, err)
+	assert.Equal(t, "refresh-1", creds.OAuthRefreshToken)
+}
+
+func TestHelperProcessRefresh(t *testing.T) {
+	if os.Getenv("CHP_TEST_REFRESH_PROCESS") != "1" {
+		t.Skip("helper process for TestRefreshOAuthToken_CrossProcess")
+	}
+	ConfigDir = os.Getenv("CHP_TEST_CONFIG_DIR")
+	CredentialsFile = filepath.Join(ConfigDir, "credentials.json")
+	CredentialHelper = os.Getenv("CHP_TEST_HELPER")
+
+	creds := LoadCredentials()
+	_, err := creds.GetToken() // loads the tokens from a helper
+	require.NoError(t, err)
+	require.True(t, creds.IsOAuthTokenExpiring())
+	resp, err := http.Get(creds.GetBaseURL() + "/ready")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.NoError(t, RefreshOAuthToken(t.Context(), httpclient.New(), creds))
+	require.Equal(t, "access-1", creds.OAuthAccessToken)
+}
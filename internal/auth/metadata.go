@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lollipopai/cli/internal/httpclient"
+)
+
+// OAuthMetadataTTL is how long cached authorization server metadata is used
+// before it is discovered again.
+const OAuthMetadataTTL = 24 * time.Hour
+
+// OAuthServerConfig returns the authorization server metadata for these
+// credentials: the cached copy while it is fresh, otherwise newly discovered
+// metadata, which replaces the cache (callers save creds to persist it).
+// A re-discovered issuer must match the cached one, so a changed
+// protected-resource document can't silently redirect tokens elsewhere.
+func (c *Credentials) OAuthServerConfig(ctx context.Context, client *httpclient.Client) (*OAuthConfig, error) {
+	cached := c.OAuthServer
+	if cached != nil && time.Since(time.Unix(cached.FetchedAt, 0)) < OAuthMetadataTTL {
+		return cached, nil
+	}
+
+	config, err := DiscoverOAuthConfig(ctx, client, c.GetBaseURL())
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil && cached.Issuer != "" && cached.Issuer != config.Issuer {
+		return nil, fmt.Errorf("%w: issuer changed from %q to %q; run chp login to trust the new server", ErrIssuerMismatch, cached.Issuer, config.Issuer)
+	}
+	c.OAuthServer = config
+	return config, nil
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lollipopai/cli/internal/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSplitServers starts an API server whose protected-resource metadata
+// points at a separate auth server. issuer overrides the auth server's
+// advertised issuer when non-empty.
+func newSplitServers(t *testing.T, issuer string, refreshes *int) (api, as *httptest.Server) {
+	t.Helper()
+	as = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/oauth-authorization-server":
+			iss := issuer
+			if iss == "" {
+				iss = as.URL
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"issuer":         iss,
+				"token_endpoint": as.URL + "/token",
+			})
+		case "/token":
+			*refreshes++
+			json.NewEncoder(w).Encode(map[string]any{"access_token": "from-auth-server", "expires_in": 3600})
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	api = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/.well-known/oauth-protected-resource" {
+			json.NewEncoder(w).Encode(map[string]any{"authorization_servers": []string{as.URL}})
+			return
+		}
+		t.Errorf("unexpected request to API server: %s", r.URL.Path)
+		w.WriteHeader(404)
+	}))
+	t.Cleanup(func() { api.Close(); as.Close() })
+	return api, as
+}
+
+func TestDiscoverOAuthConfig_IssuerMismatch(t *testing.T) {
+	refreshes := 0
+	api, _ := newSplitServers(t, "https://evil.example.com", &refreshes)
+
+	_, err := DiscoverOAuthConfig(t.Context(), httpclient.New(), api.URL)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrIssuerMismatch)
+}
+
+func TestDiscoverOAuthConfig_MissingIssuer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/oauth-authorization-server" {
+			w.WriteHeader(404)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"token_endpoint": "https://evil.example.com/token"})
+	}))
+	defer srv.Close()
+
+	_, err := DiscoverOAuthConfig(t.Context(), httpclient.New(), srv.URL)
+	assert.ErrorIs(t, err, ErrIssuerMismatch)
+}
+
+func TestDiscoverOAuthConfig_RecordsIssuer(t *testing.T) {
+	refreshes := 0
+	api, as := newSplitServers(t, "", &refreshes)
+
+	config, err := DiscoverOAuthConfig(t.Context(), httpclient.New(), api.URL)
+	require.NoError(t, err)
+	assert.Equal(t, as.URL, config.Issuer)
+	assert.Equal(t, as.URL+"/token", config.TokenEndpoint)
+	assert.InDelta(t, time.Now().Unix(), config.FetchedAt, 5)
+}
+
+func TestRefreshOAuthToken_UsesSeparateAuthServer(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+	refreshes := 0
+	api, as := newSplitServers(t, "", &refreshes)
+
+	creds := &Credentials{BaseURL: api.URL, OAuthRefreshToken: "refresh-tok", OAuthClientID: "client-123"}
+	require.NoError(t, RefreshOAuthToken(t.Context(), httpclient.New(), creds))
+	assert.Equal(t, 1, refreshes)
+	assert.Equal(t, "from-auth-server", creds.OAuthAccessToken)
+
+	// Discovered metadata is persisted with the credentials
+	loaded := LoadCredentials()
+	require.NotNil(t, loaded.OAuthServer)
+	assert.Equal(t, as.URL, loaded.OAuthServer.Issuer)
+	assert.Equal(t, as.URL+"/token", loaded.OAuthServer.TokenEndpoint)
+}
+
+func TestRefreshOAuthToken_UsesFreshCachedMetadata(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+
+	refreshes := 0
+	as := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/token", r.URL.Path, "cached metadata should skip discovery")
+		refreshes++
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "cached-endpoint-tok"})
+	}))
+	defer as.Close()
+
+	creds := &Credentials{
+		BaseURL:           "http://127.0.0.1:1", // unreachable: must not be used
+		OAuthRefreshToken: "refresh-tok",
+		OAuthClientID:     "client-123",
+		OAuthServer: &OAuthConfig{
+			Issuer:        as.URL,
+			TokenEndpoint: as.URL + "/token",
+			FetchedAt:     time.Now().Unix(),
+		},
+	}
+	require.NoError(t, RefreshOAuthToken(t.Context(), httpclient.New(), creds))
+	assert.Equal(t, 1, refreshes)
+	assert.Equal(t, "cached-endpoint-tok", creds.OAuthAccessToken)
+}
+
+func TestOAuthServerConfig_StaleCacheRediscovers(t *testing.T) {
+	refreshes := 0
+	api, as := newSplitServers(t, "", &refreshes)
+
+	creds := &Credentials{
+		BaseURL: api.URL,
+		OAuthServer: &OAuthConfig{
+			Issuer:        as.URL,
+			TokenEndpoint: "https://old.example.com/token",
+			FetchedAt:     time.Now().Add(-OAuthMetadataTTL - time.Minute).Unix(),
+		},
+	}
+	config, err := creds.OAuthServerConfig(t.Context(), httpclient.New())
+	require.NoError(t, err)
+	assert.Equal(t, as.URL+"/token", config.TokenEndpoint)
+	assert.Same(t, config, creds.OAuthServer)
+}
+
+func TestOAuthServerConfig_IssuerChangeRejected(t *testing.T) {
+	refreshes := 0
+	api, _ := newSplitServers(t, "", &refreshes)
+
+	creds := &Credentials{
+		BaseURL:           api.URL,
+		OAuthRefreshToken: "refresh-tok",
+		OAuthClientID:     "client-123",
+		OAuthServer: &OAuthConfig{
+			Issuer:        "https://original.example.com",
+			TokenEndpoint: "https://original.example.com/token",
+		},
+	}
+	_, err := creds.OAuthServerConfig(t.Context(), httpclient.New())
+	assert.ErrorIs(t, err, ErrIssuerMismatch)
+
+	// Refresh must not fall back to the stale endpoint on an issuer change
+	err = RefreshOAuthToken(t.Context(), httpclient.New(), creds)
+	assert.ErrorIs(t, err, ErrIssuerMismatch)
+	assert.Equal(t, 0, refreshes)
+}
@@ -1,24 +1,46 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/lollipopai/cli/internal/httpclient"
-	"github.com/lollipopai/cli/internal/output"
 )
 
-// OAuthConfig holds discovered authorization server endpoints.
+// OAuthConfig holds discovered authorization server endpoints. It is cached
+// per profile in Credentials (see OAuthServerConfig).
 type OAuthConfig struct {
-	AuthorizationEndpoint string
-	TokenEndpoint         string
-	RegistrationEndpoint  string
-	ScopesSupported       []string
+	Issuer                             string   `json:"issuer"`
+	AuthorizationEndpoint              string   `json:"authorization_endpoint"`
+	TokenEndpoint                      string   `json:"token_endpoint"`
+	RegistrationEndpoint               string   `json:"registration_endpoint"`
+	DeviceAuthorizationEndpoint        string   `json:"device_authorization_endpoint,omitempty"`
+	RevocationEndpoint                 string   `json:"revocation_endpoint,omitempty"`
+	PushedAuthorizationRequestEndpoint string   `json:"pushed_authorization_request_endpoint,omitempty"`
+	DPoPSigningAlgs                    []string `json:"dpop_signing_alg_values_supported,omitempty"`
+	ScopesSupported                    []string `json:"scopes_supported,omitempty"`
+	FetchedAt                          int64    `json:"fetched_at"`
+}
+
+// ErrIssuerMismatch is returned when authorization server metadata names a
+// different issuer than expected (RFC 8414 §3.3).
+var ErrIssuerMismatch = errors.New("authorization server issuer mismatch")
+
+// CallbackServer is a temporary loopback server that receives the OAuth redirect.
+type CallbackServer struct {
+	Results     chan *CallbackResult
+	Port        int
+	RedirectURI string
+	server      *http.Server
 }
 
 // CallbackResult is returned by the local OAuth callback server.
@@ -29,11 +51,13 @@ type CallbackResult struct {
 	ErrorDescription string
 }
 
-// DiscoverOAuthConfig performs two-step .well-known discovery.
-func DiscoverOAuthConfig(client *httpclient.Client, baseURL string) (*OAuthConfig, error) {
+// DiscoverOAuthConfig performs two-step .well-known discovery. The issuer in
+// the authorization server metadata must match the server it was fetched from
+// (RFC 8414 §3.3).
+func DiscoverOAuthConfig(ctx context.Context, client *httpclient.Client, baseURL string) (*OAuthConfig, error) {
 	// Step 1: Try protected resource metadata
 	authServerURL := baseURL
-	body, err := client.GetJSON(baseURL+"/.well-known/oauth-protected-resource", nil)
+	body, err := client.GetJSON(ctx, baseURL+"/.well-known/oauth-protected-resource", nil)
 	if err == nil {
 		var prMeta map[string]any
 		if json.Unmarshal(body, &prMeta) == nil {
@@ -43,12 +67,12 @@ func DiscoverOAuthConfig(client *httpclient.Client, baseURL string) (*OAuthConfi
 				}
 			}
 		}
-	} else {
-		output.Warn("Could not fetch protected resource metadata, using base URL as auth server.")
+	} else if ctx.Err() == nil {
+		slog.Warn("Could not fetch protected resource metadata, using base URL as auth server.", "err", err)
 	}
 
 	// Step 2: Fetch authorization server metadata
-	body, err = client.GetJSON(authServerURL+"/.well-known/oauth-authorization-server", nil)
+	body, err = client.GetJSON(ctx, authServerURL+"/.well-known/oauth-authorization-server", nil)
 	if err != nil {
 		return nil, fmt.Errorf("could not fetch authorization server metadata: %w", err)
 	}
@@ -58,10 +82,26 @@ func DiscoverOAuthConfig(client *httpclient.Client, baseURL string) (*OAuthConfi
 		return nil, fmt.Errorf("invalid authorization server metadata: %w", err)
 	}
 
+	// The issuer is required (RFC 8414 §3.2), so metadata without one can't
+	// be shown to belong to the server it was fetched from.
+	issuer := stringOrDefault(asMeta, "issuer", "")
+	if issuer == "" {
+		return nil, fmt.Errorf("%w: metadata from %q has no issuer", ErrIssuerMismatch, authServerURL)
+	}
+	if strings.TrimSuffix(issuer, "/") != strings.TrimSuffix(authServerURL, "/") {
+		return nil, fmt.Errorf("%w: metadata issuer %q does not match %q", ErrIssuerMismatch, issuer, authServerURL)
+	}
+
 	config := &OAuthConfig{
+		Issuer:                issuer,
+		FetchedAt:             time.Now().Unix(),
 		AuthorizationEndpoint: stringOrDefault(asMeta, "authorization_endpoint", authServerURL+"/oauth/authorize"),
 		TokenEndpoint:         stringOrDefault(asMeta, "token_endpoint", authServerURL+"/oauth/token"),
 		RegistrationEndpoint:  stringOrDefault(asMeta, "registration_endpoint", authServerURL+"/oauth/register"),
+		// No defaults: these are only used when the server advertises them.
+		DeviceAuthorizationEndpoint:        stringOrDefault(asMeta, "device_authorization_endpoint", ""),
+		RevocationEndpoint:                 stringOrDefault(asMeta, "revocation_endpoint", ""),
+		PushedAuthorizationRequestEndpoint: stringOrDefault(asMeta, "pushed_authorization_request_endpoint", ""),
 	}
 
 	if scopes, ok := asMeta["scopes_supported"].([]any); ok {
@@ -71,6 +111,13 @@ func DiscoverOAuthConfig(client *httpclient.Client, baseURL string) (*OAuthConfi
 			}
 		}
 	}
+	if algs, ok := asMeta["dpop_signing_alg_values_supported"].([]any); ok {
+		for _, a := range algs {
+			if str, ok := a.(string); ok {
+				config.DPoPSigningAlgs = append(config.DPoPSigningAlgs, str)
+			}
+		}
+	}
 	if len(config.ScopesSupported) == 0 {
 		config.ScopesSupported = []string{"read", "write"}
 	}
@@ -78,49 +125,105 @@ func DiscoverOAuthConfig(client *httpclient.Client, baseURL string) (*OAuthConfi
 	return config, nil
 }
 
-// RegisterClient performs dynamic client registration.
-func RegisterClient(client *httpclient.Client, registrationEndpoint string) (string, error) {
+// RegisterClient performs dynamic client registration with the port-agnostic
+// loopback redirect URI (see OAuthLoopbackRedirectURI).
+func RegisterClient(ctx context.Context, client *httpclient.Client, registrationEndpoint string) (*ClientRegistration, error) {
 	payload := map[string]any{
 		"client_name":                "Cherrypick CLI",
-		"redirect_uris":             []string{OAuthRedirectURI},
+		"redirect_uris":              []string{OAuthLoopbackRedirectURI},
+		"grant_types":                []string{"authorization_code", "refresh_token", DeviceCodeGrantType},
 		"token_endpoint_auth_method": "none",
 	}
-	body, err := client.PostJSON(registrationEndpoint, payload, nil)
+	body, err := client.PostJSON(ctx, registrationEndpoint, payload, nil)
 	if err != nil {
-		return "", fmt.Errorf("dynamic client registration failed: %w", err)
+		return nil, fmt.Errorf("dynamic client registration failed: %w", err)
 	}
 	var resp map[string]any
 	if err := json.Unmarshal(body, &resp); err != nil {
-		return "", fmt.Errorf("invalid registration response: %w", err)
+		return nil, fmt.Errorf("invalid registration response: %w", err)
 	}
 	clientID, ok := resp["client_id"].(string)
 	if !ok {
-		return "", fmt.Errorf("no client_id in registration response")
+		return nil, fmt.Errorf("no client_id in registration response")
 	}
-	return clientID, nil
+	return &ClientRegistration{
+		ClientID:                clientID,
+		RegistrationAccessToken: stringOrDefault(resp, "registration_access_token", ""),
+		RegistrationClientURI:   stringOrDefault(resp, "registration_client_uri", ""),
+	}, nil
+}
+
+// AuthorizationRequest holds the parameters of an authorization code request.
+type AuthorizationRequest struct {
+	ClientID    string
+	RedirectURI string
+	Challenge   string // PKCE S256 code challenge
+	State       string
+	Scope       string
+	Resource    string // RFC 8707 resource indicator, usually the API base URL
+}
+
+func (r *AuthorizationRequest) params() url.Values {
+	params := url.Values{
+		"client_id":             {r.ClientID},
+		"redirect_uri":          {r.RedirectURI},
+		"response_type":         {"code"},
+		"scope":                 {r.Scope},
+		"state":                 {r.State},
+		"code_challenge":        {r.Challenge},
+		"code_challenge_method": {"S256"},
+	}
+	if r.Resource != "" {
+		params.Set("resource", r.Resource)
+	}
+	return params
 }
 
 // BuildAuthorizationURL constructs the OAuth authorize URL with PKCE params.
-func BuildAuthorizationURL(config *OAuthConfig, clientID, challenge, state string) string {
+func BuildAuthorizationURL(config *OAuthConfig, req *AuthorizationRequest) string {
+	return config.AuthorizationEndpoint + "?" + req.params().Encode()
+}
+
+// PushAuthorizationRequest sends the authorization request parameters to the
+// server's PAR endpoint (RFC 9126) and returns the authorize URL to open,
+// which only carries client_id and the returned request_uri. With a non-nil
+// DPoP key the request carries a proof, binding the code to the key.
+func PushAuthorizationRequest(ctx context.Context, client *httpclient.Client, config *OAuthConfig, req *AuthorizationRequest, dpop *DPoPKey) (string, error) {
+	endpoint := config.PushedAuthorizationRequestEndpoint
+	body, err := dpop.Do("POST", endpoint, "", func(headers map[string]string) ([]byte, error) {
+		return client.PostForm(ctx, endpoint, req.params(), headers)
+	})
+	if err != nil {
+		return "", fmt.Errorf("pushed authorization request failed: %w", classifyClientError(body, err))
+	}
+	var resp map[string]any
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("invalid pushed authorization response: %w", err)
+	}
+	requestURI := stringOrDefault(resp, "request_uri", "")
+	if requestURI == "" {
+		return "", fmt.Errorf("pushed authorization response missing request_uri")
+	}
+	params := url.Values{
+		"client_id":   {req.ClientID},
+		"request_uri": {requestURI},
+	}
+	return config.AuthorizationEndpoint + "?" + params.Encode(), nil
+}
+
+// Scope returns the space-separated scope string to request.
+func (config *OAuthConfig) Scope() string {
 	scope := strings.Join(config.ScopesSupported, " ")
 	if scope == "" {
 		scope = "read write"
 	}
-	params := url.Values{
-		"client_id":             {clientID},
-		"redirect_uri":         {OAuthRedirectURI},
-		"response_type":        {"code"},
-		"scope":                {scope},
-		"state":                {state},
-		"code_challenge":       {challenge},
-		"code_challenge_method": {"S256"},
-	}
-	return config.AuthorizationEndpoint + "?" + params.Encode()
+	return scope
 }
 
-// StartCallbackServer starts a temporary HTTP server on 127.0.0.1:9876 to receive
-// the OAuth redirect. Returns a channel for the result and a shutdown function.
-func StartCallbackServer() (chan *CallbackResult, func(), error) {
+// StartCallbackServer starts a temporary HTTP server on 127.0.0.1 to receive the
+// OAuth redirect. A port of 0 binds an ephemeral port (RFC 8252 §7.3); the
+// redirect URI is built from the port actually bound.
+func StartCallbackServer(port int) (*CallbackServer, error) {
 	resultCh := make(chan *CallbackResult, 1)
 
 	mux := http.NewServeMux()
@@ -155,30 +258,47 @@ func StartCallbackServer() (chan *CallbackResult, func(), error) {
 		resultCh <- result
 	})
 
-	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", OAuthRedirectPort))
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to start callback server on port %d: %w", OAuthRedirectPort, err)
+		return nil, fmt.Errorf("failed to start callback server on port %d: %w", port, err)
 	}
+	boundPort := listener.Addr().(*net.TCPAddr).Port
 
 	server := &http.Server{Handler: mux}
 	go server.Serve(listener)
 
-	shutdown := func() { server.Close() }
-	return resultCh, shutdown, nil
+	return &CallbackServer{
+		Results:     resultCh,
+		Port:        boundPort,
+		RedirectURI: CallbackRedirectURI(boundPort),
+		server:      server,
+	}, nil
 }
 
-// ExchangeCode exchanges an authorization code for tokens.
-func ExchangeCode(client *httpclient.Client, tokenEndpoint, code, verifier, clientID string) (map[string]any, error) {
+// Shutdown stops the callback server.
+func (s *CallbackServer) Shutdown() {
+	s.server.Close()
+}
+
+// ExchangeCode exchanges an authorization code for tokens, audience-bound to
+// resource (RFC 8707) if set. With a non-nil DPoP key the request carries a
+// proof, so the tokens are bound to the key.
+func ExchangeCode(ctx context.Context, client *httpclient.Client, tokenEndpoint, code, verifier, clientID, redirectURI, resource string, dpop *DPoPKey) (map[string]any, error) {
 	params := url.Values{
 		"grant_type":    {"authorization_code"},
 		"code":          {code},
-		"redirect_uri":  {OAuthRedirectURI},
+		"redirect_uri":  {redirectURI},
 		"client_id":     {clientID},
 		"code_verifier": {verifier},
 	}
-	body, err := client.PostForm(tokenEndpoint, params, nil)
+	if resource != "" {
+		params.Set("resource", resource)
+	}
+	body, err := dpop.Do("POST", tokenEndpoint, "", func(headers map[string]string) ([]byte, error) {
+		return client.PostForm(ctx, tokenEndpoint, params, headers)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("token exchange failed: %w", err)
+		return nil, fmt.Errorf("token exchange failed: %w", classifyClientError(body, err))
 	}
 	var resp map[string]any
 	if err := json.Unmarshal(body, &resp); err != nil {
@@ -2,6 +2,8 @@ package auth
 
 import (
 	"encoding/json"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -25,32 +27,41 @@ func TestDiscoverOAuthConfig(t *testing.T) {
 
 	// Test that it falls back gracefully when auth server isn't reachable
 	client := httpclient.New()
-	_, err := DiscoverOAuthConfig(client, srv.URL)
+	client.Retry.MaxRetries = 0
+	_, err := DiscoverOAuthConfig(t.Context(), client, srv.URL)
 	assert.Error(t, err) // Can't reach https://auth.example.com
 }
 
 func TestDiscoverOAuthConfig_DirectFallback(t *testing.T) {
+	var srv *httptest.Server
 	mux := http.NewServeMux()
 	mux.HandleFunc("/.well-known/oauth-protected-resource", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(404)
 	})
 	mux.HandleFunc("/.well-known/oauth-authorization-server", func(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]any{
-			"authorization_endpoint": "https://example.com/auth",
-			"token_endpoint":         "https://example.com/token",
-			"registration_endpoint":  "https://example.com/register",
-			"scopes_supported":       []string{"read", "write", "admin"},
+			"issuer":                                srv.URL,
+			"authorization_endpoint":                "https://example.com/auth",
+			"token_endpoint":                        "https://example.com/token",
+			"registration_endpoint":                 "https://example.com/register",
+			"scopes_supported":                      []string{"read", "write", "admin"},
+			"device_authorization_endpoint":         "https://example.com/device",
+			"revocation_endpoint":                   "https://example.com/revoke",
+			"pushed_authorization_request_endpoint": "https://example.com/par",
 		})
 	})
-	srv := httptest.NewServer(mux)
+	srv = httptest.NewServer(mux)
 	defer srv.Close()
 
 	client := httpclient.New()
-	config, err := DiscoverOAuthConfig(client, srv.URL)
+	config, err := DiscoverOAuthConfig(t.Context(), client, srv.URL)
 	require.NoError(t, err)
 	assert.Equal(t, "https://example.com/auth", config.AuthorizationEndpoint)
 	assert.Equal(t, "https://example.com/token", config.TokenEndpoint)
 	assert.Equal(t, "https://example.com/register", config.RegistrationEndpoint)
+	assert.Equal(t, "https://example.com/device", config.DeviceAuthorizationEndpoint)
+	assert.Equal(t, "https://example.com/revoke", config.RevocationEndpoint)
+	assert.Equal(t, "https://example.com/par", config.PushedAuthorizationRequestEndpoint)
 	assert.Equal(t, []string{"read", "write", "admin"}, config.ScopesSupported)
 }
 
@@ -62,17 +73,24 @@ func TestRegisterClient(t *testing.T) {
 		json.NewDecoder(r.Body).Decode(&body)
 		assert.Equal(t, "Cherrypick CLI", body["client_name"])
 		assert.Equal(t, "none", body["token_endpoint_auth_method"])
+		assert.Equal(t, []any{OAuthLoopbackRedirectURI}, body["redirect_uris"])
 
 		json.NewEncoder(w).Encode(map[string]string{
-			"client_id": "new-client-id",
+			"client_id":                 "new-client-id",
+			"registration_access_token": "reg-token",
+			"registration_client_uri":   "https://example.com/register/new-client-id",
 		})
 	}))
 	defer srv.Close()
 
 	client := httpclient.New()
-	clientID, err := RegisterClient(client, srv.URL)
+	reg, err := RegisterClient(t.Context(), client, srv.URL)
 	require.NoError(t, err)
-	assert.Equal(t, "new-client-id", clientID)
+	assert.Equal(t, &ClientRegistration{
+		ClientID:                "new-client-id",
+		RegistrationAccessToken: "reg-token",
+		RegistrationClientURI:   "https://example.com/register/new-client-id",
+	}, reg)
 }
 
 func TestBuildAuthorizationURL(t *testing.T) {
@@ -80,48 +98,113 @@ func TestBuildAuthorizationURL(t *testing.T) {
 		AuthorizationEndpoint: "https://auth.example.com/authorize",
 		ScopesSupported:       []string{"read", "write"},
 	}
-	url := BuildAuthorizationURL(config, "client-123", "challenge-abc", "state-xyz")
+	url := BuildAuthorizationURL(config, &AuthorizationRequest{
+		ClientID:    "client-123",
+		RedirectURI: "http://127.0.0.1:54321/callback",
+		Challenge:   "challenge-abc",
+		State:       "state-xyz",
+		Scope:       config.Scope(),
+		Resource:    "https://api.example.com",
+	})
 	assert.Contains(t, url, "https://auth.example.com/authorize?")
 	assert.Contains(t, url, "client_id=client-123")
+	assert.Contains(t, url, "redirect_uri=http%3A%2F%2F127.0.0.1%3A54321%2Fcallback")
 	assert.Contains(t, url, "code_challenge=challenge-abc")
 	assert.Contains(t, url, "code_challenge_method=S256")
 	assert.Contains(t, url, "state=state-xyz")
 	assert.Contains(t, url, "response_type=code")
 	assert.Contains(t, url, "scope=read+write")
+	assert.Contains(t, url, "resource=https%3A%2F%2Fapi.example.com")
+}
+
+func TestPushAuthorizationRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		r.ParseForm()
+		assert.Equal(t, "client-123", r.FormValue("client_id"))
+		assert.Equal(t, "code", r.FormValue("response_type"))
+		assert.Equal(t, "challenge-abc", r.FormValue("code_challenge"))
+		assert.Equal(t, "state-xyz", r.FormValue("state"))
+		assert.Equal(t, "read", r.FormValue("scope"))
+		assert.Equal(t, "https://api.example.com", r.FormValue("resource"))
+
+		w.WriteHeader(201)
+		json.NewEncoder(w).Encode(map[string]any{
+			"request_uri": "urn:ietf:params:oauth:request_uri:abc",
+			"expires_in":  60,
+		})
+	}))
+	defer srv.Close()
+
+	config := &OAuthConfig{
+		AuthorizationEndpoint:              "https://auth.example.com/authorize",
+		PushedAuthorizationRequestEndpoint: srv.URL,
+	}
+	authURL, err := PushAuthorizationRequest(t.Context(), httpclient.New(), config, &AuthorizationRequest{
+		ClientID:    "client-123",
+		RedirectURI: "http://127.0.0.1:54321/callback",
+		Challenge:   "challenge-abc",
+		State:       "state-xyz",
+		Scope:       "read",
+		Resource:    "https://api.example.com",
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "https://auth.example.com/authorize?client_id=client-123&request_uri=urn%3Aietf%3Aparams%3Aoauth%3Arequest_uri%3Aabc", authURL,
+		"only client_id and request_uri go through the browser")
 }
 
 func TestStartCallbackServer_Success(t *testing.T) {
-	resultCh, shutdown, err := StartCallbackServer()
+	callback, err := StartCallbackServer(0)
 	require.NoError(t, err)
-	defer shutdown()
+	defer callback.Shutdown()
+
+	assert.NotZero(t, callback.Port, "ephemeral port should be resolved")
+	assert.Equal(t, fmt.Sprintf("http://127.0.0.1:%d/callback", callback.Port), callback.RedirectURI)
 
 	// Send a successful callback
-	resp, err := http.Get("http://127.0.0.1:9876/callback?code=authcode&state=mystate")
+	resp, err := http.Get(callback.RedirectURI + "?code=authcode&state=mystate")
 	require.NoError(t, err)
 	assert.Equal(t, 200, resp.StatusCode)
 	resp.Body.Close()
 
-	result := <-resultCh
+	result := <-callback.Results
 	assert.Equal(t, "authcode", result.Code)
 	assert.Equal(t, "mystate", result.State)
 }
 
 func TestStartCallbackServer_Error(t *testing.T) {
-	resultCh, shutdown, err := StartCallbackServer()
+	callback, err := StartCallbackServer(0)
 	require.NoError(t, err)
-	defer shutdown()
+	defer callback.Shutdown()
 
-	resp, err := http.Get("http://127.0.0.1:9876/callback?error=access_denied&error_description=User+denied")
+	resp, err := http.Get(callback.RedirectURI + "?error=access_denied&error_description=User+denied")
 	require.NoError(t, err)
 	assert.Equal(t, 400, resp.StatusCode)
 	resp.Body.Close()
 
-	result := <-resultCh
+	result := <-callback.Results
 	assert.Equal(t, "", result.Code)
 	assert.Equal(t, "access_denied", result.Error)
 	assert.Equal(t, "User denied", result.ErrorDescription)
 }
 
+func TestStartCallbackServer_ExplicitPort(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	callback, err := StartCallbackServer(port)
+	require.NoError(t, err)
+	defer callback.Shutdown()
+	assert.Equal(t, port, callback.Port)
+
+	// Binding the same port twice fails with a useful message
+	_, err = StartCallbackServer(port)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), fmt.Sprintf("port %d", port))
+}
+
 func TestExchangeCode(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		assert.Equal(t, "POST", r.Method)
@@ -130,6 +213,8 @@ func TestExchangeCode(t *testing.T) {
 		assert.Equal(t, "the-code", r.FormValue("code"))
 		assert.Equal(t, "the-verifier", r.FormValue("code_verifier"))
 		assert.Equal(t, "client-123", r.FormValue("client_id"))
+		assert.Equal(t, "http://127.0.0.1:54321/callback", r.FormValue("redirect_uri"))
+		assert.Equal(t, "https://api.example.com", r.FormValue("resource"))
 
 		json.NewEncoder(w).Encode(map[string]any{
 			"access_token":  "new-access-token",
@@ -140,8 +225,32 @@ func TestExchangeCode(t *testing.T) {
 	defer srv.Close()
 
 	client := httpclient.New()
-	resp, err := ExchangeCode(client, srv.URL, "the-code", "the-verifier", "client-123")
+	resp, err := ExchangeCode(t.Context(), client, srv.URL, "the-code", "the-verifier", "client-123", "http://127.0.0.1:54321/callback", "https://api.example.com", nil)
 	require.NoError(t, err)
 	assert.Equal(t, "new-access-token", resp["access_token"])
 	assert.Equal(t, "new-refresh-token", resp["refresh_token"])
 }
+
+func TestExchangeCode_InvalidClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(401)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+	}))
+	defer srv.Close()
+
+	_, err := ExchangeCode(t.Context(), httpclient.New(), srv.URL, "code", "verifier", "purged-client", "http://127.0.0.1/callback", "", nil)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrInvalidClient)
+}
+
+func TestExchangeCode_OtherErrorIsNotInvalidClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+	}))
+	defer srv.Close()
+
+	_, err := ExchangeCode(t.Context(), httpclient.New(), srv.URL, "code", "verifier", "client", "http://127.0.0.1/callback", "", nil)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrInvalidClient)
+}
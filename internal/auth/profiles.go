@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
+
+	"github.com/lollipopai/cli/internal/httpclient"
+)
+
+// DefaultProfile is the profile stored at the top level of credentials.json.
+const DefaultProfile = "default"
+
+// ProfileEnvVar selects the active profile when --profile is not given.
+const ProfileEnvVar = "CHP_PROFILE"
+
+// ProfileOverride is set from the --profile flag and takes precedence over
+// CHP_PROFILE and the stored current profile.
+var ProfileOverride string
+
+// ErrProfileNotFound is returned when deleting a profile that doesn't exist.
+var ErrProfileNotFound = errors.New("profile not found")
+
+var profileNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// ValidateProfileName checks that name is usable as a profile name.
+func ValidateProfileName(name string) error {
+	if !profileNameRe.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use letters, digits, '.', '_' or '-'", name)
+	}
+	return nil
+}
+
+// ActiveProfile returns the profile to use: --profile, then CHP_PROFILE, then
+// the profile selected with `chp profile use`, then "default".
+func ActiveProfile() string {
+	if ProfileOverride != "" {
+		return ProfileOverride
+	}
+	if env := os.Getenv(ProfileEnvVar); env != "" {
+		return env
+	}
+	if current := readCredentialsFile().CurrentProfile; current != "" {
+		return current
+	}
+	return DefaultProfile
+}
+
+// LoadProfile reads credentials for a named profile. Returns zero-value if the
+// profile or file doesn't exist.
+func LoadProfile(name string) *Credentials {
+	file := readCredentialsFile()
+	creds := &Credentials{}
+	if name == DefaultProfile {
+		*creds = file.Credentials
+	} else if stored, ok := file.Profiles[name]; ok && stored != nil {
+		creds = stored
+	}
+	creds.profile = name
+	return creds
+}
+
+// SaveProfile writes credentials for a named profile, leaving other profiles untouched.
+func SaveProfile(name string, creds *Credentials) error {
+	return withCredentialsLock(func() error {
+		return saveProfileLocked(name, creds)
+	})
+}
+
+// saveProfileLocked is SaveProfile for callers already holding the lock.
+func saveProfileLocked(name string, creds *Credentials) error {
+	if err := ValidateProfileName(name); err != nil {
+		return err
+	}
+	creds.profile = name
+	if httpclient.Replaying() {
+		slog.Debug("Not saving credentials while replaying a cassette.", "profile", name)
+		return nil
+	}
+	if CredentialHelper != "" {
+		if err := storeInHelper(name, creds); err != nil {
+			return err
+		}
+		creds = creds.withoutTokens()
+	}
+	creds = creds.stored()
+	return updateCredentialsFileLocked(func(file *credentialsFile) error {
+		if name == DefaultProfile {
+			file.Credentials = *creds
+		} else {
+			if file.Profiles == nil {
+				file.Profiles = map[string]*Credentials{}
+			}
+			file.Profiles[name] = creds
+		}
+		return nil
+	})
+}
+
+// ListProfiles returns all stored profile names, sorted, with "default" first.
+func ListProfiles() []string {
+	file := readCredentialsFile()
+	names := make([]string, 0, len(file.Profiles))
+	for name := range file.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append([]string{DefaultProfile}, names...)
+}
+
+// SetCurrentProfile makes name the profile used when neither --profile nor
+// CHP_PROFILE is set.
+func SetCurrentProfile(name string) error {
+	if err := ValidateProfileName(name); err != nil {
+		return err
+	}
+	return updateCredentialsFile(func(file *credentialsFile) error {
+		if name == DefaultProfile {
+			file.CurrentProfile = ""
+			return nil
+		}
+		file.CurrentProfile = name
+		if file.Profiles == nil {
+			file.Profiles = map[string]*Credentials{}
+		}
+		if _, ok := file.Profiles[name]; !ok {
+			file.Profiles[name] = &Credentials{}
+		}
+		return nil
+	})
+}
+
+// DeleteProfile removes a profile's stored credentials. Deleting "default"
+// clears the top-level credentials; deleting the current profile switches back
+// to "default". The file is removed once nothing is left in it.
+func DeleteProfile(name string) error {
+	if httpclient.Replaying() {
+		slog.Debug("Not deleting credentials while replaying a cassette.", "profile", name)
+		return nil
+	}
+	if CredentialHelper != "" {
+		if err := eraseFromHelper(name, LoadProfile(name)); err != nil {
+			return err
+		}
+	}
+	return updateCredentialsFile(func(file *credentialsFile) error {
+		if name == DefaultProfile {
+			if file.Credentials == (Credentials{}) {
+				return ErrProfileNotFound
+			}
+			file.Credentials = Credentials{}
+			return nil
+		}
+		if _, ok := file.Profiles[name]; !ok {
+			return ErrProfileNotFound
+		}
+		delete(file.Profiles, name)
+		if file.CurrentProfile == name {
+			file.CurrentProfile = ""
+		}
+		return nil
+	})
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/lollipopai/cli/internal/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCredentials_LegacyFlatFile(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+
+	// File as written by the Python CLI
+	legacy := `{"base_url":"https://legacy.example.com","oauth_access_token":"legacy-tok","oauth_client_id":"legacy-client"}`
+	require.NoError(t, os.WriteFile(CredentialsFile, []byte(legacy), 0600))
+
+	assert.Equal(t, DefaultProfile, ActiveProfile())
+	creds := LoadCredentials()
+	assert.Equal(t, "https://legacy.example.com", creds.BaseURL)
+	assert.Equal(t, "legacy-tok", creds.OAuthAccessToken)
+	assert.Equal(t, []string{DefaultProfile}, ListProfiles())
+}
+
+func TestSaveProfile_KeepsDefaultAtTopLevel(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+
+	require.NoError(t, SaveProfile(DefaultProfile, &Credentials{OAuthAccessToken: "home-tok"}))
+	require.NoError(t, SaveProfile("work", &Credentials{BaseURL: "https://work.example.com", OAuthAccessToken: "work-tok"}))
+
+	data, err := os.ReadFile(CredentialsFile)
+	require.NoError(t, err)
+	var raw map[string]any
+	require.NoError(t, json.Unmarshal(data, &raw))
+	assert.Equal(t, "home-tok", raw["oauth_access_token"], "default profile stays Python-compatible")
+	profiles := raw["profiles"].(map[string]any)
+	assert.Equal(t, "work-tok", profiles["work"].(map[string]any)["oauth_access_token"])
+
+	assert.Equal(t, "home-tok", LoadProfile(DefaultProfile).OAuthAccessToken)
+	assert.Equal(t, "work-tok", LoadProfile("work").OAuthAccessToken)
+	assert.Equal(t, "https://work.example.com", LoadProfile("work").GetBaseURL())
+	assert.Equal(t, []string{DefaultProfile, "work"}, ListProfiles())
+}
+
+func TestActiveProfile_Precedence(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+
+	assert.Equal(t, DefaultProfile, ActiveProfile())
+
+	require.NoError(t, SetCurrentProfile("stored"))
+	assert.Equal(t, "stored", ActiveProfile())
+
+	t.Setenv(ProfileEnvVar, "from-env")
+	assert.Equal(t, "from-env", ActiveProfile())
+
+	ProfileOverride = "from-flag"
+	assert.Equal(t, "from-flag", ActiveProfile())
+}
+
+func TestSaveCredentials_UsesActiveProfile(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+
+	require.NoError(t, SaveCredentials(&Credentials{OAuthAccessToken: "default-tok"}))
+	ProfileOverride = "test"
+	require.NoError(t, SaveCredentials(&Credentials{OAuthAccessToken: "test-tok"}))
+
+	assert.Equal(t, "test-tok", LoadCredentials().OAuthAccessToken)
+	assert.Equal(t, "default-tok", LoadProfile(DefaultProfile).OAuthAccessToken, "other profiles untouched")
+}
+
+func TestSetCurrentProfile_Default(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+
+	require.NoError(t, SetCurrentProfile("work"))
+	require.NoError(t, SetCurrentProfile(DefaultProfile))
+	assert.Equal(t, DefaultProfile, ActiveProfile())
+	assert.Equal(t, []string{DefaultProfile, "work"}, ListProfiles())
+}
+
+func TestDeleteProfile(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+
+	require.NoError(t, SaveProfile(DefaultProfile, &Credentials{OAuthAccessToken: "home-tok"}))
+	require.NoError(t, SaveProfile("work", &Credentials{OAuthAccessToken: "work-tok"}))
+	require.NoError(t, SetCurrentProfile("work"))
+
+	require.NoError(t, DeleteProfile("work"))
+	assert.Equal(t, DefaultProfile, ActiveProfile(), "deleting the current profile falls back to default")
+	assert.ErrorIs(t, DeleteProfile("work"), ErrProfileNotFound)
+
+	require.NoError(t, DeleteProfile(DefaultProfile))
+	_, err := os.Stat(CredentialsFile)
+	assert.True(t, os.IsNotExist(err), "empty credentials file is removed")
+	assert.ErrorIs(t, DeleteProfile(DefaultProfile), ErrProfileNotFound)
+}
+
+func TestSaveProfile_NotWhileReplaying(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+
+	require.NoError(t, SaveProfile(DefaultProfile, &Credentials{OAuthAccessToken: "real-tok"}))
+	t.Setenv(httpclient.ReplayEnvVar, "cassette.json")
+
+	// Tokens served from a cassette are redacted.
+	require.NoError(t, SaveProfile(DefaultProfile, &Credentials{OAuthAccessToken: "[REDACTED]"}))
+	require.NoError(t, DeleteProfile(DefaultProfile))
+	assert.Equal(t, "real-tok", LoadProfile(DefaultProfile).OAuthAccessToken)
+}
+
+func TestValidateProfileName(t *testing.T) {
+	assert.NoError(t, ValidateProfileName("work"))
+	assert.NoError(t, ValidateProfileName("test-2.old"))
+	assert.Error(t, ValidateProfileName(""))
+	assert.Error(t, ValidateProfileName("../etc"))
+	assert.Error(t, ValidateProfileName("has space"))
+}
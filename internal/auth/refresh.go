@@ -1,43 +1,105 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/url"
-	"time"
 
 	"github.com/lollipopai/cli/internal/httpclient"
-	"github.com/lollipopai/cli/internal/output"
 )
 
-// RefreshOAuthToken attempts to refresh an OAuth token. Updates and saves creds on success.
+// RefreshOAuthToken attempts to refresh an OAuth token. Updates and saves creds on success;
+// with a credential helper configured, rotated tokens are pushed via its store action.
 // Returns error (not fatal) so callers can handle gracefully.
-func RefreshOAuthToken(client *httpclient.Client, creds *Credentials) error {
+//
+// The refresh runs under the cross-process credentials lock. If another chp
+// process refreshed while this one waited, its stored tokens are adopted
+// instead: with refresh-token rotation ours has already been spent.
+func RefreshOAuthToken(ctx context.Context, client *httpclient.Client, creds *Credentials) error {
+	if EnvToken() != "" {
+		return fmt.Errorf("token from %s can't be refreshed", TokenEnvVar)
+	}
 	if creds.OAuthRefreshToken == "" || creds.OAuthClientID == "" {
 		return fmt.Errorf("no refresh token or client ID")
 	}
 
-	baseURL := creds.GetBaseURL()
+	return withCredentialsLock(func() error {
+		if adoptStoredTokens(creds) {
+			return nil
+		}
+		return refreshLocked(ctx, client, creds)
+	})
+}
 
-	// Discover token endpoint
-	tokenEndpoint := baseURL + "/oauth/token"
-	body, err := client.GetJSON(baseURL+"/.well-known/oauth-authorization-server", nil)
-	if err == nil {
-		var meta map[string]any
-		if json.Unmarshal(body, &meta) == nil {
-			if ep, ok := meta["token_endpoint"].(string); ok {
-				tokenEndpoint = ep
-			}
+// adoptStoredTokens copies freshly refreshed tokens from disk, or from the
+// credential helper, into creds if another process rotated them. Reports
+// whether it did.
+func adoptStoredTokens(creds *Credentials) bool {
+	stored := LoadProfile(creds.Profile())
+	if CredentialHelper != "" {
+		// The helper holds the tokens; the file only has their expiry.
+		stored.helperQueried = true
+		if err := stored.loadFromHelper(); err != nil {
+			slog.Warn(err.Error())
+			return false
 		}
 	}
+	if stored.OAuthRefreshToken == "" || stored.OAuthRefreshToken == creds.OAuthRefreshToken {
+		return false
+	}
+	if stored.OAuthAccessToken == "" || stored.IsOAuthTokenExpiring() {
+		return false
+	}
+	creds.OAuthAccessToken = stored.OAuthAccessToken
+	creds.OAuthRefreshToken = stored.OAuthRefreshToken
+	creds.OAuthExpiresAt = stored.OAuthExpiresAt
+	creds.OAuthTokenType = stored.OAuthTokenType
+	return true
+}
 
-	params := url.Values{
-		"grant_type":    {"refresh_token"},
-		"refresh_token": {creds.OAuthRefreshToken},
-		"client_id":     {creds.OAuthClientID},
+func refreshLocked(ctx context.Context, client *httpclient.Client, creds *Credentials) error {
+	// Use the cached endpoints (from the auth server named by the
+	// protected-resource metadata). If discovery fails, fall back to a stale
+	// cache, then to the base URL.
+	server := &OAuthConfig{
+		TokenEndpoint:        creds.GetBaseURL() + "/oauth/token",
+		RegistrationEndpoint: creds.GetBaseURL() + "/oauth/register",
+	}
+	if config, err := creds.OAuthServerConfig(ctx, client); err == nil {
+		server = config
+	} else if errors.Is(err, ErrIssuerMismatch) {
+		return err
+	} else if creds.OAuthServer != nil && creds.OAuthServer.TokenEndpoint != "" {
+		server = creds.OAuthServer
 	}
 
-	body, err = client.PostForm(tokenEndpoint, params, nil)
+	// Once the refresh is sent it is not cancelled with ctx: the server may
+	// already have rotated the refresh token, and abandoning the response
+	// would lose the only copy of its replacement.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx = context.WithoutCancel(ctx)
+
+	body, err := postRefresh(ctx, client, server.TokenEndpoint, creds)
+	if errors.Is(err, ErrInvalidClient) && server.RegistrationEndpoint != "" {
+		// The server purged our client. Register a new one, so the next login
+		// works without editing credentials, and retry once in case the
+		// refresh token survived.
+		slog.Warn("Stored OAuth client was rejected; registering a new one.")
+		reg, regErr := RegisterClient(ctx, client, server.RegistrationEndpoint)
+		if regErr != nil {
+			return fmt.Errorf("token refresh failed: %w (re-registration failed: %v)", err, regErr)
+		}
+		creds.ApplyRegistration(reg)
+		if err := saveProfileLocked(creds.Profile(), creds); err != nil {
+			return fmt.Errorf("failed to save new client registration: %w", err)
+		}
+		body, err = postRefresh(ctx, client, server.TokenEndpoint, creds)
+	}
 	if err != nil {
 		return fmt.Errorf("token refresh failed: %w", err)
 	}
@@ -47,20 +109,33 @@ func RefreshOAuthToken(client *httpclient.Client, creds *Credentials) error {
 		return fmt.Errorf("invalid refresh response: %w", err)
 	}
 
-	if at, ok := resp["access_token"].(string); ok {
-		creds.OAuthAccessToken = at
-	}
-	if rt, ok := resp["refresh_token"].(string); ok {
-		creds.OAuthRefreshToken = rt
-	}
-	if ei, ok := resp["expires_in"].(float64); ok {
-		creds.OAuthExpiresAt = time.Now().Unix() + int64(ei)
-	}
+	creds.ApplyTokenResponse(resp)
 
-	if err := SaveCredentials(creds); err != nil {
+	if err := saveProfileLocked(creds.Profile(), creds); err != nil {
 		return fmt.Errorf("failed to save refreshed credentials: %w", err)
 	}
 
-	output.Info("OAuth token refreshed.")
+	slog.Info("OAuth token refreshed.")
 	return nil
 }
+
+// postRefresh sends a refresh_token grant for creds' current client.
+func postRefresh(ctx context.Context, client *httpclient.Client, tokenEndpoint string, creds *Credentials) ([]byte, error) {
+	params := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {creds.OAuthRefreshToken},
+		"client_id":     {creds.OAuthClientID},
+		"resource":      {creds.GetBaseURL()},
+	}
+	dpop, err := creds.DPoP()
+	if err != nil {
+		return nil, err
+	}
+	body, err := dpop.Do("POST", tokenEndpoint, "", func(headers map[string]string) ([]byte, error) {
+		return client.PostForm(ctx, tokenEndpoint, params, headers)
+	})
+	if err != nil {
+		return nil, classifyClientError(body, err)
+	}
+	return body, nil
+}
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -22,6 +23,7 @@ func TestRefreshOAuthToken_Success(t *testing.T) {
 		assert.Equal(t, "refresh_token", r.FormValue("grant_type"))
 		assert.Equal(t, "old-refresh", r.FormValue("refresh_token"))
 		assert.Equal(t, "client-123", r.FormValue("client_id"))
+		assert.Equal(t, "http://"+r.Host, r.FormValue("resource"), "token stays audience-bound to the API")
 
 		json.NewEncoder(w).Encode(map[string]any{
 			"access_token":  "new-access",
@@ -42,7 +44,7 @@ func TestRefreshOAuthToken_Success(t *testing.T) {
 	}
 
 	client := httpclient.New()
-	err := RefreshOAuthToken(client, creds)
+	err := RefreshOAuthToken(t.Context(), client, creds)
 	require.NoError(t, err)
 
 	assert.Equal(t, "new-access", creds.OAuthAccessToken)
@@ -57,14 +59,14 @@ func TestRefreshOAuthToken_Success(t *testing.T) {
 func TestRefreshOAuthToken_NoRefreshToken(t *testing.T) {
 	creds := &Credentials{OAuthClientID: "client-123"}
 	client := httpclient.New()
-	err := RefreshOAuthToken(client, creds)
+	err := RefreshOAuthToken(t.Context(), client, creds)
 	assert.Error(t, err)
 }
 
 func TestRefreshOAuthToken_NoClientID(t *testing.T) {
 	creds := &Credentials{OAuthRefreshToken: "refresh-tok"}
 	client := httpclient.New()
-	err := RefreshOAuthToken(client, creds)
+	err := RefreshOAuthToken(t.Context(), client, creds)
 	assert.Error(t, err)
 }
 
@@ -85,7 +87,7 @@ func TestRefreshOAuthToken_ServerError(t *testing.T) {
 	}
 
 	client := httpclient.New()
-	err := RefreshOAuthToken(client, creds)
+	err := RefreshOAuthToken(t.Context(), client, creds)
 	assert.Error(t, err, "refresh should return error, not fatal")
 }
 
@@ -119,8 +121,31 @@ func TestRefreshOAuthToken_SavesCredentials(t *testing.T) {
 	}
 
 	client := httpclient.New()
-	require.NoError(t, RefreshOAuthToken(client, creds))
+	require.NoError(t, RefreshOAuthToken(t.Context(), client, creds))
 
 	loaded := LoadCredentials()
 	assert.Equal(t, "saved-tok", loaded.OAuthAccessToken)
 }
+
+func TestRefreshOAuthToken_CompletesDespiteCancellation(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/oauth/token" {
+			w.WriteHeader(404)
+			return
+		}
+		cancel() // Ctrl-C while the rotation is in flight
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "new-access", "refresh_token": "new-refresh"})
+	}))
+	defer srv.Close()
+
+	creds := &Credentials{BaseURL: srv.URL, OAuthRefreshToken: "old-refresh", OAuthClientID: "client-123"}
+	require.NoError(t, RefreshOAuthToken(ctx, httpclient.New(), creds))
+	assert.Equal(t, "new-refresh", LoadCredentials().OAuthRefreshToken, "the rotated token must not be lost")
+
+	err := RefreshOAuthToken(ctx, httpclient.New(), LoadCredentials())
+	assert.ErrorIs(t, err, context.Canceled, "a refresh not yet sent is abandoned")
+}
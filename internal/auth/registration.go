@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lollipopai/cli/internal/httpclient"
+)
+
+// ErrInvalidClient is returned when the server no longer recognises the
+// stored OAuth client, e.g. after purging dynamically registered clients.
+// The fix is to register a new client.
+var ErrInvalidClient = errors.New("OAuth client rejected by server")
+
+// ClientRegistration is the result of dynamic client registration. The
+// registration access token and client URI, if the server returns them,
+// manage the registration (RFC 7592).
+type ClientRegistration struct {
+	ClientID                string
+	RegistrationAccessToken string
+	RegistrationClientURI   string
+}
+
+// IsClientRejection reports whether an OAuth error code means the client ID
+// itself was rejected.
+func IsClientRejection(code string) bool {
+	return code == "invalid_client" || code == "unauthorized_client"
+}
+
+// classifyClientError wraps err with ErrInvalidClient when the error response
+// body names a client rejection.
+func classifyClientError(body []byte, err error) error {
+	var resp map[string]any
+	if json.Unmarshal(body, &resp) == nil && IsClientRejection(stringOrDefault(resp, "error", "")) {
+		return fmt.Errorf("%w: %w", ErrInvalidClient, err)
+	}
+	return err
+}
+
+// ApplyRegistration stores a new client registration. Tokens are kept: a
+// caller retrying a refresh decides whether they are still usable.
+func (c *Credentials) ApplyRegistration(reg *ClientRegistration) {
+	c.OAuthClientID = reg.ClientID
+	c.OAuthRedirectURI = OAuthLoopbackRedirectURI
+	c.OAuthRegistrationToken = reg.RegistrationAccessToken
+	c.OAuthRegistrationURI = reg.RegistrationClientURI
+}
+
+// ReadClientRegistration fetches the client's registration from its
+// registration client URI (RFC 7592 §2.1).
+func ReadClientRegistration(ctx context.Context, client *httpclient.Client, creds *Credentials) (map[string]any, error) {
+	if err := checkRegistrationManagement(creds); err != nil {
+		return nil, err
+	}
+	body, err := client.GetJSON(ctx, creds.OAuthRegistrationURI, registrationHeaders(creds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client registration: %w", err)
+	}
+	var resp map[string]any
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("invalid client registration response: %w", err)
+	}
+	return resp, nil
+}
+
+// DeleteClientRegistration deregisters the client at the server (RFC 7592 §2.3).
+func DeleteClientRegistration(ctx context.Context, client *httpclient.Client, creds *Credentials) error {
+	if err := checkRegistrationManagement(creds); err != nil {
+		return err
+	}
+	if _, err := client.Delete(ctx, creds.OAuthRegistrationURI, registrationHeaders(creds)); err != nil {
+		return fmt.Errorf("failed to delete client registration: %w", err)
+	}
+	return nil
+}
+
+func checkRegistrationManagement(creds *Credentials) error {
+	if creds.OAuthClientID == "" {
+		return errors.New("no OAuth client registered. Run: chp login")
+	}
+	if creds.OAuthRegistrationURI == "" || creds.OAuthRegistrationToken == "" {
+		return errors.New("server did not return a registration client URI and access token for this client; it can't be managed (RFC 7592)")
+	}
+	return nil
+}
+
+func registrationHeaders(creds *Credentials) map[string]string {
+	return map[string]string{"Authorization": "Bearer " + creds.OAuthRegistrationToken}
+}
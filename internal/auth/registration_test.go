@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lollipopai/cli/internal/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshOAuthToken_ReregistersRejectedClient(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+
+	var registrations, refreshes atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/register", func(w http.ResponseWriter, r *http.Request) {
+		registrations.Add(1)
+		json.NewEncoder(w).Encode(map[string]string{
+			"client_id":                 "new-client",
+			"registration_access_token": "reg-token",
+			"registration_client_uri":   "http://" + r.Host + "/oauth/register/new-client",
+		})
+	})
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		refreshes.Add(1)
+		r.ParseForm()
+		if r.FormValue("client_id") != "new-client" {
+			w.WriteHeader(401)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "new-access", "expires_in": 3600})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	creds := &Credentials{
+		BaseURL:           srv.URL,
+		OAuthAccessToken:  "old-access",
+		OAuthRefreshToken: "refresh",
+		OAuthClientID:     "purged-client",
+	}
+	require.NoError(t, RefreshOAuthToken(t.Context(), httpclient.New(), creds))
+	assert.Equal(t, "new-access", creds.OAuthAccessToken)
+	assert.Equal(t, int32(1), registrations.Load())
+	assert.Equal(t, int32(2), refreshes.Load(), "refresh is retried exactly once")
+
+	stored := LoadCredentials()
+	assert.Equal(t, "new-client", stored.OAuthClientID)
+	assert.Equal(t, "reg-token", stored.OAuthRegistrationToken)
+	assert.Equal(t, srv.URL+"/oauth/register/new-client", stored.OAuthRegistrationURI)
+}
+
+func TestRefreshOAuthToken_ReregistersOnlyOnce(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+
+	var registrations atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/register", func(w http.ResponseWriter, r *http.Request) {
+		registrations.Add(1)
+		json.NewEncoder(w).Encode(map[string]string{"client_id": "new-client"})
+	})
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		json.NewEncoder(w).Encode(map[string]string{"error": "unauthorized_client"})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	creds := &Credentials{BaseURL: srv.URL, OAuthRefreshToken: "refresh", OAuthClientID: "purged-client"}
+	err := RefreshOAuthToken(t.Context(), httpclient.New(), creds)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrInvalidClient)
+	assert.Equal(t, int32(1), registrations.Load())
+}
+
+func TestClientRegistrationManagement(t *testing.T) {
+	var deleted atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/register/client-1", r.URL.Path)
+		assert.Equal(t, "Bearer reg-token", r.Header.Get("Authorization"))
+		switch r.Method {
+		case "GET":
+			json.NewEncoder(w).Encode(map[string]any{"client_id": "client-1", "client_name": "Cherrypick CLI"})
+		case "DELETE":
+			deleted.Store(true)
+			w.WriteHeader(204)
+		}
+	}))
+	defer srv.Close()
+
+	creds := &Credentials{
+		OAuthClientID:          "client-1",
+		OAuthRegistrationToken: "reg-token",
+		OAuthRegistrationURI:   srv.URL + "/register/client-1",
+	}
+	reg, err := ReadClientRegistration(t.Context(), httpclient.New(), creds)
+	require.NoError(t, err)
+	assert.Equal(t, "Cherrypick CLI", reg["client_name"])
+
+	require.NoError(t, DeleteClientRegistration(t.Context(), httpclient.New(), creds))
+	assert.True(t, deleted.Load())
+}
+
+func TestClientRegistrationManagement_Unmanaged(t *testing.T) {
+	_, err := ReadClientRegistration(t.Context(), httpclient.New(), &Credentials{OAuthClientID: "client-1"})
+	assert.ErrorContains(t, err, "RFC 7592")
+	err = DeleteClientRegistration(t.Context(), httpclient.New(), &Credentials{})
+	assert.ErrorContains(t, err, "chp login")
+}
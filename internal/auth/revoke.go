@@ -0,0 +1,25 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/lollipopai/cli/internal/httpclient"
+)
+
+// RevokeToken revokes an access or refresh token at the RFC 7009 revocation
+// endpoint. tokenTypeHint is "access_token" or "refresh_token".
+func RevokeToken(ctx context.Context, client *httpclient.Client, revocationEndpoint, token, tokenTypeHint, clientID string) error {
+	params := url.Values{
+		"token":           {token},
+		"token_type_hint": {tokenTypeHint},
+	}
+	if clientID != "" {
+		params.Set("client_id", clientID)
+	}
+	if _, err := client.PostForm(ctx, revocationEndpoint, params, nil); err != nil {
+		return fmt.Errorf("%s revocation failed: %w", tokenTypeHint, err)
+	}
+	return nil
+}
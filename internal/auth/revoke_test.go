@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lollipopai/cli/internal/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevokeToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		r.ParseForm()
+		assert.Equal(t, "refresh-tok", r.FormValue("token"))
+		assert.Equal(t, "refresh_token", r.FormValue("token_type_hint"))
+		assert.Equal(t, "client-123", r.FormValue("client_id"))
+		w.WriteHeader(200)
+	}))
+	defer srv.Close()
+
+	require.NoError(t, RevokeToken(t.Context(), httpclient.New(), srv.URL, "refresh-tok", "refresh_token", "client-123"))
+}
+
+func TestRevokeToken_ServerError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		w.Write([]byte(`{"error":"unsupported_token_type"}`))
+	}))
+	defer srv.Close()
+
+	err := RevokeToken(t.Context(), httpclient.New(), srv.URL, "access-tok", "access_token", "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "access_token revocation failed")
+	assert.Contains(t, err.Error(), "unsupported_token_type")
+}
@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/term"
+)
+
+// PassphraseEnvVar supplies the encrypted-file passphrase non-interactively.
+const PassphraseEnvVar = "CHP_CREDENTIAL_PASSPHRASE"
+
+// pbkdf2Iterations is lowered in tests; the value used is stored in the file.
+var pbkdf2Iterations = 600000
+
+// deriveKey derives the file key from the passphrase. Replaceable for tests.
+var deriveKey = func(passphrase string, salt []byte, iterations int) ([]byte, error) {
+	return pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+}
+
+// PassphraseFunc returns the passphrase for the encrypted-file store. confirm
+// is true when a new file is about to be created. Replaceable for tests.
+var PassphraseFunc = defaultPassphrase
+
+// EncryptedCredentialsFile returns the path of the encrypted-file store.
+func EncryptedCredentialsFile() string {
+	return filepath.Join(ConfigDir, "credentials.json.enc")
+}
+
+// encryptedEnvelope is the on-disk format of the encrypted-file store.
+type encryptedEnvelope struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// encryptedFileBackend stores credentials AES-256-GCM encrypted with a key
+// derived from a passphrase (PBKDF2-SHA256). Works without a desktop session.
+//
+// The derivation is deliberately slow, so the key is kept for the life of the
+// process along with the salt and iteration count it was derived for, and
+// writes reuse that salt: a command that reads and refreshes credentials
+// derives it once.
+type encryptedFileBackend struct {
+	passphrase string
+
+	salt       []byte
+	iterations int
+	gcm        cipher.AEAD
+}
+
+func (b *encryptedFileBackend) Name() string { return StoreEncryptedFile }
+
+func (b *encryptedFileBackend) getPassphrase(confirm bool) (string, error) {
+	if b.passphrase != "" {
+		return b.passphrase, nil
+	}
+	p, err := PassphraseFunc(confirm)
+	if err != nil {
+		return "", err
+	}
+	if p == "" {
+		return "", errors.New("empty credential passphrase")
+	}
+	b.passphrase = p
+	return p, nil
+}
+
+func (b *encryptedFileBackend) Read() ([]byte, error) {
+	data, err := os.ReadFile(EncryptedCredentialsFile())
+	if err != nil {
+		return nil, err
+	}
+	var env encryptedEnvelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("corrupt encrypted credentials file: %w", err)
+	}
+	if env.Version != 1 || env.KDF != "pbkdf2-sha256" {
+		return nil, fmt.Errorf("unsupported encrypted credentials format (version %d, kdf %q)", env.Version, env.KDF)
+	}
+
+	passphrase, err := b.getPassphrase(false)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := b.cipher(passphrase, env.Salt, env.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, env.Nonce, env.Ciphertext, nil)
+	if err != nil {
+		b.passphrase, b.gcm = "", nil
+		return nil, errors.New("failed to decrypt credentials: wrong passphrase?")
+	}
+	return plaintext, nil
+}
+
+func (b *encryptedFileBackend) Write(data []byte) error {
+	_, statErr := os.Stat(EncryptedCredentialsFile())
+	passphrase, err := b.getPassphrase(os.IsNotExist(statErr))
+	if err != nil {
+		return err
+	}
+
+	env := encryptedEnvelope{
+		Version:    1,
+		KDF:        "pbkdf2-sha256",
+		Iterations: pbkdf2Iterations,
+	}
+	if b.gcm != nil && b.iterations == env.Iterations {
+		env.Salt = b.salt
+	} else {
+		env.Salt = make([]byte, 16)
+		if _, err := rand.Read(env.Salt); err != nil {
+			return err
+		}
+	}
+	gcm, err := b.cipher(passphrase, env.Salt, env.Iterations)
+	if err != nil {
+		return err
+	}
+	env.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(env.Nonce); err != nil {
+		return err
+	}
+	env.Ciphertext = gcm.Seal(nil, env.Nonce, data, nil)
+
+	out, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(EncryptedCredentialsFile(), out, 0600)
+}
+
+func (b *encryptedFileBackend) Delete() error {
+	if err := os.Remove(EncryptedCredentialsFile()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// cipher returns the AEAD for the key derived from passphrase and salt,
+// deriving it only if the cached key was derived for another salt.
+func (b *encryptedFileBackend) cipher(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	if b.gcm != nil && b.iterations == iterations && bytes.Equal(b.salt, salt) {
+		return b.gcm, nil
+	}
+	gcm, err := newGCM(passphrase, salt, iterations)
+	if err != nil {
+		return nil, err
+	}
+	b.salt, b.iterations, b.gcm = salt, iterations, gcm
+	return gcm, nil
+}
+
+func newGCM(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	key, err := deriveKey(passphrase, salt, iterations)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// defaultPassphrase reads CHP_CREDENTIAL_PASSPHRASE, or prompts on the terminal.
+func defaultPassphrase(confirm bool) (string, error) {
+	if p := os.Getenv(PassphraseEnvVar); p != "" {
+		return p, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("credentials are encrypted: set %s or run interactively", PassphraseEnvVar)
+	}
+	fmt.Fprint(os.Stderr, "Credential store passphrase: ")
+	p, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if confirm {
+		fmt.Fprint(os.Stderr, "Confirm passphrase: ")
+		again, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if string(again) != string(p) {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+	return string(p), nil
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/zalando/go-keyring"
+)
+
+const (
+	keyringService = "chp"
+	keyringUser    = "credentials"
+)
+
+// keyringBackend stores credentials in the OS keychain: the freedesktop Secret
+// Service over D-Bus on Linux, Keychain on macOS, Credential Manager on Windows.
+type keyringBackend struct{}
+
+func (keyringBackend) Name() string { return StoreKeyring }
+
+func (keyringBackend) Read() ([]byte, error) {
+	secret, err := keyring.Get(keyringService, keyringUser)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil, fmt.Errorf("no credentials in keyring: %w", os.ErrNotExist)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("keyring unavailable: %w", err)
+	}
+	return []byte(secret), nil
+}
+
+func (keyringBackend) Write(data []byte) error {
+	if err := keyring.Set(keyringService, keyringUser, string(data)); err != nil {
+		return fmt.Errorf("keyring unavailable: %w", err)
+	}
+	return nil
+}
+
+func (keyringBackend) Delete() error {
+	err := keyring.Delete(keyringService, keyringUser)
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		return fmt.Errorf("keyring unavailable: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zalando/go-keyring"
+)
+
+func useTestStore(t *testing.T, name string, passphrase string) {
+	t.Helper()
+	origBackend := backend
+	origIterations := pbkdf2Iterations
+	origPassphrase := PassphraseFunc
+	pbkdf2Iterations = 1000
+	PassphraseFunc = func(bool) (string, error) { return passphrase, nil }
+	require.NoError(t, SetCredentialStore(name))
+	t.Cleanup(func() {
+		backend = origBackend
+		pbkdf2Iterations = origIterations
+		PassphraseFunc = origPassphrase
+	})
+}
+
+func TestSetCredentialStore_Unknown(t *testing.T) {
+	err := SetCredentialStore("floppy-disk")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown credential store")
+	assert.Equal(t, StoreFile, CredentialStore())
+}
+
+func TestEncryptedFileStore_RoundTrip(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+	useTestStore(t, StoreEncryptedFile, "correct horse")
+
+	original := &Credentials{OAuthAccessToken: "secret-access", OAuthRefreshToken: "secret-refresh"}
+	require.NoError(t, SaveCredentials(original))
+
+	data, err := os.ReadFile(EncryptedCredentialsFile())
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "secret-access", "tokens must not be stored in plaintext")
+	_, err = os.Stat(CredentialsFile)
+	assert.True(t, os.IsNotExist(err), "plaintext file not written")
+
+	// A fresh backend instance must re-derive the key from the passphrase
+	require.NoError(t, SetCredentialStore(StoreEncryptedFile))
+	assert.Equal(t, original, LoadCredentials())
+}
+
+func TestEncryptedFileStore_DerivesKeyOnce(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+	useTestStore(t, StoreEncryptedFile, "correct horse")
+	require.NoError(t, SaveCredentials(&Credentials{OAuthAccessToken: "secret-access"}))
+
+	origDerive := deriveKey
+	t.Cleanup(func() { deriveKey = origDerive })
+	derivations := 0
+	deriveKey = func(passphrase string, salt []byte, iterations int) ([]byte, error) {
+		derivations++
+		return origDerive(passphrase, salt, iterations)
+	}
+
+	require.NoError(t, SetCredentialStore(StoreEncryptedFile))
+	for range 3 {
+		creds := LoadCredentials()
+		creds.OAuthAccessToken += "-refreshed"
+		require.NoError(t, SaveCredentials(creds))
+	}
+	assert.Equal(t, 1, derivations, "reads and writes reuse the key derived for the file's salt")
+	assert.Equal(t, "secret-access-refreshed-refreshed-refreshed", LoadCredentials().OAuthAccessToken)
+}
+
+func TestEncryptedFileStore_WrongPassphrase(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+	useTestStore(t, StoreEncryptedFile, "correct horse")
+	require.NoError(t, SaveCredentials(&Credentials{OAuthAccessToken: "secret-access"}))
+
+	PassphraseFunc = func(bool) (string, error) { return "battery staple", nil }
+	b := &encryptedFileBackend{}
+	_, err := b.Read()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "wrong passphrase")
+}
+
+func TestEncryptedFileStore_NoPassphrase(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+	useTestStore(t, StoreEncryptedFile, "")
+
+	err := SaveCredentials(&Credentials{OAuthAccessToken: "tok"})
+	assert.Error(t, err)
+}
+
+func TestKeyringStore_RoundTrip(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+	keyring.MockInit()
+	useTestStore(t, StoreKeyring, "")
+
+	_, err := backend.Read()
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	original := &Credentials{OAuthAccessToken: "keyring-tok"}
+	require.NoError(t, SaveCredentials(original))
+	assert.Equal(t, original, LoadCredentials())
+
+	require.NoError(t, DeleteProfile(DefaultProfile))
+	_, err = backend.Read()
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestMigrateCredentialStore(t *testing.T) {
+	cleanup := setupTestCreds(t)
+	defer cleanup()
+	useTestStore(t, StoreFile, "correct horse")
+
+	original := &Credentials{OAuthAccessToken: "migrate-me"}
+	require.NoError(t, SaveCredentials(original))
+
+	require.NoError(t, MigrateCredentialStore(StoreFile, StoreEncryptedFile))
+	_, err := os.Stat(CredentialsFile)
+	assert.True(t, os.IsNotExist(err), "plaintext file removed after migration")
+
+	require.NoError(t, SetCredentialStore(StoreEncryptedFile))
+	assert.Equal(t, original, LoadCredentials())
+
+	// Migrating an empty store is a no-op
+	require.NoError(t, MigrateCredentialStore(StoreFile, StoreKeyring))
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+)
+
+// TokenEnvVar supplies an access token that overrides stored credentials.
+const TokenEnvVar = "CHP_TOKEN"
+
+// EnvToken returns the access token from CHP_TOKEN, if set.
+func EnvToken() string {
+	return strings.TrimSpace(os.Getenv(TokenEnvVar))
+}
+
+// ParseTokenInput parses tokens supplied to `chp login --with-token`: either
+// a bare access token, or key=value lines using the credential helper keys
+// (access_token, refresh_token, client_id, expires_at).
+//
+// The returned Credentials hold only the supplied fields. If no expiry is
+// given but the access token is a JWT, its exp claim is used.
+func ParseTokenInput(data []byte) (*Credentials, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, errors.New("no token on stdin")
+	}
+
+	c := &Credentials{}
+	if bytes.Contains(data, []byte("access_token=")) {
+		c.applyAttrs(parseAttrs(data))
+	} else {
+		if strings.ContainsAny(string(data), " \t\n") {
+			return nil, errors.New("expected a single token, or key=value lines with access_token")
+		}
+		c.OAuthAccessToken = string(data)
+	}
+	if c.OAuthAccessToken == "" {
+		return nil, errors.New("missing access_token")
+	}
+
+	if c.OAuthExpiresAt == 0 {
+		if claims, err := DecodeJWTClaims(c.OAuthAccessToken); err == nil {
+			if exp, ok := claims["exp"].(float64); ok {
+				c.OAuthExpiresAt = int64(exp)
+			}
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTokenInput_BareToken(t *testing.T) {
+	c, err := ParseTokenInput([]byte("  opaque-token\n"))
+	require.NoError(t, err)
+	assert.Equal(t, &Credentials{OAuthAccessToken: "opaque-token"}, c)
+}
+
+func TestParseTokenInput_KeyValue(t *testing.T) {
+	c, err := ParseTokenInput([]byte("access_token=at\nrefresh_token=rt\nclient_id=cid\nexpires_at=1700000000\n"))
+	require.NoError(t, err)
+	assert.Equal(t, &Credentials{
+		OAuthAccessToken:  "at",
+		OAuthRefreshToken: "rt",
+		OAuthClientID:     "cid",
+		OAuthExpiresAt:    1700000000,
+	}, c)
+}
+
+func TestParseTokenInput_JWTExpiry(t *testing.T) {
+	c, err := ParseTokenInput([]byte(makeJWT(`{"sub":"u","exp":1800000000}`)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1800000000), c.OAuthExpiresAt)
+}
+
+func TestParseTokenInput_Invalid(t *testing.T) {
+	for name, input := range map[string]string{
+		"empty":         "\n",
+		"two tokens":    "tok-a\ntok-b",
+		"no access key": "refresh_token=rt\nclient_id=cid",
+		"empty access":  "access_token=\nrefresh_token=rt",
+	} {
+		_, err := ParseTokenInput([]byte(input))
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,312 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lollipopai/cli/internal/auth"
+	"github.com/lollipopai/cli/internal/httpclient"
+	"github.com/lollipopai/cli/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	authTokenJSON         bool
+	authStatusJSON        bool
+	authClientDeleteLocal bool
+)
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Inspect and export credentials",
+	Long: `Inspect and export credentials for the active profile.
+
+Examples:
+  chp auth token                         Print the access token
+  curl -H "Authorization: Bearer $(chp auth token)" ...
+  chp auth status                        Show token claims and server status
+  chp auth status --json                 Same, as JSON
+  chp auth client show                   Show the registered OAuth client
+  chp auth client delete                 Deregister the OAuth client`,
+}
+
+var authTokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Print the access token, refreshing it if needed",
+	Long: `Print the access token for the active profile, refreshing it first if it
+is about to expire. Only the token is written to stdout, so it can be piped
+into other tools; status messages go to stderr.
+
+Tokens from "chp login --dpop" are bound to a key that never leaves chp, so
+other tools can't use them without their own DPoP proofs.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output.SetStatusWriter(os.Stderr)
+
+		creds := auth.LoadCredentials()
+		token, err := creds.GetToken()
+		if err != nil {
+			return err
+		}
+		if creds.OAuthAccessToken != "" && creds.IsOAuthTokenExpiring() {
+			if err := auth.RefreshOAuthToken(cmd.Context(), httpclient.New(), creds); cmd.Context().Err() != nil {
+				return cmd.Context().Err()
+			} else if err != nil {
+				return fmt.Errorf("token expired and refresh failed: %v\nTry: chp login", err)
+			}
+			token = creds.OAuthAccessToken
+		}
+
+		if !authTokenJSON {
+			fmt.Println(token)
+			return nil
+		}
+		tokenType := "Bearer"
+		if token == creds.OAuthAccessToken && creds.UsesDPoP() {
+			tokenType = auth.DPoPTokenType
+		}
+		result := map[string]any{
+			"access_token": token,
+			"token_type":   tokenType,
+		}
+		if creds.OAuthExpiresAt > 0 && token == creds.OAuthAccessToken {
+			result["expires_at"] = time.Unix(creds.OAuthExpiresAt, 0).UTC().Format(time.RFC3339)
+		}
+		output.PrintJSON(result)
+		return nil
+	},
+}
+
+var authStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show token claims, refresh-token presence and server reachability",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		creds := auth.LoadCredentials()
+		status := authStatus(cmd.Context(), creds)
+		if authStatusJSON {
+			output.PrintJSON(status)
+		} else {
+			printAuthStatus(status)
+		}
+		if !status.LoggedIn {
+			return exitCode(1)
+		}
+		return nil
+	},
+}
+
+var authClientCmd = &cobra.Command{
+	Use:   "client",
+	Short: "Manage the dynamically registered OAuth client",
+	Long: `Manage the OAuth client chp registered with the server for the active
+profile (RFC 7592). Needs the registration access token and client URI the
+server returned at registration.`,
+}
+
+var authClientShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the client registration",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		creds := auth.LoadCredentials()
+		if creds.OAuthClientID == "" {
+			return errors.New("no OAuth client registered. Run: chp login")
+		}
+		result := map[string]any{
+			"client_id":               creds.OAuthClientID,
+			"redirect_uri":            nilIfEmpty(creds.OAuthRedirectURI),
+			"registration_client_uri": nilIfEmpty(creds.OAuthRegistrationURI),
+		}
+		reg, err := auth.ReadClientRegistration(cmd.Context(), httpclient.New(), creds)
+		if err != nil {
+			output.Warn(err.Error())
+		} else {
+			result["registration"] = reg
+		}
+		output.PrintJSON(result)
+		return nil
+	},
+}
+
+var authClientDeleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Deregister the client and forget it locally",
+	Long: `Deregister the OAuth client at the server and remove it from the active
+profile. Its refresh token is removed too, as it is bound to the client. The
+next "chp login" registers a new client.
+
+Use --local-only to forget a client the server can't deregister, e.g. one
+registered before chp stored registration tokens.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		creds := auth.LoadCredentials()
+		if creds.OAuthClientID == "" {
+			return errors.New("no OAuth client registered")
+		}
+		clientID := creds.OAuthClientID
+		if !authClientDeleteLocal {
+			if err := auth.DeleteClientRegistration(cmd.Context(), httpclient.New(), creds); cmd.Context().Err() != nil {
+				return cmd.Context().Err()
+			} else if err != nil {
+				return fmt.Errorf("%v\nUse --local-only to forget the client without deregistering it.", err)
+			}
+		}
+
+		creds.OAuthClientID = ""
+		creds.OAuthRedirectURI = ""
+		creds.OAuthRegistrationToken = ""
+		creds.OAuthRegistrationURI = ""
+		creds.OAuthRefreshToken = ""
+		if err := auth.SaveCredentials(creds); err != nil {
+			return err
+		}
+		if authClientDeleteLocal {
+			output.Success(fmt.Sprintf("Client %s forgotten locally.", output.Bold(clientID)))
+		} else {
+			output.Success(fmt.Sprintf("Client %s deregistered.", output.Bold(clientID)))
+		}
+		return nil
+	},
+}
+
+// authStatusInfo is the result of `chp auth status`.
+type authStatusInfo struct {
+	Profile         string   `json:"profile"`
+	BaseURL         string   `json:"base_url"`
+	ServerReachable bool     `json:"server_reachable"`
+	ServerError     string   `json:"server_error,omitempty"`
+	LoggedIn        bool     `json:"logged_in"`
+	TokenType       string   `json:"token_type,omitempty"`
+	Subject         string   `json:"subject,omitempty"`
+	Issuer          string   `json:"issuer,omitempty"`
+	Scopes          []string `json:"scopes,omitempty"`
+	ExpiresAt       string   `json:"expires_at,omitempty"`
+	Expired         bool     `json:"expired"`
+	HasRefreshToken bool     `json:"has_refresh_token"`
+	DPoPThumbprint  string   `json:"dpop_jkt,omitempty"`
+	ClaimsNote      string   `json:"claims_note,omitempty"`
+}
+
+func authStatus(ctx context.Context, creds *auth.Credentials) authStatusInfo {
+	status := authStatusInfo{
+		Profile: auth.ActiveProfile(),
+		BaseURL: creds.GetBaseURL(),
+	}
+	status.ServerReachable, status.ServerError = checkReachable(ctx, status.BaseURL)
+
+	// GetToken loads the tokens from a credential helper, if one is set.
+	token, err := creds.GetToken()
+	status.HasRefreshToken = creds.OAuthRefreshToken != ""
+	if err != nil {
+		return status
+	}
+	status.LoggedIn = true
+	switch {
+	case auth.EnvToken() != "":
+		status.TokenType = auth.TokenEnvVar
+	case token == creds.OAuthAccessToken:
+		status.TokenType = "oauth"
+	default:
+		status.TokenType = "jwt"
+	}
+
+	if token == creds.OAuthAccessToken && creds.UsesDPoP() {
+		if key, err := creds.DPoP(); err == nil {
+			status.DPoPThumbprint = key.Thumbprint()
+		}
+	}
+
+	var expiresAt int64
+	if claims, err := auth.DecodeJWTClaims(token); err == nil {
+		status.Subject, _ = claims["sub"].(string)
+		status.Issuer, _ = claims["iss"].(string)
+		status.Scopes = auth.JWTScopes(claims)
+		if exp, ok := claims["exp"].(float64); ok {
+			expiresAt = int64(exp)
+		}
+	} else {
+		status.ClaimsNote = "access token is opaque; claims unavailable"
+	}
+	if expiresAt == 0 && status.TokenType == "oauth" {
+		expiresAt = creds.OAuthExpiresAt
+	}
+	if expiresAt > 0 {
+		status.ExpiresAt = time.Unix(expiresAt, 0).UTC().Format(time.RFC3339)
+		status.Expired = time.Now().Unix() >= expiresAt
+	}
+	return status
+}
+
+// checkReachable reports whether the server answers HTTP at all; any status
+// code counts, only connection failures don't.
+func checkReachable(ctx context.Context, baseURL string) (bool, string) {
+	_, err := httpclient.New().GetJSON(ctx, baseURL+"/.well-known/oauth-protected-resource", nil)
+	var apiErr *httpclient.APIError
+	if err == nil || (errors.As(err, &apiErr) && apiErr.StatusCode != 0) {
+		return true, ""
+	}
+	return false, strings.SplitN(err.Error(), "\n", 2)[0]
+}
+
+func printAuthStatus(s authStatusInfo) {
+	row := func(label, value string) {
+		fmt.Printf("%-15s %s\n", label+":", value)
+	}
+	row("Profile", output.Bold(s.Profile))
+	server := s.BaseURL + " (reachable)"
+	if !s.ServerReachable {
+		server = s.BaseURL + " (unreachable: " + s.ServerError + ")"
+	}
+	row("Server", server)
+	if !s.LoggedIn {
+		row("Logged in", "no — run: chp login")
+		return
+	}
+	row("Logged in", "yes ("+s.TokenType+" token)")
+	if s.ClaimsNote != "" {
+		row("Claims", output.Dim(s.ClaimsNote))
+	}
+	if s.Subject != "" {
+		row("Subject", s.Subject)
+	}
+	if s.Issuer != "" {
+		row("Issuer", s.Issuer)
+	}
+	if len(s.Scopes) > 0 {
+		row("Scopes", strings.Join(s.Scopes, " "))
+	}
+	if s.ExpiresAt != "" {
+		expiry := s.ExpiresAt
+		if s.Expired {
+			expiry += " (expired)"
+		}
+		row("Expires", expiry)
+	}
+	if s.DPoPThumbprint != "" {
+		row("Bound to", "DPoP key "+s.DPoPThumbprint)
+	}
+	refresh := "no"
+	if s.HasRefreshToken {
+		refresh = "yes"
+	}
+	row("Refresh token", refresh)
+}
+
+func init() {
+	authTokenCmd.Flags().BoolVar(&authTokenJSON, "json", false, "Print the token and its expiry as JSON")
+	authStatusCmd.Flags().BoolVar(&authStatusJSON, "json", false, "Print status as JSON")
+
+	authClientDeleteCmd.Flags().BoolVar(&authClientDeleteLocal, "local-only", false, "Forget the client without deregistering it at the server")
+
+	authClientCmd.AddCommand(authClientShowCmd)
+	authClientCmd.AddCommand(authClientDeleteCmd)
+
+	authCmd.AddCommand(authTokenCmd)
+	authCmd.AddCommand(authStatusCmd)
+	authCmd.AddCommand(authClientCmd)
+}
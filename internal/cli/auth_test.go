@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/lollipopai/cli/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthStatus_RefreshTokenFromHelper(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("credential helper test uses sh")
+	}
+	tmp := t.TempDir()
+	origDir, origFile, origHelper := auth.ConfigDir, auth.CredentialsFile, auth.CredentialHelper
+	t.Cleanup(func() {
+		auth.ConfigDir, auth.CredentialsFile, auth.CredentialHelper = origDir, origFile, origHelper
+	})
+	auth.ConfigDir = tmp
+	auth.CredentialsFile = filepath.Join(tmp, "credentials.json")
+	t.Setenv(auth.ProfileEnvVar, "")
+	t.Setenv(auth.TokenEnvVar, "")
+
+	// The helper holds the tokens; the credentials file has none.
+	helper := filepath.Join(tmp, "helper.sh")
+	require.NoError(t, os.WriteFile(helper, []byte("#!/bin/sh\ncat >/dev/null\n"+
+		"if [ \"$1\" = get ]; then printf 'access_token=helper-access\\nrefresh_token=helper-refresh\\n'; fi\n"), 0700))
+	auth.CredentialHelper = helper
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	require.NoError(t, auth.SaveCredentials(&auth.Credentials{BaseURL: srv.URL}))
+
+	status := authStatus(t.Context(), auth.LoadCredentials())
+	assert.True(t, status.LoggedIn)
+	assert.Equal(t, "oauth", status.TokenType)
+	assert.True(t, status.HasRefreshToken)
+}
@@ -1,31 +1,30 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
 
-	"github.com/lollipopai/cli/internal/output"
+	basketv1 "github.com/lollipopai/cli/pkg/cherrypick/basket/v1"
 	"github.com/spf13/cobra"
 )
 
-const basketService = "lollipop.proto.basket.v1.BasketV1"
-
-var basketQuantity int
+var basketQuantity int32
 
 var basketCmd = &cobra.Command{
 	Use:   "basket",
 	Short: "Basket commands",
-	Run: func(cmd *cobra.Command, args []string) {
-		runBasketShow()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBasketShow(cmd.Context())
 	},
 }
 
 var basketShowCmd = &cobra.Command{
 	Use:   "show",
 	Short: "Show current basket",
-	Run: func(cmd *cobra.Command, args []string) {
-		runBasketShow()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBasketShow(cmd.Context())
 	},
 }
 
@@ -33,19 +32,17 @@ var basketAddRecipeCmd = &cobra.Command{
 	Use:   "add-recipe <recipe-id>...",
 	Short: "Add one or more recipes to the basket",
 	Args:  cobra.MinimumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		caller := newTwirpCaller()
-		var result any
-		for _, id := range args {
-			var err error
-			result, err = caller.Call(basketService, "AddRecipe", map[string]any{
-				"recipe_id": id,
-			})
-			if err != nil {
-				output.Fatal(err.Error())
-			}
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+		if err != nil {
+			return err
 		}
-		output.PrintJSON(result)
+		basket := basketv1.NewBasketV1Client(client)
+		return runBatch(cmd.Context(), args, func(ctx context.Context, id string) (any, error) {
+			return rawResult(ctx, func(ctx context.Context) (any, error) {
+				return basket.AddRecipe(ctx, &basketv1.RecipeRequest{RecipeID: id})
+			})
+		})
 	},
 }
 
@@ -53,19 +50,17 @@ var basketRemoveRecipeCmd = &cobra.Command{
 	Use:   "remove-recipe <recipe-id>...",
 	Short: "Remove one or more recipes from the basket",
 	Args:  cobra.MinimumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		caller := newTwirpCaller()
-		var result any
-		for _, id := range args {
-			var err error
-			result, err = caller.Call(basketService, "RemoveRecipe", map[string]any{
-				"recipe_id": id,
-			})
-			if err != nil {
-				output.Fatal(err.Error())
-			}
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+		if err != nil {
+			return err
 		}
-		output.PrintJSON(result)
+		basket := basketv1.NewBasketV1Client(client)
+		return runBatch(cmd.Context(), args, func(ctx context.Context, id string) (any, error) {
+			return rawResult(ctx, func(ctx context.Context) (any, error) {
+				return basket.RemoveRecipe(ctx, &basketv1.RecipeRequest{RecipeID: id})
+			})
+		})
 	},
 }
 
@@ -83,26 +78,27 @@ Examples:
   chp basket add-product 7834128 7209381 -q 2      # -q sets default for all
   chp basket add-product 7834128:3 7209381 -q 2    # 7834128→3, 7209381→2`,
 	Args: cobra.MinimumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		defaultQty := basketQuantity
 		if defaultQty == 0 {
 			defaultQty = 1
 		}
-		caller := newTwirpCaller()
-		var result any
 		for _, arg := range args {
-			uid, qty := parseProductArg(arg, defaultQty)
-			payload := map[string]any{
-				"product_id": uid,
-				"quantity":   qty,
-			}
-			var err error
-			result, err = caller.Call(basketService, "AddProduct", payload)
-			if err != nil {
-				output.Fatal(err.Error())
+			if _, _, err := parseProductArg(arg, defaultQty); err != nil {
+				return err // reject bad quantities before adding anything
 			}
 		}
-		output.PrintJSON(result)
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		basket := basketv1.NewBasketV1Client(client)
+		return runBatch(cmd.Context(), args, func(ctx context.Context, arg string) (any, error) {
+			uid, qty, _ := parseProductArg(arg, defaultQty)
+			return rawResult(ctx, func(ctx context.Context) (any, error) {
+				return basket.AddProduct(ctx, &basketv1.ProductRequest{ProductID: uid, Quantity: qty})
+			})
+		})
 	},
 }
 
@@ -110,19 +106,17 @@ var basketRemoveProductCmd = &cobra.Command{
 	Use:   "remove-product <uid>...",
 	Short: "Remove one or more products from the basket by Sainsbury's product UID",
 	Args:  cobra.MinimumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		caller := newTwirpCaller()
-		var result any
-		for _, uid := range args {
-			var err error
-			result, err = caller.Call(basketService, "RemoveProduct", map[string]any{
-				"product_id": uid,
-			})
-			if err != nil {
-				output.Fatal(err.Error())
-			}
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+		if err != nil {
+			return err
 		}
-		output.PrintJSON(result)
+		basket := basketv1.NewBasketV1Client(client)
+		return runBatch(cmd.Context(), args, func(ctx context.Context, uid string) (any, error) {
+			return rawResult(ctx, func(ctx context.Context) (any, error) {
+				return basket.RemoveProduct(ctx, &basketv1.ProductRequest{ProductID: uid})
+			})
+		})
 	},
 }
 
@@ -130,61 +124,61 @@ var basketSetQuantityCmd = &cobra.Command{
 	Use:   "set-quantity <uid> <qty>",
 	Short: "Set the quantity of a product in the basket by Sainsbury's product UID",
 	Args:  cobra.ExactArgs(2),
-	Run: func(cmd *cobra.Command, args []string) {
-		qty, err := strconv.Atoi(args[1])
+	RunE: func(cmd *cobra.Command, args []string) error {
+		qty, err := strconv.ParseInt(args[1], 10, 32)
 		if err != nil {
-			output.Fatal(fmt.Sprintf("invalid quantity %q: must be a number", args[1]))
+			return fmt.Errorf("invalid quantity %q: must be a number", args[1])
 		}
-		caller := newTwirpCaller()
-		result, err := caller.Call(basketService, "SetQuantity", map[string]any{
-			"product_id": args[0],
-			"quantity":   qty,
-		})
+		client, err := newClient()
 		if err != nil {
-			output.Fatal(err.Error())
+			return err
 		}
-		output.PrintJSON(result)
+		return printResult(rawResult(cmd.Context(), func(ctx context.Context) (any, error) {
+			return basketv1.NewBasketV1Client(client).SetQuantity(ctx, &basketv1.ProductRequest{ProductID: args[0], Quantity: int32(qty)})
+		}))
 	},
 }
 
 var basketClearCmd = &cobra.Command{
 	Use:   "clear",
 	Short: "Clear the basket",
-	Run: func(cmd *cobra.Command, args []string) {
-		caller := newTwirpCaller()
-		result, err := caller.Call(basketService, "Clear", nil)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
 		if err != nil {
-			output.Fatal(err.Error())
+			return err
 		}
-		output.PrintJSON(result)
+		return printResult(rawResult(cmd.Context(), func(ctx context.Context) (any, error) {
+			return basketv1.NewBasketV1Client(client).Clear(ctx, nil)
+		}))
 	},
 }
 
 // parseProductArg splits "uid:qty" into uid and quantity.
 // If no colon is present, defaultQty is used.
-func parseProductArg(arg string, defaultQty int) (string, int) {
+func parseProductArg(arg string, defaultQty int32) (string, int32, error) {
 	parts := strings.SplitN(arg, ":", 2)
 	if len(parts) == 2 {
-		qty, err := strconv.Atoi(parts[1])
+		qty, err := strconv.ParseInt(parts[1], 10, 32)
 		if err != nil {
-			output.Fatal(fmt.Sprintf("invalid quantity in %q: %s", arg, err))
+			return "", 0, fmt.Errorf("invalid quantity in %q: %s", arg, err)
 		}
-		return parts[0], qty
+		return parts[0], int32(qty), nil
 	}
-	return arg, defaultQty
+	return arg, defaultQty, nil
 }
 
-func runBasketShow() {
-	caller := newTwirpCaller()
-	result, err := caller.Call(basketService, "Show", nil)
+func runBasketShow(ctx context.Context) error {
+	client, err := newClient()
 	if err != nil {
-		output.Fatal(err.Error())
+		return err
 	}
-	output.PrintJSON(result)
+	return printResult(rawResult(ctx, func(ctx context.Context) (any, error) {
+		return basketv1.NewBasketV1Client(client).Show(ctx, nil)
+	}))
 }
 
 func init() {
-	basketAddProductCmd.Flags().IntVarP(&basketQuantity, "quantity", "q", 0, "Default quantity for items without :qty suffix (default: 1)")
+	basketAddProductCmd.Flags().Int32VarP(&basketQuantity, "quantity", "q", 0, "Default quantity for items without :qty suffix (default: 1)")
 	basketCmd.AddCommand(basketShowCmd)
 	basketCmd.AddCommand(basketAddRecipeCmd)
 	basketCmd.AddCommand(basketRemoveRecipeCmd)
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lollipopai/cli/internal/httpclient"
+	"github.com/lollipopai/cli/internal/output"
+)
+
+// runBatch calls apply for each item in order and prints the last result.
+// If an item fails, or the user interrupts, the items applied so far are
+// reported before returning the error, so a half-applied batch can be
+// finished by hand.
+func runBatch(ctx context.Context, items []string, apply func(ctx context.Context, item string) (any, error)) error {
+	var result any
+	for i, item := range items {
+		var err error
+		result, err = apply(ctx, item)
+		if err != nil {
+			if len(items) > 1 {
+				output.Warn(batchSummary(items[:i], item, items[i+1:], err))
+			}
+			return err
+		}
+	}
+	output.PrintJSON(result)
+	return nil
+}
+
+// batchSummary describes how far a batch got. An item whose request was cut
+// off may or may not have been applied by the server.
+func batchSummary(done []string, current string, pending []string, err error) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Stopped after %d of %d items.", len(done), len(done)+1+len(pending))
+	row := func(label string, items ...string) {
+		if len(items) > 0 {
+			fmt.Fprintf(&b, "\n  %-12s %s", label, strings.Join(items, " "))
+		}
+	}
+	row("Applied:", done...)
+	var apiErr *httpclient.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode != 0 {
+		row("Failed:", current)
+	} else {
+		row("Unknown:", current+" (the request was cut off; check before retrying)")
+	}
+	row("Not started:", pending...)
+	return b.String()
+}
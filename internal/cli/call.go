@@ -5,12 +5,17 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/lollipopai/cli/internal/config"
 	"github.com/lollipopai/cli/internal/output"
+	"github.com/lollipopai/cli/internal/twirp"
 	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 const twirpServicePrefix = "lollipop.proto."
 
+var callList bool
+
 var callCmd = &cobra.Command{
 	Use:   "call <service> <method> [payload]",
 	Short: "Make a raw Twirp RPC call",
@@ -18,33 +23,251 @@ var callCmd = &cobra.Command{
 
 The lollipop.proto. prefix is added automatically.
 
+Use --list to see the services and methods, and "chp call describe" for
+their request and response fields. Both, and tab completion of service and
+method names, use the descriptors in the descriptor-set setting if
+configured, else those built into chp, which are written by hand and give
+field names and types but not field numbers.
+
 Examples:
+  chp call --list
+  chp call describe basket.v1.BasketV1 AddProduct
   chp call recipe.v1.RecipeV1 Search '{"query":"curry"}'
   chp call user.v1.UserV1 Current
   chp call basket.v1.BasketV1 Show
   chp call product.v2.ProductV2 Search '{"keyword":"eggs"}'
   chp call slot.v1.SlotV1 List
   chp call plan.v1.PlanV1 Show`,
-	Args: cobra.RangeArgs(2, 3),
-	Run: func(cmd *cobra.Command, args []string) {
-		service := args[0]
-		if !strings.HasPrefix(service, twirpServicePrefix) {
-			service = twirpServicePrefix + service
+	Args: func(cmd *cobra.Command, args []string) error {
+		if callList {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.RangeArgs(2, 3)(cmd, args)
+	},
+	ValidArgsFunction: completeServiceMethod,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if callList {
+			return runCallList()
 		}
+
+		service := fullServiceName(args[0])
 		method := args[1]
 
 		var payload any
 		if len(args) == 3 {
 			if err := json.Unmarshal([]byte(args[2]), &payload); err != nil {
-				output.Fatal(fmt.Sprintf("Invalid JSON payload: %v", err))
+				return fmt.Errorf("invalid JSON payload: %v", err)
 			}
 		}
 
-		caller := newTwirpCaller()
-		result, err := caller.Call(service, method, payload)
+		client, err := newClient()
 		if err != nil {
-			output.Fatal(err.Error())
+			return err
 		}
-		output.PrintJSON(result)
+		return printResult(client.Call(cmd.Context(), service, method, payload))
 	},
 }
+
+var callDescribeCmd = &cobra.Command{
+	Use:   "describe <service> [method]",
+	Short: "Show a service's methods, or a method's request and response fields",
+	Long: `Show a service's methods, or a method's request and response messages,
+in .proto syntax. Payloads for "chp call" use these field names. Field
+numbers are shown only with the API's own descriptors (the descriptor-set
+setting): the ones built into chp don't know them.
+
+Examples:
+  chp call describe basket.v1.BasketV1
+  chp call describe basket.v1.BasketV1 AddProduct`,
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completeServiceMethod,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		schema, err := callSchema()
+		if err != nil {
+			return err
+		}
+		sd, ok := schema.Service(fullServiceName(args[0]))
+		if !ok {
+			return fmt.Errorf("service %s is not in the descriptors (see chp call --list, or set descriptor-set to the API's own descriptors)", args[0])
+		}
+
+		var b strings.Builder
+		if len(args) == 1 {
+			var methods []protoreflect.MethodDescriptor
+			for i := range sd.Methods().Len() {
+				methods = append(methods, sd.Methods().Get(i))
+			}
+			writeService(&b, sd, methods...)
+		} else {
+			md := sd.Methods().ByName(protoreflect.Name(args[1]))
+			if md == nil {
+				return fmt.Errorf("%s has no method %s (see chp call describe %s)", args[0], args[1], args[0])
+			}
+			writeService(&b, sd, md)
+			seen := map[protoreflect.FullName]bool{}
+			numbers := !schema.Bundled()
+			writeMessage(&b, md.Input(), sd.ParentFile().Package(), numbers, seen)
+			writeMessage(&b, md.Output(), sd.ParentFile().Package(), numbers, seen)
+		}
+		fmt.Print(b.String())
+		return nil
+	},
+}
+
+// callSchema returns the descriptors describing the API: those in the
+// descriptor-set setting if configured, else those built into chp.
+func callSchema() (*twirp.Schema, error) {
+	if path := config.Load().DescriptorSet; path != "" {
+		return twirp.LoadSchema(path)
+	}
+	return twirp.BundledSchema()
+}
+
+func runCallList() error {
+	schema, err := callSchema()
+	if err != nil {
+		return err
+	}
+	for _, sd := range schema.Services() {
+		fmt.Println(output.Bold(shortServiceName(sd.FullName())))
+		pkg := sd.ParentFile().Package()
+		for i := range sd.Methods().Len() {
+			md := sd.Methods().Get(i)
+			fmt.Printf("  %-16s %s\n", md.Name(), output.Dim(fmt.Sprintf("(%s) returns (%s)", relativeName(md.Input().FullName(), pkg), relativeName(md.Output().FullName(), pkg))))
+		}
+	}
+	return nil
+}
+
+// completeServiceMethod completes a service name, then one of its methods.
+func completeServiceMethod(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	schema, err := callSchema()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var names []string
+	switch len(args) {
+	case 0:
+		for _, sd := range schema.Services() {
+			name := string(sd.FullName())
+			if !strings.HasPrefix(toComplete, twirpServicePrefix) {
+				name = shortServiceName(sd.FullName())
+			}
+			if strings.HasPrefix(name, toComplete) {
+				names = append(names, name)
+			}
+		}
+	case 1:
+		sd, ok := schema.Service(fullServiceName(args[0]))
+		if !ok {
+			break
+		}
+		pkg := sd.ParentFile().Package()
+		for i := range sd.Methods().Len() {
+			md := sd.Methods().Get(i)
+			if strings.HasPrefix(string(md.Name()), toComplete) {
+				names = append(names, fmt.Sprintf("%s\t%s → %s", md.Name(), relativeName(md.Input().FullName(), pkg), relativeName(md.Output().FullName(), pkg)))
+			}
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// fullServiceName adds the lollipop.proto. prefix to a service name without
+// it.
+func fullServiceName(service string) string {
+	if !strings.HasPrefix(service, twirpServicePrefix) {
+		return twirpServicePrefix + service
+	}
+	return service
+}
+
+// shortServiceName drops the lollipop.proto. prefix, as chp call accepts.
+func shortServiceName(name protoreflect.FullName) string {
+	return strings.TrimPrefix(string(name), twirpServicePrefix)
+}
+
+// relativeName names a message or enum as a .proto file in package pkg
+// would refer to it.
+func relativeName(name, pkg protoreflect.FullName) string {
+	if rest, ok := strings.CutPrefix(string(name), string(pkg)+"."); ok {
+		return rest
+	}
+	return shortServiceName(name)
+}
+
+// writeService writes a service with the given methods in .proto syntax.
+func writeService(b *strings.Builder, sd protoreflect.ServiceDescriptor, methods ...protoreflect.MethodDescriptor) {
+	pkg := sd.ParentFile().Package()
+	fmt.Fprintf(b, "service %s {\n", shortServiceName(sd.FullName()))
+	for _, md := range methods {
+		fmt.Fprintf(b, "  rpc %s(%s) returns (%s);\n", md.Name(), relativeName(md.Input().FullName(), pkg), relativeName(md.Output().FullName(), pkg))
+	}
+	fmt.Fprintf(b, "}\n")
+}
+
+// writeMessage writes a message in .proto syntax, then the messages and
+// enums its fields use, each once. Field numbers are left out unless
+// numbers is set.
+func writeMessage(b *strings.Builder, md protoreflect.MessageDescriptor, pkg protoreflect.FullName, numbers bool, seen map[protoreflect.FullName]bool) {
+	if seen[md.FullName()] {
+		return
+	}
+	seen[md.FullName()] = true
+
+	fmt.Fprintf(b, "\nmessage %s {\n", relativeName(md.FullName(), pkg))
+	var used []protoreflect.FieldDescriptor
+	for i := range md.Fields().Len() {
+		f := md.Fields().Get(i)
+		if numbers {
+			fmt.Fprintf(b, "  %s %s = %d;\n", fieldType(f, pkg), f.Name(), f.Number())
+		} else {
+			fmt.Fprintf(b, "  %s %s;\n", fieldType(f, pkg), f.Name())
+		}
+		if f.IsMap() {
+			f = f.MapValue()
+		}
+		used = append(used, f)
+	}
+	fmt.Fprintf(b, "}\n")
+
+	for _, f := range used {
+		switch {
+		case f.Message() != nil:
+			writeMessage(b, f.Message(), pkg, numbers, seen)
+		case f.Enum() != nil && !seen[f.Enum().FullName()]:
+			seen[f.Enum().FullName()] = true
+			fmt.Fprintf(b, "\nenum %s {\n", relativeName(f.Enum().FullName(), pkg))
+			for i := range f.Enum().Values().Len() {
+				v := f.Enum().Values().Get(i)
+				fmt.Fprintf(b, "  %s = %d;\n", v.Name(), v.Number())
+			}
+			fmt.Fprintf(b, "}\n")
+		}
+	}
+}
+
+// fieldType writes a field's type as a .proto file in package pkg would.
+func fieldType(f protoreflect.FieldDescriptor, pkg protoreflect.FullName) string {
+	if f.IsMap() {
+		return fmt.Sprintf("map<%s, %s>", fieldType(f.MapKey(), pkg), fieldType(f.MapValue(), pkg))
+	}
+	var t string
+	switch {
+	case f.Message() != nil:
+		t = relativeName(f.Message().FullName(), pkg)
+	case f.Enum() != nil:
+		t = relativeName(f.Enum().FullName(), pkg)
+	default:
+		t = f.Kind().String()
+	}
+	if f.IsList() {
+		return "repeated " + t
+	}
+	return t
+}
+
+func init() {
+	callCmd.Flags().BoolVar(&callList, "list", false, "List the services and methods that can be called")
+	callCmd.AddCommand(callDescribeCmd)
+}
@@ -1,11 +1,13 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"net/url"
 	"time"
 
 	"github.com/lollipopai/cli/internal/auth"
+	"github.com/lollipopai/cli/internal/config"
 	"github.com/lollipopai/cli/internal/output"
 	"github.com/spf13/cobra"
 )
@@ -19,7 +21,7 @@ var configSetURLCmd = &cobra.Command{
 	Use:   "set-url <url>",
 	Short: "Set the base API URL",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		rawURL := args[0]
 		// Strip trailing slash
 		for len(rawURL) > 0 && rawURL[len(rawURL)-1] == '/' {
@@ -28,36 +30,120 @@ var configSetURLCmd = &cobra.Command{
 
 		parsed, err := url.Parse(rawURL)
 		if err != nil {
-			output.Fatal(fmt.Sprintf("Invalid URL: %v", err))
+			return fmt.Errorf("Invalid URL: %v", err)
 		}
 		if parsed.Scheme != "http" && parsed.Scheme != "https" {
-			output.Fatal("Base URL must use http or https scheme.")
+			return errors.New("Base URL must use http or https scheme.")
 		}
 		if parsed.Hostname() == "" {
-			output.Fatal("Base URL must include a hostname.")
+			return errors.New("Base URL must include a hostname.")
 		}
 
 		creds := auth.LoadCredentials()
 		creds.BaseURL = rawURL
+		creds.OAuthServer = nil // discovered for the old URL
 		if err := auth.SaveCredentials(creds); err != nil {
-			output.Fatal(err.Error())
+			return err
 		}
 		output.Success(fmt.Sprintf("Base URL set to %s", output.Bold(rawURL)))
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set a configuration value",
+	Long: `Set a configuration value in ~/.chp/config.json.
+
+Keys:
+  credential-store   Where tokens are stored: file (plaintext, default),
+                     encrypted-file (passphrase-protected) or keyring
+                     (Secret Service on Linux, Keychain on macOS).
+                     Existing credentials are moved to the new store.
+  credential-helper  External command that owns tokens, modelled on git
+                     credential helpers. Run as "<command> get|store|erase"
+                     with key=value lines on stdin/stdout. Set to "" to
+                     disable.
+  retries            How many times to retry a request that failed with a
+                     connection error, 429 or 5xx (default 3). Only reads
+                     and idempotent writes are retried. Overridden by
+                     --retries.
+  ca-file            PEM bundle of CAs to trust in addition to the system
+                     roots, e.g. for an internal gateway. Overridden by
+                     --ca-file and $CHP_CA_FILE.
+  client-cert        PEM client certificate sent for mTLS. Overridden by
+                     --client-cert and $CHP_CLIENT_CERT.
+  client-key         PEM private key for client-cert, if it is not in the
+                     same file. Overridden by --client-key and
+                     $CHP_CLIENT_KEY.
+  wire-format        json (default) or protobuf: how Twirp requests and
+                     responses are encoded. protobuf requires
+                     descriptor-set; it is smaller and faster to parse for
+                     large responses, and output is JSON either way.
+                     Overridden by --wire-format.
+  descriptor-set     Binary FileDescriptorSet (buf build -o, or protoc
+                     --include_imports --descriptor_set_out) of the API's
+                     services. Required by wire-format protobuf, and used by
+                     chp call --list and describe instead of the descriptors
+                     built into chp.
+
+Examples:
+  chp config set credential-store encrypted-file
+  chp config set credential-store keyring
+  chp config set credential-helper "vault-chp --path secret/chp"
+  chp config set retries 5
+  chp config set ca-file ~/certs/gateway-ca.pem
+  chp config set descriptor-set cherrypick.binpb
+  chp config set wire-format protobuf`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, value := args[0], args[1]
+		cfg := config.Load()
+		previous, err := cfg.Get(key)
+		if err != nil {
+			return err
+		}
+		if err := cfg.Set(key, value); err != nil {
+			return err
+		}
+
+		if key == "credential-store" {
+			if err := auth.MigrateCredentialStore(previous, value); err != nil {
+				return err
+			}
+		}
+
+		if err := config.Save(cfg); err != nil {
+			return err
+		}
+		if value == "" {
+			output.Success(fmt.Sprintf("%s unset", key))
+			return nil
+		}
+		output.Success(fmt.Sprintf("%s set to %s", key, output.Bold(value)))
+		return nil
 	},
 }
 
 var configShowCmd = &cobra.Command{
 	Use:   "show",
 	Short: "Show current configuration",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		creds := auth.LoadCredentials()
 
 		config := map[string]any{
-			"base_url":         creds.GetBaseURL(),
-			"credentials_file": auth.CredentialsFile,
-			"has_jwt":          creds.JWT != "",
-			"has_oauth_token":  creds.OAuthAccessToken != "",
-			"oauth_client_id":  nilIfEmpty(creds.OAuthClientID),
+			"profile":           auth.ActiveProfile(),
+			"base_url":          creds.GetBaseURL(),
+			"credentials_file":  auth.CredentialsFile,
+			"credential_store":  auth.CredentialStore(),
+			"credential_helper": nilIfEmpty(auth.CredentialHelper),
+			"has_jwt":           creds.JWT != "",
+			"has_oauth_token":   creds.OAuthAccessToken != "",
+			"oauth_client_id":   nilIfEmpty(creds.OAuthClientID),
+		}
+
+		if creds.OAuthServer != nil {
+			config["oauth_issuer"] = creds.OAuthServer.Issuer
 		}
 
 		if creds.OAuthExpiresAt > 0 {
@@ -70,6 +156,7 @@ var configShowCmd = &cobra.Command{
 		}
 
 		output.PrintJSON(config)
+		return nil
 	},
 }
 
@@ -81,6 +168,7 @@ func nilIfEmpty(s string) any {
 }
 
 func init() {
+	configCmd.AddCommand(configSetCmd)
 	configCmd.AddCommand(configSetURLCmd)
 	configCmd.AddCommand(configShowCmd)
 }
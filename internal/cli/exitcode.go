@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/lollipopai/cli/internal/output"
+	"github.com/lollipopai/cli/internal/twirp"
+)
+
+// twirpFailure is how a command reports a Twirp error code: a distinct exit
+// status scripts can branch on, and a hint for the person at the terminal.
+type twirpFailure struct {
+	exit int
+	hint string
+}
+
+// twirpFailures maps each Twirp error code to its exit status (10-27, in the
+// order of the Twirp spec) and hint. Keep in sync with the README.
+var twirpFailures = map[twirp.ErrorCode]twirpFailure{
+	twirp.Canceled:           {10, "The server cancelled the request. Try again."},
+	twirp.Unknown:            {11, "The server returned an unexpected error. Try again, or run with --debug for details."},
+	twirp.InvalidArgument:    {12, "Check the arguments and flags; see --help for this command."},
+	twirp.Malformed:          {13, "The server could not parse the request. Check the payload, or update chp."},
+	twirp.DeadlineExceeded:   {14, "The server took too long to respond. Try again later."},
+	twirp.NotFound:           {15, "Check the ID or slug; list commands show valid values."},
+	twirp.BadRoute:           {16, "The server does not support this method. Check the base URL, or update chp."},
+	twirp.AlreadyExists:      {17, "It already exists; no change was made."},
+	twirp.PermissionDenied:   {18, "Your account is not allowed to do this. Check the active profile with: chp auth status"},
+	twirp.Unauthenticated:    {19, "Your session has expired or was revoked. Try: chp login"},
+	twirp.ResourceExhausted:  {20, "Rate limited or over quota. Wait a moment and try again."},
+	twirp.FailedPrecondition: {21, "The request doesn't fit the current state (e.g. an empty basket). Check it and try again."},
+	twirp.Aborted:            {22, "The request conflicted with another change. Try again."},
+	twirp.OutOfRange:         {23, "A value is out of range (e.g. a page or quantity). Check the arguments."},
+	twirp.Unimplemented:      {24, "The server does not implement this yet. Check the base URL, or update chp."},
+	twirp.Internal:           {25, "The server hit an internal error. Try again, or run with --debug and report it."},
+	twirp.Unavailable:        {26, "The service is temporarily unavailable. Try again later, or raise --retries."},
+	twirp.DataLoss:           {27, "The server reported data loss. Report this with the output of --debug."},
+}
+
+// failureFor returns the exit status and hint for a Twirp error code. Codes
+// not in the spec are treated as unknown.
+func failureFor(code twirp.ErrorCode) twirpFailure {
+	if f, ok := twirpFailures[code]; ok {
+		return f
+	}
+	return twirpFailures[twirp.Unknown]
+}
+
+// exitTwirpError prints a Twirp error with its hint and exits with the
+// code's status.
+func exitTwirpError(twErr *twirp.Error) {
+	f := failureFor(twErr.Code)
+	output.Error(twErr.Error())
+	if f.hint != "" {
+		fmt.Fprintln(os.Stderr, "  "+output.Dim(f.hint))
+	}
+	os.Exit(f.exit)
+}
+
+// exitCode is returned by a command that has already reported its outcome
+// and only needs to exit with a status, e.g. 1 from "chp auth status" when
+// logged out.
+type exitCode int
+
+func (c exitCode) Error() string {
+	return fmt.Sprintf("exit status %d", int(c))
+}
@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/lollipopai/cli/internal/httpclient"
+	"github.com/lollipopai/cli/pkg/cherrypick"
+	productv2 "github.com/lollipopai/cli/pkg/cherrypick/product/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExitStatus_ReplayedErrorMatchesLive(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(404)
+		w.Write([]byte(`{"code":"not_found","msg":"no such product"}`))
+	}))
+	cassette := filepath.Join(t.TempDir(), "cassette.json")
+
+	call := func() *cherrypick.Error {
+		t.Helper()
+		client, err := cherrypick.New(cherrypick.WithBaseURL(srv.URL), cherrypick.WithTokenSource(cherrypick.StaticToken("tok")), cherrypick.WithRetries(0))
+		require.NoError(t, err)
+		_, err = client.Call(t.Context(), productv2.ProductV2Path, "Get", &productv2.GetRequest{ID: "404"})
+		var twErr *cherrypick.Error
+		require.ErrorAs(t, err, &twErr)
+		return twErr
+	}
+
+	t.Setenv(httpclient.RecordEnvVar, cassette)
+	live := call()
+	srv.Close()
+
+	t.Setenv(httpclient.RecordEnvVar, "")
+	t.Setenv(httpclient.ReplayEnvVar, cassette)
+	replayed := call()
+
+	assert.Equal(t, 15, failureFor(live.Code).exit)
+	assert.Equal(t, failureFor(live.Code).exit, failureFor(replayed.Code).exit, "replay exits as the live call did")
+	assert.Equal(t, live.Error(), replayed.Error())
+}
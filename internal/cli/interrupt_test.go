@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lollipopai/cli/internal/auth"
+	"github.com/lollipopai/cli/pkg/cherrypick"
+	userv1 "github.com/lollipopai/cli/pkg/cherrypick/user/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterrupt_WaitsForTokenRefresh(t *testing.T) {
+	tmp := t.TempDir()
+	origDir, origFile, origGrace, origExit := auth.ConfigDir, auth.CredentialsFile, interruptGrace, exit
+	t.Cleanup(func() {
+		auth.ConfigDir, auth.CredentialsFile, interruptGrace, exit = origDir, origFile, origGrace, origExit
+	})
+	auth.ConfigDir = tmp
+	auth.CredentialsFile = filepath.Join(tmp, "credentials.json")
+	t.Setenv(auth.ProfileEnvVar, "")
+	t.Setenv(auth.TokenEnvVar, "")
+
+	// The token endpoint rotates the refresh token, then answers slowly.
+	refreshing := make(chan struct{})
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/oauth/token" {
+			w.WriteHeader(404)
+			return
+		}
+		close(refreshing)
+		<-release
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "new-access", "refresh_token": "new-refresh", "expires_in": 3600})
+	}))
+	defer srv.Close()
+	var releaseOnce sync.Once
+	releaseToken := func() { releaseOnce.Do(func() { close(release) }) }
+	defer releaseToken()
+	require.NoError(t, auth.SaveProfile(auth.DefaultProfile, &auth.Credentials{
+		BaseURL:           srv.URL,
+		OAuthAccessToken:  "old-access",
+		OAuthRefreshToken: "old-refresh",
+		OAuthExpiresAt:    1,
+		OAuthClientID:     "client-123",
+	}))
+
+	interruptGrace = 0
+	exited := make(chan int, 1)
+	exit = func(code int) { exited <- code }
+
+	ctx, interrupt := context.WithCancel(t.Context())
+	go exitAfterInterrupt(ctx, func() {})
+	client, err := cherrypick.New(cherrypick.WithBaseURL(srv.URL), cherrypick.WithRetries(0))
+	require.NoError(t, err)
+	go userv1.NewUserV1Client(client).Current(ctx, nil)
+
+	<-refreshing
+	interrupt()
+	select {
+	case <-exited:
+		t.Fatal("exited while the refresh was in flight")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	releaseToken()
+	assert.Equal(t, 130, <-exited)
+	assert.Equal(t, "new-refresh", auth.LoadProfile(auth.DefaultProfile).OAuthRefreshToken, "the rotated refresh token was saved")
+}
@@ -1,70 +1,225 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/lollipopai/cli/internal/auth"
 	"github.com/lollipopai/cli/internal/httpclient"
 	"github.com/lollipopai/cli/internal/output"
+	"github.com/lollipopai/cli/internal/twirp"
 	"github.com/pkg/browser"
 	"github.com/spf13/cobra"
 )
 
+var (
+	loginDevice    bool
+	loginPort      int
+	loginWithToken bool
+	loginDPoP      bool
+	loginScopes    []string
+)
+
 var loginCmd = &cobra.Command{
 	Use:   "login",
 	Short: "Sign in to Cherrypick via OAuth",
-	Run: func(cmd *cobra.Command, args []string) {
-		runLogin()
+	Long: `Sign in to Cherrypick via OAuth.
+
+By default a browser is opened and the redirect is caught on a local callback
+server bound to an ephemeral loopback port (use --port to pin one). If the
+server supports pushed authorization requests (PAR), the request parameters
+are sent to it directly instead of through the browser URL. Use
+--device on machines without a browser (SSH sessions, CI runners):
+a code is printed which you enter on any other device.
+
+With --dpop the tokens are bound to a key generated for this login (RFC 9449
+DPoP): every request carries a proof signed with the key, so a copied token
+is useless without it.
+
+With --with-token no browser or device is involved: an access token is read
+from stdin, checked against the API and stored. Either pipe the bare token,
+or key=value lines with access_token and optionally refresh_token, client_id
+and expires_at (Unix seconds).
+
+Examples:
+  chp login                              Sign in via the browser
+  chp login --device                     Sign in from another device
+  chp login --dpop                       Sign in with DPoP-bound tokens
+  chp login --scope read                 Request read-only access
+  echo "$TOKEN" | chp login --with-token Store a token from CI secrets`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if loginWithToken {
+			return runLoginWithToken(cmd.Context())
+		}
+		return runLogin(cmd.Context())
 	},
 }
 
-func runLogin() {
+// runLoginWithToken stores tokens read from stdin after checking them
+// against the API.
+func runLoginWithToken(ctx context.Context) error {
+	if auth.EnvToken() != "" {
+		return fmt.Errorf("%s is set and takes precedence over stored credentials. Unset it to store a token.", auth.TokenEnvVar)
+	}
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("Failed to read token from stdin: %v", err)
+	}
+	supplied, err := auth.ParseTokenInput(data)
+	if err != nil {
+		return fmt.Errorf("Invalid token input: %v", err)
+	}
+
 	creds := auth.LoadCredentials()
 	baseURL := creds.GetBaseURL()
 	client := httpclient.New()
 
-	output.Info(fmt.Sprintf("Starting OAuth login for %s...", baseURL))
+	output.Info(fmt.Sprintf("Validating token against %s (profile %s)...", baseURL, auth.ActiveProfile()))
+	if _, err := twirp.CallWithToken(ctx, client, baseURL, "lollipop.proto.user.v1.UserV1", "Current", nil, supplied.OAuthAccessToken); ctx.Err() != nil {
+		return ctx.Err()
+	} else if err != nil {
+		return fmt.Errorf("Token rejected: %v", err)
+	}
+
+	// Tokens and DPoP key left over from an earlier login belong to another
+	// session; a pasted token is always a bearer token.
+	creds.DPoPPrivateKey = ""
+	creds.OAuthTokenType = ""
+	creds.OAuthAccessToken = supplied.OAuthAccessToken
+	creds.OAuthRefreshToken = supplied.OAuthRefreshToken
+	creds.OAuthExpiresAt = supplied.OAuthExpiresAt
+	if supplied.OAuthClientID != "" {
+		creds.OAuthClientID = supplied.OAuthClientID
+	}
+	creds.BaseURL = baseURL
+	if err := auth.SaveCredentials(creds); err != nil {
+		return fmt.Errorf("Failed to save credentials: %v", err)
+	}
+
+	output.Success("Token validated and saved.")
+	if creds.OAuthRefreshToken == "" {
+		output.Info("No refresh token supplied; run this again when the token expires.")
+	}
+	return nil
+}
+
+func runLogin(ctx context.Context) error {
+	creds := auth.LoadCredentials()
+	baseURL := creds.GetBaseURL()
+	client := httpclient.New()
+
+	output.Info(fmt.Sprintf("Starting OAuth login for %s (profile %s)...", baseURL, auth.ActiveProfile()))
 
 	// Step 1: Discover OAuth configuration
-	config, err := auth.DiscoverOAuthConfig(client, baseURL)
+	config, err := auth.DiscoverOAuthConfig(ctx, client, baseURL)
 	if err != nil {
-		output.Fatal(err.Error())
+		return err
 	}
+	creds.OAuthServer = config
+	dpop, err := setupLoginDPoP(creds, config)
+	if err != nil {
+		return err
+	}
+	scope := loginScope(config)
 
-	// Step 2: Register client if needed
-	clientID := creds.OAuthClientID
-	if clientID == "" {
-		output.Info("Registering CLI client...")
-		clientID, err = auth.RegisterClient(client, config.RegistrationEndpoint)
+	if loginDevice {
+		if config.DeviceAuthorizationEndpoint == "" {
+			return errors.New("Server does not advertise a device_authorization_endpoint. Use chp login without --device.")
+		}
+		if creds.OAuthClientID == "" {
+			if err := registerLoginClient(ctx, client, config, creds); err != nil {
+				return err
+			}
+		}
+		tokenResp, err := withClientRetry(ctx, client, config, creds, func() (map[string]any, error) {
+			return authorizeDevice(ctx, client, config, creds.OAuthClientID, baseURL, scope, dpop)
+		})
 		if err != nil {
-			output.Fatal(err.Error())
+			return err
+		}
+		return saveLoginTokens(creds, tokenResp, baseURL)
+	}
+
+	// Step 2: Start callback server
+	callback, err := auth.StartCallbackServer(loginPort)
+	if err != nil {
+		return err
+	}
+	defer callback.Shutdown()
+
+	// Step 3: Register client if needed. Clients registered before ephemeral
+	// ports were supported only accept the old fixed redirect URI.
+	if !creds.ClientAcceptsRedirect(callback.RedirectURI) {
+		if err := registerLoginClient(ctx, client, config, creds); err != nil {
+			return err
+		}
+	}
+
+	// Steps 4-8: Authorize in the browser and exchange the code
+	tokenResp, err := withClientRetry(ctx, client, config, creds, func() (map[string]any, error) {
+		return authorizeInBrowser(ctx, client, config, callback, creds.OAuthClientID, baseURL, scope, dpop)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Step 9: Save tokens
+	return saveLoginTokens(creds, tokenResp, baseURL)
+}
+
+// withClientRetry runs an authorization flow. If the server rejects the
+// stored client (it may purge dynamically registered clients), a new client
+// is registered and the flow runs once more.
+func withClientRetry(ctx context.Context, client *httpclient.Client, config *auth.OAuthConfig, creds *auth.Credentials, flow func() (map[string]any, error)) (map[string]any, error) {
+	tokenResp, err := flow()
+	if errors.Is(err, auth.ErrInvalidClient) {
+		output.Warn("The server rejected the stored OAuth client; registering a new one.")
+		if err := registerLoginClient(ctx, client, config, creds); err != nil {
+			return nil, err
 		}
-		creds.OAuthClientID = clientID
-		auth.SaveCredentials(creds)
-		output.Success(fmt.Sprintf("Client registered: %s", clientID))
+		tokenResp, err = flow()
 	}
+	return tokenResp, err
+}
 
-	// Step 3: Generate PKCE values
+// authorizeInBrowser runs the authorization code flow with PKCE through the
+// browser and returns the token response.
+func authorizeInBrowser(ctx context.Context, client *httpclient.Client, config *auth.OAuthConfig, callback *auth.CallbackServer, clientID, baseURL, scope string, dpop *auth.DPoPKey) (map[string]any, error) {
+	// Step 4: Generate PKCE values
 	verifier, err := auth.GenerateCodeVerifier()
 	if err != nil {
-		output.Fatal(fmt.Sprintf("Failed to generate PKCE verifier: %v", err))
+		return nil, fmt.Errorf("failed to generate PKCE verifier: %w", err)
 	}
 	challenge := auth.GenerateCodeChallenge(verifier)
 	state, err := auth.GenerateState()
 	if err != nil {
-		output.Fatal(fmt.Sprintf("Failed to generate state: %v", err))
+		return nil, fmt.Errorf("failed to generate state: %w", err)
 	}
 
-	// Step 4: Build authorization URL
-	authURL := auth.BuildAuthorizationURL(config, clientID, challenge, state)
-
-	// Step 5: Start callback server
-	resultCh, shutdown, err := auth.StartCallbackServer()
-	if err != nil {
-		output.Fatal(err.Error())
+	// Step 5: Build authorization URL. With PAR the parameters are pushed to
+	// the server directly and the browser only carries a reference to them.
+	authReq := &auth.AuthorizationRequest{
+		ClientID:    clientID,
+		RedirectURI: callback.RedirectURI,
+		Challenge:   challenge,
+		State:       state,
+		Scope:       scope,
+		Resource:    baseURL,
+	}
+	authURL := auth.BuildAuthorizationURL(config, authReq)
+	if config.PushedAuthorizationRequestEndpoint != "" {
+		authURL, err = auth.PushAuthorizationRequest(ctx, client, config, authReq, dpop)
+		if err != nil {
+			return nil, err
+		}
 	}
-	defer shutdown()
 
 	// Step 6: Open browser
 	output.Info("Opening browser for authorization...")
@@ -74,7 +229,7 @@ func runLogin() {
 	// Step 7: Wait for callback (120s timeout)
 	output.Info("Waiting for authorization callback...")
 	select {
-	case result := <-resultCh:
+	case result := <-callback.Results:
 		if result.Code == "" {
 			errMsg := result.ErrorDescription
 			if errMsg == "" {
@@ -83,38 +238,141 @@ func runLogin() {
 			if errMsg == "" {
 				errMsg = "Unknown error"
 			}
-			output.Fatal(fmt.Sprintf("Authorization failed: %s", errMsg))
+			if auth.IsClientRejection(result.Error) {
+				return nil, fmt.Errorf("authorization failed: %w: %s", auth.ErrInvalidClient, errMsg)
+			}
+			return nil, fmt.Errorf("authorization failed: %s", errMsg)
 		}
 
 		// Verify state
 		if result.State != state {
-			output.Fatal("OAuth state mismatch - possible CSRF attack.")
+			return nil, errors.New("OAuth state mismatch - possible CSRF attack")
 		}
 
 		// Step 8: Exchange code for tokens
 		output.Info("Exchanging authorization code for tokens...")
-		tokenResp, err := auth.ExchangeCode(client, config.TokenEndpoint, result.Code, verifier, clientID)
-		if err != nil {
-			output.Fatal(err.Error())
-		}
+		return auth.ExchangeCode(ctx, client, config.TokenEndpoint, result.Code, verifier, clientID, callback.RedirectURI, baseURL, dpop)
 
-		// Step 9: Save tokens
-		if at, ok := tokenResp["access_token"].(string); ok {
-			creds.OAuthAccessToken = at
-		}
-		if rt, ok := tokenResp["refresh_token"].(string); ok {
-			creds.OAuthRefreshToken = rt
-		}
-		if ei, ok := tokenResp["expires_in"].(float64); ok {
-			creds.OAuthExpiresAt = time.Now().Unix() + int64(ei)
+	case <-time.After(120 * time.Second):
+		return nil, errors.New("timed out waiting for OAuth callback")
+
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// registerLoginClient registers a new OAuth client and stores it.
+func registerLoginClient(ctx context.Context, client *httpclient.Client, config *auth.OAuthConfig, creds *auth.Credentials) error {
+	output.Info("Registering CLI client...")
+	reg, err := auth.RegisterClient(ctx, client, config.RegistrationEndpoint)
+	if err != nil {
+		return err
+	}
+	creds.ApplyRegistration(reg)
+	if err := auth.SaveCredentials(creds); err != nil {
+		return fmt.Errorf("failed to save client registration: %w", err)
+	}
+	output.Success(fmt.Sprintf("Client registered: %s", reg.ClientID))
+	return nil
+}
+
+// authorizeDevice performs the RFC 8628 device authorization grant and
+// returns the token response.
+func authorizeDevice(ctx context.Context, client *httpclient.Client, config *auth.OAuthConfig, clientID, baseURL, scope string, dpop *auth.DPoPKey) (map[string]any, error) {
+	da, err := auth.RequestDeviceCode(ctx, client, config.DeviceAuthorizationEndpoint, clientID, scope, baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	output.Info("To sign in, visit:")
+	fmt.Printf("\n  %s\n\n", da.VerificationURI)
+	output.Info(fmt.Sprintf("and enter the code: %s", output.Bold(da.UserCode)))
+	if da.VerificationURIComplete != "" {
+		fmt.Printf("\n  %s\n  %s\n", output.Dim("Or open this link, which includes the code:"), da.VerificationURIComplete)
+	}
+	fmt.Println()
+
+	output.Info("Waiting for authorization...")
+	return auth.PollDeviceToken(ctx, client, config.TokenEndpoint, clientID, baseURL, da, dpop)
+}
+
+// loginScope returns the scope to request: --scope if given, otherwise every
+// scope the server advertises.
+func loginScope(config *auth.OAuthConfig) string {
+	if len(loginScopes) == 0 {
+		return config.Scope()
+	}
+	for _, s := range loginScopes {
+		if !slices.Contains(config.ScopesSupported, s) {
+			output.Warn(fmt.Sprintf("Scope %q is not in the server's scopes_supported %v.", s, config.ScopesSupported))
 		}
-		creds.BaseURL = baseURL
-		auth.SaveCredentials(creds)
+	}
+	return strings.Join(loginScopes, " ")
+}
+
+// setupLoginDPoP generates a fresh DPoP key for this login when --dpop is
+// set, and drops any previous key otherwise: a key lives exactly as long as
+// the tokens bound to it.
+func setupLoginDPoP(creds *auth.Credentials, config *auth.OAuthConfig) (*auth.DPoPKey, error) {
+	creds.DPoPPrivateKey = ""
+	creds.OAuthTokenType = ""
+	if !loginDPoP {
+		return nil, nil
+	}
+	if !slices.Contains(config.DPoPSigningAlgs, "ES256") {
+		output.Warn("Authorization server does not advertise ES256 DPoP support; tokens may not be bound.")
+	}
+	encoded, err := auth.GenerateDPoPKey()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to generate DPoP key: %v", err)
+	}
+	creds.DPoPPrivateKey = encoded
+	return creds.DPoP()
+}
 
-		output.Success("OAuth login successful!")
-		output.Info(fmt.Sprintf("Credentials saved to %s", auth.CredentialsFile))
+// saveLoginTokens stores a token response from any login flow.
+func saveLoginTokens(creds *auth.Credentials, tokenResp map[string]any, baseURL string) error {
+	creds.ApplyTokenResponse(tokenResp)
+	creds.BaseURL = baseURL
+	if err := auth.SaveCredentials(creds); err != nil {
+		return fmt.Errorf("Failed to save credentials: %v", err)
+	}
 
-	case <-time.After(120 * time.Second):
-		output.Fatal("Timed out waiting for OAuth callback.")
+	output.Success("OAuth login successful!")
+	if creds.DPoPPrivateKey != "" && !creds.UsesDPoP() {
+		output.Warn("Server did not issue a DPoP-bound token; it will be sent as a bearer token.")
 	}
+	output.Info("Credentials saved " + credentialsLocation())
+	return nil
+}
+
+// credentialsLocation describes where SaveCredentials keeps the tokens: the
+// credential helper if one is set, else the active credential store.
+func credentialsLocation() string {
+	if auth.CredentialHelper != "" {
+		// Only the command name: its arguments may be paths or secrets.
+		return "with credential helper " + strings.Fields(auth.CredentialHelper)[0]
+	}
+	switch auth.CredentialStore() {
+	case auth.StoreFile:
+		return "to " + auth.CredentialsFile
+	case auth.StoreEncryptedFile:
+		return "encrypted to " + auth.EncryptedCredentialsFile()
+	case auth.StoreKeyring:
+		return "to the system keyring"
+	default:
+		return "to the " + auth.CredentialStore() + " credential store"
+	}
+}
+
+func init() {
+	loginCmd.Flags().IntVar(&loginPort, "port", 0, "Port for the local OAuth callback server (default: any free port)")
+	loginCmd.Flags().BoolVar(&loginDevice, "device", false, "Use the device authorization grant (no local browser needed)")
+	loginCmd.Flags().BoolVar(&loginWithToken, "with-token", false, "Read an access token from stdin instead of signing in interactively")
+	loginCmd.Flags().BoolVar(&loginDPoP, "dpop", false, "Bind tokens to a locally generated key (RFC 9449 DPoP)")
+	loginCmd.Flags().StringSliceVar(&loginScopes, "scope", nil, "Scopes to request, comma-separated or repeated (default: all the server supports)")
+	loginCmd.MarkFlagsMutuallyExclusive("with-token", "device")
+	loginCmd.MarkFlagsMutuallyExclusive("with-token", "scope")
+	loginCmd.MarkFlagsMutuallyExclusive("with-token", "dpop")
+	loginCmd.MarkFlagsMutuallyExclusive("with-token", "port")
 }
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/lollipopai/cli/internal/auth"
+	"github.com/lollipopai/cli/internal/output"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogin_SavedMessageNamesStore(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, dir string)
+		want  string
+	}{
+		{
+			name: "encrypted file",
+			setup: func(t *testing.T, dir string) {
+				t.Setenv(auth.PassphraseEnvVar, "secret")
+				require.NoError(t, auth.SetCredentialStore(auth.StoreEncryptedFile))
+			},
+			want: "Credentials saved encrypted to %s/credentials.json.enc\n",
+		},
+		{
+			name: "credential helper",
+			setup: func(t *testing.T, dir string) {
+				if runtime.GOOS == "windows" {
+					t.Skip("credential helper test uses sh")
+				}
+				helper := filepath.Join(dir, "helper.sh")
+				require.NoError(t, os.WriteFile(helper, []byte("#!/bin/sh\ncat >/dev/null\n"), 0700))
+				auth.CredentialHelper = helper + " --vault secret/chp"
+			},
+			want: "Credentials saved with credential helper %s/helper.sh\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmp := t.TempDir()
+			origDir, origFile, origHelper := auth.ConfigDir, auth.CredentialsFile, auth.CredentialHelper
+			t.Cleanup(func() {
+				auth.ConfigDir, auth.CredentialsFile, auth.CredentialHelper = origDir, origFile, origHelper
+				require.NoError(t, auth.SetCredentialStore(""))
+				output.SetStatusWriter(os.Stdout)
+			})
+			auth.ConfigDir = tmp
+			auth.CredentialsFile = filepath.Join(tmp, "credentials.json")
+			t.Setenv(auth.ProfileEnvVar, "")
+			t.Setenv(auth.TokenEnvVar, "")
+			tt.setup(t, tmp)
+
+			var out bytes.Buffer
+			output.SetStatusWriter(&out)
+			tokens := map[string]any{"access_token": "access", "refresh_token": "refresh", "expires_in": float64(3600)}
+			require.NoError(t, saveLoginTokens(&auth.Credentials{}, tokens, "https://api.example.com"))
+
+			assert.Contains(t, out.String(), fmt.Sprintf(tt.want, tmp))
+		})
+	}
+}
@@ -1,24 +1,101 @@
 package cli
 
 import (
-	"os"
+	"context"
+	"errors"
+	"fmt"
 
 	"github.com/lollipopai/cli/internal/auth"
+	"github.com/lollipopai/cli/internal/httpclient"
 	"github.com/lollipopai/cli/internal/output"
 	"github.com/spf13/cobra"
 )
 
+var (
+	logoutLocalOnly   bool
+	logoutAllProfiles bool
+)
+
 var logoutCmd = &cobra.Command{
 	Use:   "logout",
-	Short: "Clear saved credentials",
-	Run: func(cmd *cobra.Command, args []string) {
-		if err := os.Remove(auth.CredentialsFile); err != nil {
-			if os.IsNotExist(err) {
-				output.Info("No credentials found. Already logged out.")
-				return
+	Short: "Revoke tokens and clear saved credentials for the active profile",
+	Long: `Revoke tokens and clear saved credentials for the active profile.
+
+The refresh and access tokens are revoked at the server's revocation endpoint
+(RFC 7009) before the local credentials are removed. Use --local-only to skip
+revocation, or --all-profiles to log out of every stored profile.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profiles := []string{auth.ActiveProfile()}
+		if logoutAllProfiles {
+			profiles = auth.ListProfiles()
+		}
+
+		client := httpclient.New()
+		for _, profile := range profiles {
+			if err := logoutProfile(cmd.Context(), client, profile); err != nil {
+				return err
 			}
-			output.Fatal(err.Error())
 		}
-		output.Success("Logged out. Credentials removed.")
+		return nil
 	},
 }
+
+func logoutProfile(ctx context.Context, client *httpclient.Client, profile string) error {
+	creds := auth.LoadProfile(profile)
+	if !logoutLocalOnly {
+		revokeProfileTokens(ctx, client, profile, creds)
+		if err := ctx.Err(); err != nil {
+			return err // keep the credentials if revocation was cut short
+		}
+	}
+
+	if err := auth.DeleteProfile(profile); err != nil {
+		if errors.Is(err, auth.ErrProfileNotFound) {
+			output.Info(fmt.Sprintf("No credentials found for profile %s. Already logged out.", output.Bold(profile)))
+			return nil
+		}
+		return err
+	}
+	output.Success(fmt.Sprintf("Logged out of profile %s. Credentials removed.", output.Bold(profile)))
+	return nil
+}
+
+// revokeProfileTokens revokes a profile's refresh and access tokens and
+// reports the outcome of each. Failures are warnings: local credentials are
+// removed regardless.
+func revokeProfileTokens(ctx context.Context, client *httpclient.Client, profile string, creds *auth.Credentials) {
+	creds.GetToken() // loads tokens from the credential helper, if any
+	tokens := []struct{ hint, value string }{
+		{"refresh_token", creds.OAuthRefreshToken},
+		{"access_token", creds.OAuthAccessToken},
+	}
+	if tokens[0].value == "" && tokens[1].value == "" {
+		return
+	}
+
+	config, err := creds.OAuthServerConfig(ctx, client)
+	if err != nil {
+		output.Warn(fmt.Sprintf("Profile %s: could not discover revocation endpoint, tokens not revoked: %v", profile, err))
+		return
+	}
+	if config.RevocationEndpoint == "" {
+		output.Warn(fmt.Sprintf("Profile %s: server does not advertise a revocation_endpoint, tokens not revoked.", profile))
+		return
+	}
+
+	for _, tok := range tokens {
+		if tok.value == "" {
+			continue
+		}
+		if err := auth.RevokeToken(ctx, client, config.RevocationEndpoint, tok.value, tok.hint, creds.OAuthClientID); err != nil {
+			output.Warn(fmt.Sprintf("Profile %s: %v", profile, err))
+			continue
+		}
+		output.Success(fmt.Sprintf("Profile %s: %s revoked.", profile, tok.hint))
+	}
+}
+
+func init() {
+	logoutCmd.Flags().BoolVar(&logoutLocalOnly, "local-only", false, "Only remove local credentials; don't revoke tokens at the server")
+	logoutCmd.Flags().BoolVar(&logoutAllProfiles, "all-profiles", false, "Log out of every stored profile")
+}
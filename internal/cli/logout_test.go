@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lollipopai/cli/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogout_AllProfilesWithHelper(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("credential helper test uses sh")
+	}
+	tmp := t.TempDir()
+	origDir, origFile, origHelper := auth.ConfigDir, auth.CredentialsFile, auth.CredentialHelper
+	t.Cleanup(func() {
+		auth.ConfigDir, auth.CredentialsFile, auth.CredentialHelper = origDir, origFile, origHelper
+		logoutAllProfiles = false
+	})
+	auth.ConfigDir = tmp
+	auth.CredentialsFile = filepath.Join(tmp, "credentials.json")
+	t.Setenv(auth.ProfileEnvVar, "")
+	t.Setenv(auth.TokenEnvVar, "")
+
+	// The helper holds each profile's tokens, named after the profile.
+	helper := filepath.Join(tmp, "helper.sh")
+	require.NoError(t, os.WriteFile(helper, []byte("#!/bin/sh\n"+
+		"profile=$(sed -n 's/^profile=//p')\n"+
+		"if [ \"$1\" = get ]; then printf 'access_token=%s-access\\nrefresh_token=%s-refresh\\n' \"$profile\" \"$profile\"; fi\n"), 0700))
+	auth.CredentialHelper = helper
+
+	var mu sync.Mutex
+	var revoked []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		revoked = append(revoked, r.FormValue("token"))
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	server := &auth.OAuthConfig{Issuer: srv.URL, RevocationEndpoint: srv.URL + "/oauth/revoke", FetchedAt: time.Now().Unix()}
+	for _, profile := range []string{auth.DefaultProfile, "work"} {
+		require.NoError(t, auth.SaveProfile(profile, &auth.Credentials{BaseURL: srv.URL, OAuthServer: server}))
+	}
+
+	logoutAllProfiles = true
+	logoutCmd.SetContext(t.Context())
+	require.NoError(t, logoutCmd.RunE(logoutCmd, nil))
+
+	assert.ElementsMatch(t, []string{"default-refresh", "default-access", "work-refresh", "work-access"}, revoked)
+	assert.Equal(t, []string{auth.DefaultProfile}, auth.ListProfiles())
+}
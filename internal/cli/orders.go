@@ -1,26 +1,28 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
 	"github.com/lollipopai/cli/internal/output"
+	orderv1 "github.com/lollipopai/cli/pkg/cherrypick/order/v1"
 	"github.com/spf13/cobra"
 )
 
 var ordersCmd = &cobra.Command{
 	Use:   "orders",
 	Short: "Order commands",
-	Run: func(cmd *cobra.Command, args []string) {
-		runOrdersList()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runOrdersList(cmd.Context())
 	},
 }
 
 var ordersListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List order summaries",
-	Run: func(cmd *cobra.Command, args []string) {
-		runOrdersList()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runOrdersList(cmd.Context())
 	},
 }
 
@@ -28,17 +30,21 @@ var ordersGetCmd = &cobra.Command{
 	Use:   "get <id>",
 	Short: "Get an order by ID",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		caller := newTwirpCaller()
-		result, err := caller.Call("lollipop.proto.order.v1.OrderV1", "Get", map[string]any{
-			"id": args[0],
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		result, err := rawResult(cmd.Context(), func(ctx context.Context) (any, error) {
+			return orderv1.NewOrderV1Client(client).Get(ctx, &orderv1.GetRequest{ID: args[0]})
 		})
 		if err != nil {
-			output.Fatal(err.Error())
+			return err
 		}
 		output.PrintJSON(result)
 
-		uids := extractProductUIDs(result)
+		tree, _ := output.JSONValue(result)
+		uids := extractProductUIDs(tree)
 		if len(uids) > 0 {
 			fmt.Println()
 			output.Info("Product UIDs (for re-ordering):")
@@ -47,6 +53,7 @@ var ordersGetCmd = &cobra.Command{
 			output.Info("Re-add to basket:")
 			fmt.Printf("  chp basket add-product %s\n", strings.Join(uids, " "))
 		}
+		return nil
 	},
 }
 
@@ -81,13 +88,14 @@ func extractProductUIDs(v any) []string {
 	return uids
 }
 
-func runOrdersList() {
-	caller := newTwirpCaller()
-	result, err := caller.Call("lollipop.proto.order.v1.OrderV1", "SummaryList", nil)
+func runOrdersList(ctx context.Context) error {
+	client, err := newClient()
 	if err != nil {
-		output.Fatal(err.Error())
+		return err
 	}
-	output.PrintJSON(result)
+	return printResult(rawResult(ctx, func(ctx context.Context) (any, error) {
+		return orderv1.NewOrderV1Client(client).SummaryList(ctx, nil)
+	}))
 }
 
 func init() {
@@ -1,38 +1,39 @@
 package cli
 
 import (
-	"github.com/lollipopai/cli/internal/output"
+	"context"
+
+	planv1 "github.com/lollipopai/cli/pkg/cherrypick/plan/v1"
 	"github.com/spf13/cobra"
 )
 
-const planService = "lollipop.proto.plan.v1.PlanV1"
-
 var planCmd = &cobra.Command{
 	Use:   "plan",
 	Short: "Meal plan commands",
-	Run: func(cmd *cobra.Command, args []string) {
-		runPlanShow()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPlanShow(cmd.Context())
 	},
 }
 
 var planShowCmd = &cobra.Command{
 	Use:   "show",
 	Short: "Show current meal plan",
-	Run: func(cmd *cobra.Command, args []string) {
-		runPlanShow()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPlanShow(cmd.Context())
 	},
 }
 
 var planListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List available plans",
-	Run: func(cmd *cobra.Command, args []string) {
-		caller := newTwirpCaller()
-		result, err := caller.Call(planService, "List", nil)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
 		if err != nil {
-			output.Fatal(err.Error())
+			return err
 		}
-		output.PrintJSON(result)
+		return printResult(rawResult(cmd.Context(), func(ctx context.Context) (any, error) {
+			return planv1.NewPlanV1Client(client).List(ctx, nil)
+		}))
 	},
 }
 
@@ -40,15 +41,14 @@ var planGetCmd = &cobra.Command{
 	Use:   "get <id>",
 	Short: "Get a specific plan",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		caller := newTwirpCaller()
-		result, err := caller.Call(planService, "Get", map[string]any{
-			"id": args[0],
-		})
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
 		if err != nil {
-			output.Fatal(err.Error())
+			return err
 		}
-		output.PrintJSON(result)
+		return printResult(rawResult(cmd.Context(), func(ctx context.Context) (any, error) {
+			return planv1.NewPlanV1Client(client).Get(ctx, &planv1.GetRequest{ID: args[0]})
+		}))
 	},
 }
 
@@ -56,22 +56,19 @@ var planAddRecipeCmd = &cobra.Command{
 	Use:   "add-recipe <plan-id> <recipe-id>...",
 	Short: "Add one or more recipes to a plan",
 	Args:  cobra.MinimumNArgs(2),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		planID := args[0]
 		recipeIDs := args[1:]
-		caller := newTwirpCaller()
-		var result any
-		for _, recipeID := range recipeIDs {
-			var err error
-			result, err = caller.Call(planService, "AddRecipe", map[string]any{
-				"plan_id":   planID,
-				"recipe_id": recipeID,
-			})
-			if err != nil {
-				output.Fatal(err.Error())
-			}
+		client, err := newClient()
+		if err != nil {
+			return err
 		}
-		output.PrintJSON(result)
+		plan := planv1.NewPlanV1Client(client)
+		return runBatch(cmd.Context(), recipeIDs, func(ctx context.Context, recipeID string) (any, error) {
+			return rawResult(ctx, func(ctx context.Context) (any, error) {
+				return plan.AddRecipe(ctx, &planv1.RecipeRequest{PlanID: planID, RecipeID: recipeID})
+			})
+		})
 	},
 }
 
@@ -79,26 +76,25 @@ var planRemoveRecipeCmd = &cobra.Command{
 	Use:   "remove-recipe <plan-id> <recipe-id>",
 	Short: "Remove a recipe from a plan",
 	Args:  cobra.ExactArgs(2),
-	Run: func(cmd *cobra.Command, args []string) {
-		caller := newTwirpCaller()
-		result, err := caller.Call(planService, "RemoveRecipe", map[string]any{
-			"plan_id":   args[0],
-			"recipe_id": args[1],
-		})
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
 		if err != nil {
-			output.Fatal(err.Error())
+			return err
 		}
-		output.PrintJSON(result)
+		return printResult(rawResult(cmd.Context(), func(ctx context.Context) (any, error) {
+			return planv1.NewPlanV1Client(client).RemoveRecipe(ctx, &planv1.RecipeRequest{PlanID: args[0], RecipeID: args[1]})
+		}))
 	},
 }
 
-func runPlanShow() {
-	caller := newTwirpCaller()
-	result, err := caller.Call(planService, "Show", nil)
+func runPlanShow(ctx context.Context) error {
+	client, err := newClient()
 	if err != nil {
-		output.Fatal(err.Error())
+		return err
 	}
-	output.PrintJSON(result)
+	return printResult(rawResult(ctx, func(ctx context.Context) (any, error) {
+		return planv1.NewPlanV1Client(client).Show(ctx, nil)
+	}))
 }
 
 func init() {
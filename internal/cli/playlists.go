@@ -1,23 +1,25 @@
 package cli
 
 import (
-	"github.com/lollipopai/cli/internal/output"
+	"context"
+
+	playlistv1 "github.com/lollipopai/cli/pkg/cherrypick/playlist/v1"
 	"github.com/spf13/cobra"
 )
 
 var playlistsCmd = &cobra.Command{
 	Use:   "playlists",
 	Short: "Playlist commands",
-	Run: func(cmd *cobra.Command, args []string) {
-		runPlaylistsList()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPlaylistsList(cmd.Context())
 	},
 }
 
 var playlistsListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List playlists",
-	Run: func(cmd *cobra.Command, args []string) {
-		runPlaylistsList()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPlaylistsList(cmd.Context())
 	},
 }
 
@@ -25,25 +27,25 @@ var playlistsGetCmd = &cobra.Command{
 	Use:   "get <id>",
 	Short: "Get a playlist by ID",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		caller := newTwirpCaller()
-		result, err := caller.Call("lollipop.proto.playlist.v1.PlaylistV1", "Get", map[string]any{
-			"id": args[0],
-		})
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
 		if err != nil {
-			output.Fatal(err.Error())
+			return err
 		}
-		output.PrintJSON(result)
+		return printResult(rawResult(cmd.Context(), func(ctx context.Context) (any, error) {
+			return playlistv1.NewPlaylistV1Client(client).Get(ctx, &playlistv1.GetRequest{ID: args[0]})
+		}))
 	},
 }
 
-func runPlaylistsList() {
-	caller := newTwirpCaller()
-	result, err := caller.Call("lollipop.proto.playlist.v1.PlaylistV1", "List", nil)
+func runPlaylistsList(ctx context.Context) error {
+	client, err := newClient()
 	if err != nil {
-		output.Fatal(err.Error())
+		return err
 	}
-	output.PrintJSON(result)
+	return printResult(rawResult(ctx, func(ctx context.Context) (any, error) {
+		return playlistv1.NewPlaylistV1Client(client).List(ctx, nil)
+	}))
 }
 
 func init() {
@@ -1,7 +1,9 @@
 package cli
 
 import (
-	"github.com/lollipopai/cli/internal/output"
+	"context"
+
+	productv2 "github.com/lollipopai/cli/pkg/cherrypick/product/v2"
 	"github.com/spf13/cobra"
 )
 
@@ -14,15 +16,14 @@ var productsSearchCmd = &cobra.Command{
 	Use:   "search <query>",
 	Short: "Search products",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		caller := newTwirpCaller()
-		result, err := caller.Call("lollipop.proto.product.v2.ProductV2", "Search", map[string]any{
-			"keyword": args[0],
-		})
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
 		if err != nil {
-			output.Fatal(err.Error())
+			return err
 		}
-		output.PrintJSON(result)
+		return printResult(rawResult(cmd.Context(), func(ctx context.Context) (any, error) {
+			return productv2.NewProductV2Client(client).Search(ctx, &productv2.SearchRequest{Keyword: args[0]})
+		}))
 	},
 }
 
@@ -30,15 +31,14 @@ var productsGetCmd = &cobra.Command{
 	Use:   "get <uid>",
 	Short: "Get a product by Sainsbury's product UID",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		caller := newTwirpCaller()
-		result, err := caller.Call("lollipop.proto.product.v2.ProductV2", "Get", map[string]any{
-			"id": args[0],
-		})
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
 		if err != nil {
-			output.Fatal(err.Error())
+			return err
 		}
-		output.PrintJSON(result)
+		return printResult(rawResult(cmd.Context(), func(ctx context.Context) (any, error) {
+			return productv2.NewProductV2Client(client).Get(ctx, &productv2.GetRequest{ID: args[0]})
+		}))
 	},
 }
 
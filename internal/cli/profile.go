@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lollipopai/cli/internal/auth"
+	"github.com/lollipopai/cli/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage named credential profiles",
+	Long: `Manage named credential profiles.
+
+Each profile has its own base URL, OAuth client and tokens. The active profile
+is chosen by --profile, then $CHP_PROFILE, then the one selected with
+"chp profile use", then "default".
+
+Examples:
+  chp profile list                       List profiles
+  chp profile use work                   Make "work" the current profile
+  chp --profile test login               Sign in to the "test" profile
+  chp profile delete test                Remove the "test" profile`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		runProfileList()
+		return nil
+	},
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List profiles",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		runProfileList()
+		return nil
+	},
+}
+
+var profileUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Set the current profile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := auth.SetCurrentProfile(args[0]); err != nil {
+			return err
+		}
+		output.Success(fmt.Sprintf("Now using profile %s", output.Bold(args[0])))
+		if auth.LoadProfile(args[0]).OAuthAccessToken == "" {
+			output.Info("Profile has no credentials yet. Run: chp login")
+		}
+		return nil
+	},
+}
+
+var profileDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a profile and its stored credentials",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := auth.DeleteProfile(args[0]); err != nil {
+			if errors.Is(err, auth.ErrProfileNotFound) {
+				return fmt.Errorf("Profile %q not found.", args[0])
+			}
+			return err
+		}
+		output.Success(fmt.Sprintf("Profile %s deleted.", output.Bold(args[0])))
+		return nil
+	},
+}
+
+func runProfileList() {
+	active := auth.ActiveProfile()
+	for _, name := range auth.ListProfiles() {
+		creds := auth.LoadProfile(name)
+		marker := " "
+		if name == active {
+			marker = "*"
+		}
+		status := "logged in"
+		if auth.CredentialHelper != "" && creds.OAuthAccessToken == "" {
+			status = "tokens via credential helper"
+		} else if _, err := creds.GetToken(); err != nil {
+			status = "not logged in"
+		}
+		fmt.Printf("%s %s  %s\n", marker, output.Bold(name), output.Dim(creds.GetBaseURL()+", "+status))
+	}
+}
+
+func init() {
+	profileCmd.AddCommand(profileListCmd)
+	profileCmd.AddCommand(profileUseCmd)
+	profileCmd.AddCommand(profileDeleteCmd)
+}
@@ -1,7 +1,9 @@
 package cli
 
 import (
-	"github.com/lollipopai/cli/internal/output"
+	"context"
+
+	recipev1 "github.com/lollipopai/cli/pkg/cherrypick/recipe/v1"
 	"github.com/spf13/cobra"
 )
 
@@ -14,15 +16,14 @@ var recipesSearchCmd = &cobra.Command{
 	Use:   "search <query>",
 	Short: "Search recipes",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		caller := newTwirpCaller()
-		result, err := caller.Call("lollipop.proto.recipe.v1.RecipeV1", "Search", map[string]any{
-			"query": args[0],
-		})
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
 		if err != nil {
-			output.Fatal(err.Error())
+			return err
 		}
-		output.PrintJSON(result)
+		return printResult(rawResult(cmd.Context(), func(ctx context.Context) (any, error) {
+			return recipev1.NewRecipeV1Client(client).Search(ctx, &recipev1.SearchRequest{Query: args[0]})
+		}))
 	},
 }
 
@@ -30,7 +31,7 @@ var recipesGetCmd = &cobra.Command{
 	Use:   "get <slug-or-id>",
 	Short: "Get a recipe by slug or ID",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		identifier := args[0]
 		// If it contains letters, treat as slug; otherwise use id field
 		isSlug := false
@@ -41,19 +42,18 @@ var recipesGetCmd = &cobra.Command{
 			}
 		}
 
-		var payload map[string]any
+		req := &recipev1.GetBySlugRequest{ID: identifier}
 		if isSlug {
-			payload = map[string]any{"slug": identifier}
-		} else {
-			payload = map[string]any{"id": identifier}
+			req = &recipev1.GetBySlugRequest{Slug: identifier}
 		}
 
-		caller := newTwirpCaller()
-		result, err := caller.Call("lollipop.proto.recipe.v1.RecipeV1", "GetBySlug", payload)
+		client, err := newClient()
 		if err != nil {
-			output.Fatal(err.Error())
+			return err
 		}
-		output.PrintJSON(result)
+		return printResult(rawResult(cmd.Context(), func(ctx context.Context) (any, error) {
+			return recipev1.NewRecipeV1Client(client).GetBySlug(ctx, req)
+		}))
 	},
 }
 
@@ -1,13 +1,23 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/lollipopai/cli/internal/auth"
+	"github.com/lollipopai/cli/internal/config"
 	"github.com/lollipopai/cli/internal/httpclient"
-	"github.com/lollipopai/cli/internal/twirp"
+	"github.com/lollipopai/cli/internal/output"
+	"github.com/lollipopai/cli/pkg/cherrypick"
 	"github.com/spf13/cobra"
 )
 
@@ -21,6 +31,7 @@ var rootCmd = &cobra.Command{
 Examples:
   chp login                              Sign in via OAuth in browser
   chp whoami                             Show current user
+  chp auth token                         Print the access token
   chp recipes search curry               Search for recipes
   chp recipes get chicken-tikka          Get recipe by slug
   chp products search milk               Search products
@@ -38,43 +49,330 @@ Examples:
   chp plan add-recipe 1 100 101          Add recipes to a plan
   chp playlists                          List playlists
   chp config show                        Show current config
+  chp profile list                       List stored accounts
+  chp --profile work whoami              Use a named profile
   chp call recipe.v1.RecipeV1 Search     Raw Twirp call
   chp logout                             Clear credentials`,
 	SilenceUsage:  true,
 	SilenceErrors: true,
 }
 
+// interruptGrace is how long a command gets to wind down after Ctrl-C
+// before the process exits regardless, e.g. when blocked reading stdin.
+var interruptGrace = 3 * time.Second
+
+// exit ends the process; tests replace it.
+var exit = os.Exit
+
 // Execute runs the root command.
 func Execute(version string) {
 	Version = version
 	rootCmd.Version = version
 	httpclient.SetUserAgent("chp-cli/" + version)
 
-	// SIGINT → exit 130
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, os.Interrupt)
-	go func() {
-		<-sigCh
-		fmt.Println()
-		os.Exit(130)
-	}()
+	// SIGINT cancels the command's context: in-flight requests are aborted
+	// and the command reports what it completed before exiting 130. A
+	// second Ctrl-C exits immediately.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go exitAfterInterrupt(ctx, stop)
+
+	err := rootCmd.ExecuteContext(ctx)
+	cancelTimeout()
+	if ctx.Err() != nil {
+		exitInterrupted()
+	}
+	if err != nil {
+		exitWithError(err)
+	}
+}
+
+// exitAfterInterrupt waits for Ctrl-C to cancel ctx, then gives the command
+// interruptGrace to wind down before exiting. stop restores the default
+// handling, so a second Ctrl-C exits immediately.
+func exitAfterInterrupt(ctx context.Context, stop func()) {
+	<-ctx.Done()
+	stop()
+	time.Sleep(interruptGrace)
+	exitInterrupted()
+}
+
+// exitInterrupted exits 130, once any token refresh in flight has saved its
+// result: the refresh token it sent may already have been rotated.
+func exitInterrupted() {
+	auth.WaitForCredentialUpdates()
+	fmt.Fprintln(os.Stderr)
+	output.Error("Interrupted.")
+	exit(130)
+}
+
+// exitWithError reports the error a command returned and exits: with the
+// code's status for a Twirp error (see twirpFailures), with a command's own
+// status for an exitCode, otherwise 1.
+func exitWithError(err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		output.Fatal(fmt.Sprintf("Timed out after %s (see --timeout).", timeoutFlag))
+	}
+	var twErr *cherrypick.Error
+	if errors.As(err, &twErr) {
+		exitTwirpError(twErr)
+	}
+	var code exitCode
+	if errors.As(err, &code) {
+		os.Exit(int(code))
+	}
+	output.Fatal(err.Error())
+}
+
+// newClient returns an API client for the active profile, in the configured
+// wire format. Used by command handlers.
+func newClient() (*cherrypick.Client, error) {
+	return cherrypick.New(clientOptions...)
+}
+
+// rawResult makes a typed call under a context that keeps the response as
+// the API sent it, and returns that for printing: the typed messages only
+// have the fields in the descriptors.
+func rawResult(ctx context.Context, call func(ctx context.Context) (any, error)) (any, error) {
+	var raw any
+	if _, err := call(cherrypick.WithRawResponse(ctx, &raw)); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// printResult prints a call's result as JSON, or returns its error.
+func printResult(result any, err error) error {
+	if err != nil {
+		return err
+	}
+	output.PrintJSON(result)
+	return nil
+}
+
+// prepare runs before every command: it applies the config file and bounds
+// the command's context by --timeout.
+func prepare(cmd *cobra.Command, args []string) error {
+	if err := applyConfig(); err != nil {
+		return err
+	}
+	applyTimeout(cmd)
+	return nil
+}
+
+// applyConfig applies settings from the config file.
+func applyConfig() error {
+	cfg := config.Load()
+	if err := auth.SetCredentialStore(cfg.CredentialStore); err != nil {
+		output.Warn(fmt.Sprintf("%v; falling back to plaintext credentials file.", err))
+	}
+	auth.CredentialHelper = cfg.CredentialHelper
+	// The SDK would otherwise read the config file again, without falling
+	// back from an unknown store.
+	clientOptions = append(clientOptions,
+		cherrypick.WithCredentialStore(auth.CredentialStore()),
+		cherrypick.WithCredentialHelper(auth.CredentialHelper),
+	)
+
+	retries := httpclient.DefaultRetryPolicy.MaxRetries
+	if rootCmd.PersistentFlags().Changed("retries") {
+		retries = retriesFlag
+	} else if cfg.Retries != "" {
+		if n, err := strconv.Atoi(cfg.Retries); err == nil {
+			retries = n
+		} else {
+			output.Warn(fmt.Sprintf("Ignoring invalid retries setting %q.", cfg.Retries))
+		}
+	}
+	httpclient.SetMaxRetries(retries)
+
+	tlsOpts := httpclient.TLSOptions{
+		CAFile:   firstSet(caFileFlag, os.Getenv(httpclient.CAFileEnvVar), cfg.CAFile),
+		CertFile: firstSet(clientCertFlag, os.Getenv(httpclient.ClientCertEnvVar), cfg.ClientCert),
+		KeyFile:  firstSet(clientKeyFlag, os.Getenv(httpclient.ClientKeyEnvVar), cfg.ClientKey),
+		Insecure: insecureFlag,
+	}
+	if err := httpclient.ConfigureTLS(tlsOpts); err != nil {
+		return err
+	}
+
+	// The protobuf format needs the API's descriptors: the field numbers in
+	// those built into chp are placeholders.
+	switch wireFormat := firstSet(wireFormatFlag, cfg.WireFormat); wireFormat {
+	case "", "json":
+	case "protobuf":
+		if cfg.DescriptorSet == "" {
+			return fmt.Errorf("wire format protobuf needs the API's descriptors: build them (buf build -o cherrypick.binpb) and run chp config set descriptor-set cherrypick.binpb")
+		}
+		clientOptions = append(clientOptions, cherrypick.WithProtobuf(cfg.DescriptorSet))
+	default:
+		return fmt.Errorf("unknown wire format %q (available: %v)", wireFormat, config.WireFormats)
+	}
+
+	headers, err := parseHeaders(headerFlags)
+	if err != nil {
+		return err
+	}
+	clientOptions = append(clientOptions, cherrypick.WithInterceptors(
+		cherrypick.Headers(headers),
+		cherrypick.RequestID(),
+		cherrypick.Logging(),
+	))
+	return nil
+}
+
+// parseHeaders parses -H values of the form "Name: value".
+func parseHeaders(values []string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, v := range values {
+		name, value, ok := strings.Cut(v, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid header %q: use -H 'Name: value'", v)
+		}
+		if http.CanonicalHeaderKey(name) == "Authorization" {
+			return nil, errors.New("-H can't set Authorization: chp sends the profile's token (see chp login, or set " + auth.TokenEnvVar + ")")
+		}
+		headers[name] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// firstSet returns the first non-empty value: flag, then environment, then
+// config file.
+func firstSet(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// setupLogging routes log/slog to stderr or --log-file at the level chosen
+// by -v/--debug or --trace. Without them only warnings are logged.
+func setupLogging() {
+	level := slog.LevelWarn
+	switch {
+	case traceFlag:
+		level = httpclient.LevelTrace
+	case debugFlag:
+		level = slog.LevelDebug
+	}
+
+	var w io.Writer = os.Stderr
+	toFile := false
+	if logFileFlag != "" {
+		f, err := os.OpenFile(logFileFlag, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			output.Warn(fmt.Sprintf("Could not open log file: %v; logging to stderr.", err))
+		} else {
+			w, toFile = f, true
+		}
+	}
+
+	log := slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.LevelKey && a.Value.Any() == httpclient.LevelTrace {
+				a.Value = slog.StringValue("TRACE")
+			}
+			return a
+		},
+	})
+	slog.SetDefault(slog.New(statusHandler{log: log, toFile: toFile}))
+}
+
+// statusHandler prints Info and higher records as chp's status lines: the
+// auth and API client packages log what a user should see, such as a token
+// refresh, rather than printing it themselves. Other records go to log, which
+// also keeps the status lines when it is a --log-file.
+type statusHandler struct {
+	log    slog.Handler
+	toFile bool
+}
+
+func (h statusHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelInfo || h.log.Enabled(ctx, level)
+}
 
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+func (h statusHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelInfo {
+		return h.log.Handle(ctx, r)
+	}
+	switch {
+	case r.Level >= slog.LevelError:
+		output.Error(r.Message)
+	case r.Level >= slog.LevelWarn:
+		output.Warn(r.Message)
+	default:
+		output.Info(r.Message)
+	}
+	if h.toFile && h.log.Enabled(ctx, r.Level) {
+		return h.log.Handle(ctx, r)
 	}
+	return nil
 }
 
-// newTwirpCaller creates a Caller with loaded credentials. Used by command handlers.
-func newTwirpCaller() *twirp.Caller {
-	creds := auth.LoadCredentials()
-	client := httpclient.New()
-	return twirp.NewCaller(client, creds)
+func (h statusHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return statusHandler{log: h.log.WithAttrs(attrs), toFile: h.toFile}
 }
 
+func (h statusHandler) WithGroup(name string) slog.Handler {
+	return statusHandler{log: h.log.WithGroup(name), toFile: h.toFile}
+}
+
+// applyTimeout bounds the command's context by --timeout, if set.
+func applyTimeout(cmd *cobra.Command) {
+	if timeoutFlag > 0 {
+		var ctx context.Context
+		ctx, cancelTimeout = context.WithTimeout(cmd.Context(), timeoutFlag)
+		cmd.SetContext(ctx)
+	}
+}
+
+var (
+	// retriesFlag holds --retries; applyConfig resolves it against the config.
+	retriesFlag int
+
+	debugFlag   bool
+	traceFlag   bool
+	logFileFlag string
+
+	caFileFlag     string
+	clientCertFlag string
+	clientKeyFlag  string
+	insecureFlag   bool
+
+	timeoutFlag   time.Duration
+	cancelTimeout context.CancelFunc = func() {}
+
+	wireFormatFlag string
+	// headerFlags holds the -H values, as "Name: value".
+	headerFlags []string
+	// clientOptions is set by applyConfig for newClient.
+	clientOptions []cherrypick.Option
+)
+
 func init() {
+	cobra.OnInitialize(setupLogging)
+	rootCmd.PersistentPreRunE = prepare // not in the literal: applyConfig reads rootCmd's flags
+	rootCmd.PersistentFlags().StringVar(&auth.ProfileOverride, "profile", "", "Credentials profile to use (default: $"+auth.ProfileEnvVar+" or the current profile)")
+	rootCmd.PersistentFlags().BoolVarP(&debugFlag, "debug", "v", false, "Log each HTTP request's method, URL, status, timing and size to stderr")
+	rootCmd.PersistentFlags().BoolVar(&traceFlag, "trace", false, "Like --debug, plus full headers and bodies (secrets redacted)")
+	rootCmd.PersistentFlags().StringVar(&logFileFlag, "log-file", "", "Append logs to this file instead of stderr")
+	rootCmd.PersistentFlags().DurationVar(&timeoutFlag, "timeout", 0, "Give up on the command after this long, e.g. 30s or 2m (default: no limit)")
+	rootCmd.PersistentFlags().StringVar(&caFileFlag, "ca-file", "", "PEM bundle of extra CAs to trust (or $"+httpclient.CAFileEnvVar+")")
+	rootCmd.PersistentFlags().StringVar(&clientCertFlag, "client-cert", "", "PEM client certificate for mTLS (or $"+httpclient.ClientCertEnvVar+")")
+	rootCmd.PersistentFlags().StringVar(&clientKeyFlag, "client-key", "", "PEM private key for --client-cert, if not in the same file (or $"+httpclient.ClientKeyEnvVar+")")
+	rootCmd.PersistentFlags().BoolVar(&insecureFlag, "insecure", false, "Skip TLS certificate verification for localhost and 127.0.0.1")
+	rootCmd.PersistentFlags().StringVar(&wireFormatFlag, "wire-format", "", "Twirp encoding: json, or protobuf (requires the descriptor-set setting) (default: the wire-format setting, else json)")
+	rootCmd.PersistentFlags().StringArrayVarP(&headerFlags, "header", "H", nil, "Send an extra header with API calls, e.g. -H 'X-Request-Id: abc' (repeatable)")
+	rootCmd.PersistentFlags().IntVar(&retriesFlag, "retries", httpclient.DefaultRetryPolicy.MaxRetries, "Retries for transient failures (connection errors, 429, 5xx) on reads and idempotent writes")
+
 	rootCmd.AddCommand(loginCmd)
 	rootCmd.AddCommand(logoutCmd)
+	rootCmd.AddCommand(authCmd)
 	rootCmd.AddCommand(whoamiCmd)
 	rootCmd.AddCommand(recipesCmd)
 	rootCmd.AddCommand(productsCmd)
@@ -84,5 +382,6 @@ func init() {
 	rootCmd.AddCommand(slotsCmd)
 	rootCmd.AddCommand(planCmd)
 	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(profileCmd)
 	rootCmd.AddCommand(callCmd)
 }
@@ -1,26 +1,26 @@
 package cli
 
 import (
-	"github.com/lollipopai/cli/internal/output"
+	"context"
+
+	slotv1 "github.com/lollipopai/cli/pkg/cherrypick/slot/v1"
 	"github.com/spf13/cobra"
 )
 
-const slotService = "lollipop.proto.slot.v1.SlotV1"
-
 var slotsCmd = &cobra.Command{
 	Use:     "slots",
 	Aliases: []string{"delivery"},
 	Short:   "Delivery slot commands",
-	Run: func(cmd *cobra.Command, args []string) {
-		runSlotsList()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSlotsList(cmd.Context())
 	},
 }
 
 var slotsListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List available delivery slots",
-	Run: func(cmd *cobra.Command, args []string) {
-		runSlotsList()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSlotsList(cmd.Context())
 	},
 }
 
@@ -28,15 +28,14 @@ var slotsGetCmd = &cobra.Command{
 	Use:   "get <id>",
 	Short: "Get delivery slot details",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		caller := newTwirpCaller()
-		result, err := caller.Call(slotService, "Get", map[string]any{
-			"id": args[0],
-		})
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
 		if err != nil {
-			output.Fatal(err.Error())
+			return err
 		}
-		output.PrintJSON(result)
+		return printResult(rawResult(cmd.Context(), func(ctx context.Context) (any, error) {
+			return slotv1.NewSlotV1Client(client).Get(ctx, &slotv1.SlotRequest{ID: args[0]})
+		}))
 	},
 }
 
@@ -44,25 +43,25 @@ var slotsBookCmd = &cobra.Command{
 	Use:   "book <id>",
 	Short: "Book a delivery slot",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		caller := newTwirpCaller()
-		result, err := caller.Call(slotService, "Book", map[string]any{
-			"id": args[0],
-		})
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
 		if err != nil {
-			output.Fatal(err.Error())
+			return err
 		}
-		output.PrintJSON(result)
+		return printResult(rawResult(cmd.Context(), func(ctx context.Context) (any, error) {
+			return slotv1.NewSlotV1Client(client).Book(ctx, &slotv1.SlotRequest{ID: args[0]})
+		}))
 	},
 }
 
-func runSlotsList() {
-	caller := newTwirpCaller()
-	result, err := caller.Call(slotService, "List", nil)
+func runSlotsList(ctx context.Context) error {
+	client, err := newClient()
 	if err != nil {
-		output.Fatal(err.Error())
+		return err
 	}
-	output.PrintJSON(result)
+	return printResult(rawResult(ctx, func(ctx context.Context) (any, error) {
+		return slotv1.NewSlotV1Client(client).List(ctx, nil)
+	}))
 }
 
 func init() {
@@ -1,19 +1,22 @@
 package cli
 
 import (
-	"github.com/lollipopai/cli/internal/output"
+	"context"
+
+	userv1 "github.com/lollipopai/cli/pkg/cherrypick/user/v1"
 	"github.com/spf13/cobra"
 )
 
 var whoamiCmd = &cobra.Command{
 	Use:   "whoami",
 	Short: "Show current user profile",
-	Run: func(cmd *cobra.Command, args []string) {
-		caller := newTwirpCaller()
-		result, err := caller.Call("lollipop.proto.user.v1.UserV1", "Current", nil)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newClient()
 		if err != nil {
-			output.Fatal(err.Error())
+			return err
 		}
-		output.PrintJSON(result)
+		return printResult(rawResult(cmd.Context(), func(ctx context.Context) (any, error) {
+			return userv1.NewUserV1Client(client).Current(ctx, nil)
+		}))
 	},
 }
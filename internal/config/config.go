@@ -0,0 +1,179 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/lollipopai/cli/internal/auth"
+)
+
+// Config holds user settings. Unlike credentials.json it never holds secrets.
+type Config struct {
+	CredentialStore  string `json:"credential_store,omitempty"`
+	CredentialHelper string `json:"credential_helper,omitempty"`
+	Retries          string `json:"retries,omitempty"`
+	CAFile           string `json:"ca_file,omitempty"`
+	ClientCert       string `json:"client_cert,omitempty"`
+	ClientKey        string `json:"client_key,omitempty"`
+	WireFormat       string `json:"wire_format,omitempty"`
+	DescriptorSet    string `json:"descriptor_set,omitempty"`
+}
+
+// WireFormats are the accepted values of the wire-format setting.
+var WireFormats = []string{"json", "protobuf"}
+
+// setting describes a key accepted by `chp config set`.
+type setting struct {
+	field    func(c *Config) *string
+	validate func(value string) error
+	// normalize, if set, rewrites a valid value before it is stored.
+	normalize func(value string) string
+}
+
+var settings = map[string]setting{
+	"credential-store": {
+		field: func(c *Config) *string { return &c.CredentialStore },
+		validate: func(value string) error {
+			if !slices.Contains(auth.CredentialStores(), value) {
+				return fmt.Errorf("unknown credential store %q (available: %v)", value, auth.CredentialStores())
+			}
+			return nil
+		},
+	},
+	"credential-helper": {
+		field:    func(c *Config) *string { return &c.CredentialHelper },
+		validate: func(string) error { return nil },
+	},
+	"ca-file": {
+		field:     func(c *Config) *string { return &c.CAFile },
+		validate:  validateFile,
+		normalize: absPath,
+	},
+	"client-cert": {
+		field:     func(c *Config) *string { return &c.ClientCert },
+		validate:  validateFile,
+		normalize: absPath,
+	},
+	"client-key": {
+		field:     func(c *Config) *string { return &c.ClientKey },
+		validate:  validateFile,
+		normalize: absPath,
+	},
+	"wire-format": {
+		field: func(c *Config) *string { return &c.WireFormat },
+		validate: func(value string) error {
+			if value != "" && !slices.Contains(WireFormats, value) {
+				return fmt.Errorf("unknown wire format %q (available: %v)", value, WireFormats)
+			}
+			return nil
+		},
+	},
+	"descriptor-set": {
+		field:     func(c *Config) *string { return &c.DescriptorSet },
+		validate:  validateFile,
+		normalize: absPath,
+	},
+	"retries": {
+		field: func(c *Config) *string { return &c.Retries },
+		validate: func(value string) error {
+			if value == "" {
+				return nil
+			}
+			if n, err := strconv.Atoi(value); err != nil || n < 0 {
+				return fmt.Errorf("retries must be a non-negative integer, got %q", value)
+			}
+			return nil
+		},
+	},
+}
+
+// validateFile accepts "" (unset) or a path to an existing file.
+func validateFile(value string) error {
+	if value == "" {
+		return nil
+	}
+	if info, err := os.Stat(value); err != nil {
+		return err
+	} else if info.IsDir() {
+		return fmt.Errorf("%s is a directory", value)
+	}
+	return nil
+}
+
+// absPath makes a file setting independent of the working directory.
+func absPath(value string) string {
+	if abs, err := filepath.Abs(value); err == nil && value != "" {
+		return abs
+	}
+	return value
+}
+
+// File returns the path of the config file.
+func File() string {
+	return filepath.Join(auth.ConfigDir, "config.json")
+}
+
+// Keys returns the setting names accepted by Set, sorted.
+func Keys() []string {
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Load reads the config file. Returns zero-value on missing/corrupt file.
+func Load() *Config {
+	data, err := os.ReadFile(File())
+	if err != nil {
+		return &Config{}
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return &Config{}
+	}
+	return &cfg
+}
+
+// Save writes the config file.
+func Save(cfg *Config) error {
+	if err := os.MkdirAll(auth.ConfigDir, 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(File(), data, 0600)
+}
+
+// Get returns the value of a setting.
+func (c *Config) Get(key string) (string, error) {
+	s, ok := settings[key]
+	if !ok {
+		return "", fmt.Errorf("unknown config key %q (available: %v)", key, Keys())
+	}
+	return *s.field(c), nil
+}
+
+// Set validates and updates a setting. It does not save the file.
+func (c *Config) Set(key, value string) error {
+	s, ok := settings[key]
+	if !ok {
+		return fmt.Errorf("unknown config key %q (available: %v)", key, Keys())
+	}
+	if err := s.validate(value); err != nil {
+		return err
+	}
+	if s.normalize != nil {
+		value = s.normalize(value)
+	}
+	*s.field(c) = value
+	return nil
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lollipopai/cli/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestConfig(t *testing.T) {
+	t.Helper()
+	orig := auth.ConfigDir
+	auth.ConfigDir = t.TempDir()
+	t.Cleanup(func() { auth.ConfigDir = orig })
+}
+
+func TestLoad_Missing(t *testing.T) {
+	setupTestConfig(t)
+	assert.Equal(t, &Config{}, Load())
+}
+
+func TestSaveAndLoad_RoundTrip(t *testing.T) {
+	setupTestConfig(t)
+
+	cfg := &Config{CredentialStore: auth.StoreEncryptedFile}
+	require.NoError(t, Save(cfg))
+	assert.Equal(t, cfg, Load())
+}
+
+func TestSet_CredentialStore(t *testing.T) {
+	cfg := &Config{}
+	require.NoError(t, cfg.Set("credential-store", auth.StoreKeyring))
+	v, err := cfg.Get("credential-store")
+	require.NoError(t, err)
+	assert.Equal(t, auth.StoreKeyring, v)
+
+	assert.Error(t, cfg.Set("credential-store", "floppy-disk"))
+	assert.Equal(t, auth.StoreKeyring, cfg.CredentialStore, "invalid value not applied")
+}
+
+func TestSet_Retries(t *testing.T) {
+	cfg := &Config{}
+	require.NoError(t, cfg.Set("retries", "5"))
+	assert.Equal(t, "5", cfg.Retries)
+	require.NoError(t, cfg.Set("retries", "0"))
+
+	assert.Error(t, cfg.Set("retries", "-1"))
+	assert.Error(t, cfg.Set("retries", "lots"))
+	assert.Equal(t, "0", cfg.Retries, "invalid value not applied")
+}
+
+func TestSet_WireFormat(t *testing.T) {
+	cfg := &Config{}
+	require.NoError(t, cfg.Set("wire-format", "protobuf"))
+	assert.Equal(t, "protobuf", cfg.WireFormat)
+
+	assert.Error(t, cfg.Set("wire-format", "xml"))
+	assert.Equal(t, "protobuf", cfg.WireFormat, "invalid value not applied")
+	require.NoError(t, cfg.Set("wire-format", ""), "empty unsets")
+}
+
+func TestSet_FileSettings(t *testing.T) {
+	cfg := &Config{}
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(path, []byte("pem"), 0600))
+
+	require.NoError(t, cfg.Set("ca-file", path))
+	assert.Equal(t, path, cfg.CAFile)
+	t.Chdir(filepath.Dir(path))
+	require.NoError(t, cfg.Set("client-cert", "ca.pem"))
+	assert.Equal(t, path, cfg.ClientCert, "relative paths are stored absolute")
+	assert.Error(t, cfg.Set("client-cert", filepath.Join(t.TempDir(), "missing.pem")))
+	assert.Error(t, cfg.Set("client-key", t.TempDir()), "directories are rejected")
+	require.NoError(t, cfg.Set("ca-file", ""), "empty unsets")
+}
+
+func TestSet_UnknownKey(t *testing.T) {
+	err := (&Config{}).Set("colour", "blue")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown config key")
+}
@@ -0,0 +1,273 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Cassettes let scripts built on chp be tested without the real API. With
+// CHP_RECORD set, every request and response is appended to that file; with
+// CHP_REPLAY set, responses are served from it and nothing touches the
+// network. Credentials are redacted as in --trace logs, so cassettes can be
+// committed.
+const (
+	RecordEnvVar = "CHP_RECORD"
+	ReplayEnvVar = "CHP_REPLAY"
+
+	// ReplayMatchEnvVar selects the request fields compared on replay: a
+	// comma-separated subset of method, url and body (default all three).
+	ReplayMatchEnvVar = "CHP_REPLAY_MATCH"
+)
+
+// ErrUnmatchedRequest is returned on replay for a request the cassette has
+// no unused interaction for.
+var ErrUnmatchedRequest = errors.New("replay: no recorded interaction matches request")
+
+// replayError marks a failure of the replayer itself, which is reported as
+// is instead of as a connection failure, and never retried.
+type replayError struct{ err error }
+
+func (e *replayError) Error() string { return e.err.Error() }
+func (e *replayError) Unwrap() error { return e.err }
+
+var matchFields = []string{"method", "url", "body"}
+
+type cassette struct {
+	Interactions []interaction `json:"interactions"`
+}
+
+type interaction struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+}
+
+type recordedRequest struct {
+	Method       string      `json:"method"`
+	URL          string      `json:"url"`
+	Headers      http.Header `json:"headers,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty"`
+}
+
+type recordedResponse struct {
+	Status       int         `json:"status"`
+	Headers      http.Header `json:"headers,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty"`
+}
+
+// cassetteBody returns a body as stored in a cassette: redacted text, or
+// base64 for binary bodies such as protobuf messages.
+func cassetteBody(contentType string, body []byte) (string, string) {
+	if isBinary(contentType, body) {
+		return base64.StdEncoding.EncodeToString(body), "base64"
+	}
+	return redactBody(contentType, body), ""
+}
+
+// Replaying reports whether responses come from a CHP_REPLAY cassette. Tokens
+// in a cassette are redacted, so nothing received while replaying may be
+// saved as credentials.
+func Replaying() bool {
+	return os.Getenv(ReplayEnvVar) != ""
+}
+
+// cassetteTransport wraps next for recording or replay if the environment
+// asks for it, and returns next unchanged otherwise.
+func cassetteTransport(next http.RoundTripper) http.RoundTripper {
+	if Replaying() {
+		return replayerFor(os.Getenv(ReplayEnvVar), os.Getenv(ReplayMatchEnvVar))
+	}
+	if path := os.Getenv(RecordEnvVar); path != "" {
+		return &recorder{path: path, next: next}
+	}
+	return next
+}
+
+// recordRequest is the normalised, redacted form of req used both for
+// storing and for matching.
+func recordRequest(req *http.Request, body []byte) recordedRequest {
+	r := recordedRequest{
+		Method:  req.Method,
+		URL:     redactURL(req.URL),
+		Headers: redactHeaders(req.Header),
+	}
+	if len(body) > 0 {
+		r.Body, r.BodyEncoding = cassetteBody(req.Header.Get("Content-Type"), body)
+	}
+	return r
+}
+
+// recorder passes requests through and appends each exchange to a cassette.
+type recorder struct {
+	path string
+	next http.RoundTripper
+}
+
+// recordMu serialises cassette writes from all clients in the process.
+var recordMu sync.Mutex
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody := peekBody(req)
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	recorded := recordedResponse{Status: resp.StatusCode, Headers: redactHeaders(resp.Header)}
+	if len(respBody) > 0 {
+		recorded.Body, recorded.BodyEncoding = cassetteBody(resp.Header.Get("Content-Type"), respBody)
+	}
+	if err := r.append(interaction{Request: recordRequest(req, reqBody), Response: recorded}); err != nil {
+		return nil, fmt.Errorf("failed to record to %s: %w", r.path, err)
+	}
+	return resp, nil
+}
+
+// append adds an interaction to the cassette file. An existing cassette is
+// extended, so a script running several chp commands records one file.
+func (r *recorder) append(i interaction) error {
+	recordMu.Lock()
+	defer recordMu.Unlock()
+
+	var c cassette
+	if data, err := os.ReadFile(r.path); err == nil {
+		if err := json.Unmarshal(data, &c); err != nil {
+			return fmt.Errorf("existing cassette is not valid: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	c.Interactions = append(c.Interactions, i)
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, data, 0600)
+}
+
+// replayer serves responses from a cassette. Each interaction is used at
+// most once, in recorded order, so repeated identical requests get their
+// responses in sequence.
+type replayer struct {
+	path    string
+	match   []string
+	mu      sync.Mutex
+	loaded  bool
+	loadErr error
+	c       cassette
+	used    []bool
+}
+
+// replayers holds one replayer per cassette and match setting, shared by
+// all clients in the process so interactions aren't served twice.
+var replayers sync.Map
+
+func replayerFor(path, match string) *replayer {
+	fields := matchFields
+	if match != "" {
+		fields = nil
+		for _, f := range strings.Split(match, ",") {
+			fields = append(fields, strings.TrimSpace(f))
+		}
+	}
+	r, _ := replayers.LoadOrStore(path+"|"+strings.Join(fields, ","), &replayer{path: path, match: fields})
+	return r.(*replayer)
+}
+
+func (r *replayer) load() error {
+	if r.loaded {
+		return r.loadErr
+	}
+	r.loaded = true
+	for _, f := range r.match {
+		if !slices.Contains(matchFields, f) {
+			r.loadErr = fmt.Errorf("invalid %s field %q (available: %s)", ReplayMatchEnvVar, f, strings.Join(matchFields, ", "))
+			return r.loadErr
+		}
+	}
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		r.loadErr = fmt.Errorf("failed to read cassette: %w", err)
+		return r.loadErr
+	}
+	if err := json.Unmarshal(data, &r.c); err != nil {
+		r.loadErr = fmt.Errorf("invalid cassette %s: %w", r.path, err)
+		return r.loadErr
+	}
+	r.used = make([]bool, len(r.c.Interactions))
+	return nil
+}
+
+func (r *replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.load(); err != nil {
+		return nil, &replayError{err}
+	}
+
+	want := recordRequest(req, peekBody(req))
+	for i, recorded := range r.c.Interactions {
+		if r.used[i] || !r.matches(recorded.Request, want) {
+			continue
+		}
+		resp := recorded.Response
+		body := []byte(resp.Body)
+		if resp.BodyEncoding == "base64" {
+			decoded, err := base64.StdEncoding.DecodeString(resp.Body)
+			if err != nil {
+				return nil, &replayError{fmt.Errorf("cassette %s: response body of %s %s: %w", r.path, req.Method, want.URL, err)}
+			}
+			body = decoded
+		}
+		r.used[i] = true
+		return &http.Response{
+			StatusCode:    resp.Status,
+			Status:        fmt.Sprintf("%d %s", resp.Status, http.StatusText(resp.Status)),
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        resp.Headers.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, &replayError{fmt.Errorf("%w: %s %s (cassette %s, matching on %s)",
+		ErrUnmatchedRequest, req.Method, want.URL, r.path, strings.Join(r.match, ","))}
+}
+
+func (r *replayer) matches(recorded, want recordedRequest) bool {
+	for _, f := range r.match {
+		switch f {
+		case "method":
+			if recorded.Method != want.Method {
+				return false
+			}
+		case "url":
+			if recorded.URL != want.URL {
+				return false
+			}
+		case "body":
+			if recorded.Body != want.Body {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,140 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordServer answers every request with a numbered JSON body.
+func recordServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "cookie-secret"})
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(404)
+		}
+		w.Write([]byte(`{"n":` + string(rune('0'+n)) + `,"access_token":"issued-secret"}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestCassette_RecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	srv, requests := recordServer(t)
+	auth := map[string]string{"Authorization": "Bearer bearer-secret"}
+
+	t.Setenv(RecordEnvVar, path)
+	c := New()
+	_, err := c.PostJSON(t.Context(), srv.URL+"/rpc", map[string]any{"b": 2, "a": 1}, auth)
+	require.NoError(t, err)
+	_, err = c.PostJSON(t.Context(), srv.URL+"/rpc", map[string]any{"b": 2, "a": 1}, auth)
+	require.NoError(t, err)
+	_, err = New().GetJSON(t.Context(), srv.URL+"/missing", nil)
+	require.Error(t, err)
+	assert.Equal(t, int32(3), requests.Load())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	for _, secret := range []string{"bearer-secret", "cookie-secret", "issued-secret"} {
+		assert.NotContains(t, string(data), secret)
+	}
+
+	t.Setenv(RecordEnvVar, "")
+	t.Setenv(ReplayEnvVar, path)
+	c = New()
+	// Key order and whitespace don't matter: bodies are JSON-normalised.
+	body, err := c.PostJSON(t.Context(), srv.URL+"/rpc", map[string]any{"a": 1, "b": 2}, nil)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"n":1,"access_token":"[REDACTED]"}`, string(body))
+	body, err = New().PostJSON(t.Context(), srv.URL+"/rpc", map[string]any{"a": 1, "b": 2}, nil)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"n":2,"access_token":"[REDACTED]"}`, string(body), "repeated requests replay in order")
+
+	_, err = c.GetJSON(t.Context(), srv.URL+"/missing", nil)
+	require.Error(t, err)
+	assert.Equal(t, 404, err.(*APIError).StatusCode)
+	assert.Equal(t, int32(3), requests.Load(), "replay never touches the network")
+}
+
+func TestCassette_UnmatchedRequestFailsLoudly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	srv, _ := recordServer(t)
+
+	t.Setenv(RecordEnvVar, path)
+	_, err := New().PostJSON(t.Context(), srv.URL+"/rpc", map[string]any{"a": 1}, nil)
+	require.NoError(t, err)
+
+	t.Setenv(RecordEnvVar, "")
+	t.Setenv(ReplayEnvVar, path)
+	slept = nil
+	_, err = New().PostJSONIdempotent(t.Context(), srv.URL+"/rpc", map[string]any{"a": 2}, nil)
+	require.ErrorIs(t, err, ErrUnmatchedRequest)
+	assert.Contains(t, err.Error(), "POST "+srv.URL+"/rpc")
+	assert.Contains(t, err.Error(), "matching on method,url,body")
+	assert.Empty(t, slept, "an unmatched request is not retried")
+}
+
+func TestCassette_MatchFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	srv, _ := recordServer(t)
+
+	t.Setenv(RecordEnvVar, path)
+	_, err := New().PostForm(t.Context(), srv.URL+"/token", url.Values{"refresh_token": {"one"}}, nil)
+	require.NoError(t, err)
+
+	t.Setenv(RecordEnvVar, "")
+	t.Setenv(ReplayEnvVar, path)
+	t.Setenv(ReplayMatchEnvVar, "method,url")
+	_, err = New().PostJSON(t.Context(), srv.URL+"/token", map[string]any{"other": "body"}, nil)
+	assert.NoError(t, err, "body is ignored")
+
+	t.Setenv(ReplayMatchEnvVar, "method,headers")
+	_, err = New().PostJSON(t.Context(), srv.URL+"/token", nil, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid CHP_REPLAY_MATCH field "headers"`)
+}
+
+func TestCassette_MissingFile(t *testing.T) {
+	t.Setenv(ReplayEnvVar, filepath.Join(t.TempDir(), "nope.json"))
+	_, err := New().GetJSON(t.Context(), "https://example.com/", nil)
+	require.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "failed to read cassette"), err.Error())
+}
+
+func TestCassette_BinaryBodies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	reqBody := []byte{0x0a, 0x03, 'e', 'g', 'g', 0xff}
+	respBody := []byte{0x08, 0x96, 0x01, 0x12, 0x00}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/protobuf")
+		w.Write(respBody)
+	}))
+	defer srv.Close()
+
+	t.Setenv(RecordEnvVar, path)
+	body, err := New().Post(t.Context(), srv.URL+"/rpc", "application/protobuf", reqBody, nil)
+	require.NoError(t, err)
+	assert.Equal(t, respBody, body)
+
+	t.Setenv(RecordEnvVar, "")
+	t.Setenv(ReplayEnvVar, path)
+	body, err = New().Post(t.Context(), srv.URL+"/rpc", "application/protobuf", reqBody, nil)
+	require.NoError(t, err)
+	assert.Equal(t, respBody, body, "binary bodies survive the round trip")
+
+	_, err = New().Post(t.Context(), srv.URL+"/rpc", "application/protobuf", []byte{0x0a, 0x01, 'x'}, nil)
+	assert.ErrorIs(t, err, ErrUnmatchedRequest, "binary bodies are matched too")
+}
@@ -2,8 +2,9 @@ package httpclient
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -23,57 +24,117 @@ func SetUserAgent(ua string) {
 type APIError struct {
 	StatusCode int
 	Message    string
+	Header     http.Header // response headers, nil if no response was received
 }
 
 func (e *APIError) Error() string {
 	return e.Message
 }
 
-// Client wraps net/http with localhost TLS skip and error parsing.
+// Client wraps net/http with error parsing, retries of transient failures
+// and the opt-in localhost TLS skip.
 type Client struct {
+	Retry RetryPolicy
+
 	standard *http.Client
 	insecure *http.Client
 }
 
-// New creates a Client with 30s timeout and a separate insecure transport for localhost.
+// New creates a Client with 30s timeout, using the settings from
+// ConfigureTLS and the proxy environment variables. Requests are recorded or
+// replayed if CHP_RECORD or CHP_REPLAY is set (see RecordEnvVar).
 func New() *Client {
+	insecure := tlsConfig.Clone()
+	insecure.InsecureSkipVerify = true
 	return &Client{
-		standard: &http.Client{Timeout: 30 * time.Second},
-		insecure: &http.Client{
-			Timeout: 30 * time.Second,
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-			},
-		},
+		Retry:    DefaultRetryPolicy,
+		standard: &http.Client{Timeout: 30 * time.Second, Transport: newTransport(tlsConfig.Clone())},
+		insecure: &http.Client{Timeout: 30 * time.Second, Transport: newTransport(insecure)},
 	}
 }
 
-func (c *Client) clientFor(rawURL string) *http.Client {
-	u, err := url.Parse(rawURL)
-	if err != nil {
-		return c.standard
-	}
-	host := u.Hostname()
-	if host == "localhost" || host == "127.0.0.1" {
+// clientFor picks the client for a URL: the one that skips certificate
+// verification is only used for loopback hosts, and only with --insecure.
+func (c *Client) clientFor(u *url.URL) *http.Client {
+	if insecureLoopback && isLoopback(u.Hostname()) {
+		if u.Scheme == "https" {
+			warnInsecure(u.Hostname())
+		}
 		return c.insecure
 	}
 	return c.standard
 }
 
-func (c *Client) do(req *http.Request) ([]byte, *http.Response, error) {
+// do sends a request, retrying transient failures per c.Retry if the
+// request is idempotent (or retryable is set by the caller).
+func (c *Client) do(req *http.Request, retryable bool) ([]byte, *http.Response, error) {
 	req.Header.Set("User-Agent", userAgent)
+	retry := isIdempotent(req, retryable)
 
-	client := c.clientFor(req.URL.String())
+	var reqBody []byte
+	if tracing(req.Context()) {
+		reqBody = peekBody(req)
+	}
+
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		body, resp, err := c.doOnce(req)
+		logExchange(req, reqBody, resp, body, err, time.Since(start))
+		if !retry || attempt >= c.Retry.MaxRetries || (req.Body != nil && req.GetBody == nil) {
+			return body, resp, err
+		}
+
+		var reason string
+		switch {
+		case resp == nil && errors.As(err, new(*APIError)):
+			reason = "connection failed"
+		case resp != nil && retryableStatus(resp.StatusCode):
+			reason = resp.Status
+		default:
+			return body, resp, err
+		}
+		delay, ok := c.Retry.backoff(attempt, resp)
+		if !ok {
+			return body, resp, err
+		}
+		logRetry(req, attempt, delay, reason)
+		if err := sleep(req.Context(), delay); err != nil {
+			return nil, nil, err
+		}
+
+		if req.GetBody != nil {
+			newBody, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return body, resp, err
+			}
+			req.Body = newBody
+		}
+	}
+}
+
+func (c *Client) doOnce(req *http.Request) ([]byte, *http.Response, error) {
+	client := c.clientFor(req.URL)
 	resp, err := client.Do(req)
 	if err != nil {
+		if ctxErr := req.Context().Err(); ctxErr != nil {
+			// Cancelled or timed out by the caller: not a network problem.
+			return nil, nil, ctxErr
+		}
+		var replayErr *replayError
+		if errors.As(err, &replayErr) {
+			return nil, nil, replayErr.err
+		}
 		return nil, nil, &APIError{
-			Message: fmt.Sprintf("Connection failed: %v\nCheck your network and base URL: %s", err, req.URL.String()),
+			Message: fmt.Sprintf("Connection failed: %v\nCheck your network and base URL: %s%s", err, req.URL.String(), certificateHint(err, req.URL.Hostname())),
 		}
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
+		if ctxErr := req.Context().Err(); ctxErr != nil {
+			return nil, resp, ctxErr
+		}
 		return nil, resp, &APIError{
 			Message: fmt.Sprintf("Failed to read response: %v", err),
 		}
@@ -84,6 +145,7 @@ func (c *Client) do(req *http.Request) ([]byte, *http.Response, error) {
 		return body, resp, &APIError{
 			StatusCode: resp.StatusCode,
 			Message:    fmt.Sprintf("HTTP %d: %s", resp.StatusCode, msg),
+			Header:     resp.Header,
 		}
 	}
 
@@ -103,57 +165,75 @@ func parseErrorBody(body []byte) string {
 	return string(body)
 }
 
-// GetJSON performs a GET request and returns the response body.
-func (c *Client) GetJSON(rawURL string, headers map[string]string) ([]byte, error) {
-	req, err := http.NewRequest("GET", rawURL, nil)
+// GetJSON performs a GET request and returns the response body. Like every
+// request method it stops when ctx is cancelled, returning ctx's error.
+func (c *Client) GetJSON(ctx context.Context, rawURL string, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
 	if err != nil {
 		return nil, err
 	}
 	for k, v := range headers {
 		req.Header.Set(k, v)
 	}
-	body, _, err := c.do(req)
+	body, _, err := c.do(req, false)
 	return body, err
 }
 
 // PostJSON sends a JSON-encoded payload and returns the response body.
-func (c *Client) PostJSON(rawURL string, payload any, headers map[string]string) ([]byte, error) {
-	data, err := json.Marshal(payload)
-	if err != nil {
-		return nil, err
-	}
-	req, err := http.NewRequest("POST", rawURL, bytes.NewReader(data))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	for k, v := range headers {
-		req.Header.Set(k, v)
-	}
-	body, _, err := c.do(req)
+// It is retried only if an Idempotency-Key header is attached.
+func (c *Client) PostJSON(ctx context.Context, rawURL string, payload any, headers map[string]string) ([]byte, error) {
+	body, _, err := c.postJSON(ctx, rawURL, payload, headers, false)
+	return body, err
+}
+
+// PostJSONIdempotent is PostJSON for requests that are safe to repeat, such
+// as read-only RPCs: transient failures are retried.
+func (c *Client) PostJSONIdempotent(ctx context.Context, rawURL string, payload any, headers map[string]string) ([]byte, error) {
+	body, _, err := c.postJSON(ctx, rawURL, payload, headers, true)
 	return body, err
 }
 
 // PostJSONRaw sends JSON and returns both body and the raw http.Response.
-func (c *Client) PostJSONRaw(rawURL string, payload any, headers map[string]string) ([]byte, *http.Response, error) {
+func (c *Client) PostJSONRaw(ctx context.Context, rawURL string, payload any, headers map[string]string) ([]byte, *http.Response, error) {
+	return c.postJSON(ctx, rawURL, payload, headers, false)
+}
+
+func (c *Client) postJSON(ctx context.Context, rawURL string, payload any, headers map[string]string, retryable bool) ([]byte, *http.Response, error) {
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return nil, nil, err
 	}
-	req, err := http.NewRequest("POST", rawURL, bytes.NewReader(data))
+	return c.post(ctx, rawURL, "application/json", data, headers, retryable)
+}
+
+// Post sends body with the given content type and returns the response body.
+func (c *Client) Post(ctx context.Context, rawURL, contentType string, body []byte, headers map[string]string) ([]byte, error) {
+	respBody, _, err := c.post(ctx, rawURL, contentType, body, headers, false)
+	return respBody, err
+}
+
+// PostIdempotent is Post for requests that are safe to repeat: transient
+// failures are retried.
+func (c *Client) PostIdempotent(ctx context.Context, rawURL, contentType string, body []byte, headers map[string]string) ([]byte, error) {
+	respBody, _, err := c.post(ctx, rawURL, contentType, body, headers, true)
+	return respBody, err
+}
+
+func (c *Client) post(ctx context.Context, rawURL, contentType string, body []byte, headers map[string]string, retryable bool) ([]byte, *http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", rawURL, bytes.NewReader(body))
 	if err != nil {
 		return nil, nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", contentType)
 	for k, v := range headers {
 		req.Header.Set(k, v)
 	}
-	return c.do(req)
+	return c.do(req, retryable)
 }
 
 // PostForm sends a form-encoded POST and returns the response body.
-func (c *Client) PostForm(rawURL string, params url.Values, headers map[string]string) ([]byte, error) {
-	req, err := http.NewRequest("POST", rawURL, strings.NewReader(params.Encode()))
+func (c *Client) PostForm(ctx context.Context, rawURL string, params url.Values, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", rawURL, strings.NewReader(params.Encode()))
 	if err != nil {
 		return nil, err
 	}
@@ -161,6 +241,19 @@ func (c *Client) PostForm(rawURL string, params url.Values, headers map[string]s
 	for k, v := range headers {
 		req.Header.Set(k, v)
 	}
-	body, _, err := c.do(req)
+	body, _, err := c.do(req, false)
+	return body, err
+}
+
+// Delete performs a DELETE request and returns the response body.
+func (c *Client) Delete(ctx context.Context, rawURL string, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	body, _, err := c.do(req, false)
 	return body, err
 }
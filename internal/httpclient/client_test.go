@@ -1,10 +1,12 @@
 package httpclient
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -20,7 +22,7 @@ func TestGetJSON_Success(t *testing.T) {
 	defer srv.Close()
 
 	c := New()
-	body, err := c.GetJSON(srv.URL+"/test", nil)
+	body, err := c.GetJSON(t.Context(), srv.URL+"/test", nil)
 	require.NoError(t, err)
 
 	var result map[string]string
@@ -42,7 +44,7 @@ func TestPostJSON_Success(t *testing.T) {
 	defer srv.Close()
 
 	c := New()
-	body, err := c.PostJSON(srv.URL, map[string]string{"foo": "bar"}, nil)
+	body, err := c.PostJSON(t.Context(), srv.URL, map[string]string{"foo": "bar"}, nil)
 	require.NoError(t, err)
 
 	var result map[string]string
@@ -58,7 +60,7 @@ func TestAPIError_OnNon2xx(t *testing.T) {
 	defer srv.Close()
 
 	c := New()
-	_, err := c.GetJSON(srv.URL, nil)
+	_, err := c.GetJSON(t.Context(), srv.URL, nil)
 	require.Error(t, err)
 
 	apiErr, ok := err.(*APIError)
@@ -75,7 +77,7 @@ func TestAPIError_MsgField(t *testing.T) {
 	defer srv.Close()
 
 	c := New()
-	_, err := c.GetJSON(srv.URL, nil)
+	_, err := c.GetJSON(t.Context(), srv.URL, nil)
 	apiErr := err.(*APIError)
 	assert.Contains(t, apiErr.Message, "server exploded")
 }
@@ -88,7 +90,7 @@ func TestAPIError_PlainText(t *testing.T) {
 	defer srv.Close()
 
 	c := New()
-	_, err := c.GetJSON(srv.URL, nil)
+	_, err := c.GetJSON(t.Context(), srv.URL, nil)
 	apiErr := err.(*APIError)
 	assert.Contains(t, apiErr.Message, "Service Unavailable")
 }
@@ -101,7 +103,7 @@ func TestCustomHeaders(t *testing.T) {
 	defer srv.Close()
 
 	c := New()
-	_, err := c.PostJSON(srv.URL, nil, map[string]string{
+	_, err := c.PostJSON(t.Context(), srv.URL, nil, map[string]string{
 		"Authorization": "Bearer tok123",
 	})
 	require.NoError(t, err)
@@ -118,6 +120,22 @@ func TestUserAgent(t *testing.T) {
 	defer srv.Close()
 
 	c := New()
-	_, err := c.GetJSON(srv.URL, nil)
+	_, err := c.GetJSON(t.Context(), srv.URL, nil)
 	require.NoError(t, err)
 }
+
+func TestCancelledRequest_ReturnsContextError(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+	_, err := New().PostJSON(ctx, srv.URL, map[string]any{}, nil)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NotContains(t, err.Error(), "Connection failed", "a timeout is not a network problem")
+}
@@ -0,0 +1,190 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// LevelTrace is below slog.LevelDebug: at this level full request and
+// response headers and bodies are logged, with secrets redacted.
+const LevelTrace = slog.LevelDebug - 4
+
+const redacted = "[REDACTED]"
+
+// sensitiveHeaders are logged with their value redacted. For Authorization
+// the scheme is kept, so Bearer and DPoP tokens can be told apart.
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "Dpop"}
+
+// sensitiveParams are form fields, query parameters and JSON keys whose
+// values are redacted wherever they appear.
+var sensitiveParams = map[string]bool{
+	"access_token":              true,
+	"refresh_token":             true,
+	"id_token":                  true,
+	"code_verifier":             true,
+	"device_code":               true,
+	"client_secret":             true,
+	"registration_access_token": true,
+	"password":                  true,
+}
+
+// oauthParams are redacted only in form-encoded bodies and query strings,
+// where they are an authorization code or a token being revoked. In JSON
+// they are ordinary keys: "code" is the code of every Twirp error.
+var oauthParams = map[string]bool{
+	"code":  true,
+	"token": true,
+}
+
+// logExchange logs one HTTP attempt at debug level, and at trace level its
+// headers and bodies. reqBody is only read when tracing.
+func logExchange(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, err error, elapsed time.Duration) {
+	ctx := req.Context()
+	logger := slog.Default()
+	if !logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", redactURL(req.URL)),
+		slog.Duration("duration", elapsed.Round(time.Millisecond)),
+	}
+	if resp != nil {
+		attrs = append(attrs, slog.Int("status", resp.StatusCode), slog.Int("bytes", len(respBody)))
+	}
+	if err != nil && resp == nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	logger.LogAttrs(ctx, slog.LevelDebug, "http request", attrs...)
+
+	if !logger.Enabled(ctx, LevelTrace) {
+		return
+	}
+	trace := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", redactURL(req.URL)),
+		slog.Any("request_headers", redactHeaders(req.Header)),
+	}
+	if len(reqBody) > 0 {
+		trace = append(trace, slog.String("request_body", redactBody(req.Header.Get("Content-Type"), reqBody)))
+	}
+	if resp != nil {
+		trace = append(trace, slog.Any("response_headers", redactHeaders(resp.Header)))
+		if len(respBody) > 0 {
+			trace = append(trace, slog.String("response_body", redactBody(resp.Header.Get("Content-Type"), respBody)))
+		}
+	}
+	logger.LogAttrs(ctx, LevelTrace, "http exchange", trace...)
+}
+
+// tracing reports whether bodies will be logged for requests made with ctx.
+func tracing(ctx context.Context) bool {
+	return slog.Default().Enabled(ctx, LevelTrace)
+}
+
+// peekBody returns a copy of the request body for logging, leaving the
+// request untouched.
+func peekBody(req *http.Request) []byte {
+	if req.GetBody == nil {
+		return nil
+	}
+	rc, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	defer rc.Close()
+	data, _ := io.ReadAll(rc)
+	return data
+}
+
+// redactHeaders returns a copy of h with credentials replaced.
+func redactHeaders(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range sensitiveHeaders {
+		values := out.Values(name)
+		for i, v := range values {
+			if scheme, _, ok := strings.Cut(v, " "); ok && (name == "Authorization" || name == "Proxy-Authorization") {
+				values[i] = scheme + " " + redacted
+			} else {
+				values[i] = redacted
+			}
+		}
+	}
+	return out
+}
+
+// redactURL returns u with its password and any sensitive query parameters
+// redacted.
+func redactURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Redacted()
+	}
+	copied := *u
+	copied.RawQuery = redactValues(u.Query()).Encode()
+	return copied.Redacted()
+}
+
+// redactBody returns a loggable form of a JSON or form-encoded body with
+// sensitive fields redacted. Binary bodies are summarised; other bodies are
+// returned as is.
+func redactBody(contentType string, body []byte) string {
+	if isBinary(contentType, body) {
+		return fmt.Sprintf("(%d bytes of %s)", len(body), contentType)
+	}
+	switch {
+	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+		if values, err := url.ParseQuery(string(body)); err == nil {
+			return redactValues(values).Encode()
+		}
+	case strings.Contains(contentType, "json") || json.Valid(body):
+		var v any
+		if json.Unmarshal(body, &v) == nil {
+			if data, err := json.Marshal(redactJSON(v)); err == nil {
+				return string(data)
+			}
+		}
+	}
+	return string(body)
+}
+
+// isBinary reports whether a body is binary, such as a protobuf message,
+// rather than text.
+func isBinary(contentType string, body []byte) bool {
+	return strings.Contains(contentType, "protobuf") || strings.HasPrefix(contentType, "application/octet-stream") || !utf8.Valid(body)
+}
+
+func redactValues(values url.Values) url.Values {
+	for k := range values {
+		if sensitiveParams[k] || oauthParams[k] {
+			values[k] = []string{redacted}
+		}
+	}
+	return values
+}
+
+func redactJSON(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			if sensitiveParams[k] {
+				v[k] = redacted
+			} else {
+				v[k] = redactJSON(child)
+			}
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = redactJSON(child)
+		}
+	}
+	return v
+}
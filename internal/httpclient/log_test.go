@@ -0,0 +1,135 @@
+package httpclient
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureLogs routes slog to a buffer at level for the test.
+func captureLogs(t *testing.T, level slog.Level) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	orig := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: level})))
+	t.Cleanup(func() { slog.SetDefault(orig) })
+	return &buf
+}
+
+func TestRedactHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set("Authorization", "Bearer secret-token")
+	h.Set("DPoP", "eyJproof")
+	h.Add("Set-Cookie", "session=abc; Path=/")
+	h.Set("Content-Type", "application/json")
+
+	got := redactHeaders(h)
+	assert.Equal(t, "Bearer [REDACTED]", got.Get("Authorization"))
+	assert.Equal(t, "[REDACTED]", got.Get("DPoP"))
+	assert.Equal(t, "[REDACTED]", got.Get("Set-Cookie"))
+	assert.Equal(t, "application/json", got.Get("Content-Type"))
+	assert.Equal(t, "Bearer secret-token", h.Get("Authorization"), "original is untouched")
+}
+
+func TestRedactBody(t *testing.T) {
+	form := redactBody("application/x-www-form-urlencoded", []byte("grant_type=refresh_token&refresh_token=r1&code_verifier=v1&client_id=c"))
+	values, err := url.ParseQuery(form)
+	require.NoError(t, err)
+	assert.Equal(t, "refresh_token", values.Get("grant_type"))
+	assert.Equal(t, "[REDACTED]", values.Get("refresh_token"))
+	assert.Equal(t, "[REDACTED]", values.Get("code_verifier"))
+	assert.Equal(t, "c", values.Get("client_id"))
+
+	jsonBody := redactBody("application/json", []byte(`{"access_token":"a1","nested":[{"refresh_token":"r1","name":"x"}]}`))
+	assert.JSONEq(t, `{"access_token":"[REDACTED]","nested":[{"refresh_token":"[REDACTED]","name":"x"}]}`, jsonBody)
+
+	form = redactBody("application/x-www-form-urlencoded", []byte("grant_type=authorization_code&code=auth-code"))
+	assert.NotContains(t, form, "auth-code", "authorization codes are redacted in OAuth forms")
+	twirpError := redactBody("application/json", []byte(`{"code":"not_found","msg":"no such product"}`))
+	assert.JSONEq(t, `{"code":"not_found","msg":"no such product"}`, twirpError, "Twirp error codes are kept")
+
+	assert.Equal(t, "plain text", redactBody("text/plain", []byte("plain text")))
+	assert.Equal(t, "(3 bytes of application/protobuf)", redactBody("application/protobuf", []byte{0x08, 0x96, 0x01}))
+}
+
+func TestRedactURL(t *testing.T) {
+	u, _ := url.Parse("https://user:pw@example.com/callback?code=abc&state=xyz")
+	got := redactURL(u)
+	assert.NotContains(t, got, "pw")
+	assert.NotContains(t, got, "abc")
+	assert.Contains(t, got, "state=xyz")
+}
+
+func TestLogging_Debug(t *testing.T) {
+	logs := captureLogs(t, slog.LevelDebug)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access_token":"issued-secret"}`))
+	}))
+	defer srv.Close()
+
+	_, err := New().PostForm(t.Context(), srv.URL+"/token", url.Values{"refresh_token": {"rt-secret"}}, map[string]string{"Authorization": "Bearer bearer-secret"})
+	require.NoError(t, err)
+
+	out := logs.String()
+	assert.Contains(t, out, "http request")
+	assert.Contains(t, out, "method=POST")
+	assert.Contains(t, out, "status=200")
+	assert.Contains(t, out, "bytes=32")
+	assert.Contains(t, out, "duration=")
+	assert.NotContains(t, out, "http exchange", "headers and bodies only at trace level")
+}
+
+func TestLogging_TraceRedactsSecrets(t *testing.T) {
+	logs := captureLogs(t, LevelTrace)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "cookie-secret"})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"issued-secret","user":"alice"}`))
+	}))
+	defer srv.Close()
+
+	_, err := New().PostForm(t.Context(), srv.URL+"/token", url.Values{
+		"refresh_token": {"rt-secret"},
+		"code_verifier": {"verifier-secret"},
+		"client_id":     {"client-1"},
+	}, map[string]string{"Authorization": "Bearer bearer-secret"})
+	require.NoError(t, err)
+
+	out := logs.String()
+	assert.Contains(t, out, "http exchange")
+	assert.Contains(t, out, "client_id=client-1", "non-secret fields are kept")
+	assert.Contains(t, out, "alice")
+	for _, secret := range []string{"rt-secret", "verifier-secret", "bearer-secret", "issued-secret", "cookie-secret"} {
+		assert.NotContains(t, out, secret)
+	}
+}
+
+func TestLogging_TraceKeepsTwirpErrors(t *testing.T) {
+	logs := captureLogs(t, LevelTrace)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(404)
+		w.Write([]byte(`{"code":"not_found","msg":"no such product"}`))
+	}))
+	defer srv.Close()
+
+	_, err := New().Post(t.Context(), srv.URL+"/twirp/Get", "application/json", []byte(`{"uid":"1"}`), nil)
+	require.Error(t, err)
+	assert.Contains(t, logs.String(), `\"code\":\"not_found\"`)
+}
+
+func TestLogging_Off(t *testing.T) {
+	logs := captureLogs(t, slog.LevelInfo)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	_, err := New().GetJSON(t.Context(), srv.URL, nil)
+	require.NoError(t, err)
+	assert.Empty(t, logs.String())
+}
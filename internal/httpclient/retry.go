@@ -0,0 +1,113 @@
+package httpclient
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// IdempotencyKeyHeader marks a POST as safe to retry: the server deduplicates
+// requests carrying the same key.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// RetryPolicy controls automatic retries of transient failures: connection
+// errors, 429 and 5xx responses. Only idempotent requests are retried.
+type RetryPolicy struct {
+	MaxRetries int           // retries after the first attempt; 0 disables
+	BaseDelay  time.Duration // first backoff, doubled on each retry
+	MaxDelay   time.Duration // cap on backoff
+}
+
+// DefaultRetryPolicy is used by clients created with New.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	BaseDelay:  500 * time.Millisecond,
+	MaxDelay:   10 * time.Second,
+}
+
+// maxRetryAfter caps how long a server's Retry-After is honoured. A server
+// asking for a longer pause gets an error instead of a hung CLI.
+const maxRetryAfter = 2 * time.Minute
+
+// sleep waits for d, returning early with ctx's error if it is cancelled.
+// It is replaced in tests.
+var sleep = func(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SetMaxRetries sets the retry count for clients created after the call.
+func SetMaxRetries(n int) {
+	DefaultRetryPolicy.MaxRetries = max(n, 0)
+}
+
+// retryableStatus reports whether a response status is worth retrying.
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// isIdempotent reports whether a request may be sent again automatically.
+func isIdempotent(req *http.Request, retryable bool) bool {
+	if retryable || req.Header.Get(IdempotencyKeyHeader) != "" {
+		return true
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// backoff returns the delay before retry number attempt (0-based): jittered
+// exponential backoff, or the server's Retry-After on 429/503. ok is false if
+// the server asked to wait longer than maxRetryAfter.
+func (p RetryPolicy) backoff(attempt int, resp *http.Response) (delay time.Duration, ok bool) {
+	if resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		if d, found := parseRetryAfter(resp.Header.Get("Retry-After")); found {
+			return d, d <= maxRetryAfter
+		}
+	}
+	d := p.BaseDelay << attempt
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	// Equal jitter: half fixed, half random, so parallel clients spread out.
+	return d/2 + rand.N(d/2+1), true
+}
+
+// parseRetryAfter parses a Retry-After value in seconds or as an HTTP date.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+func logRetry(req *http.Request, attempt int, delay time.Duration, reason string) {
+	slog.Debug("retrying request",
+		"method", req.Method,
+		"url", redactURL(req.URL),
+		"attempt", attempt+1,
+		"delay", delay.Round(time.Millisecond),
+		"reason", reason)
+}
@@ -0,0 +1,163 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slept records backoff delays instead of sleeping.
+var slept []time.Duration
+
+func TestMain(m *testing.M) {
+	sleep = func(_ context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+	os.Exit(m.Run())
+}
+
+// flakyServer fails the first n requests with status, then succeeds.
+func flakyServer(t *testing.T, n int32, status int, header http.Header) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	slept = nil
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := make([]byte, 64)
+		k, _ := r.Body.Read(body)
+		if r.Header.Get("Content-Type") == "application/json" {
+			assert.Equal(t, `{"a":1}`, string(body[:k]), "body is resent on every attempt")
+		}
+		if requests.Add(1) <= n {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestRetry_GetRetriesTransientErrors(t *testing.T) {
+	srv, requests := flakyServer(t, 2, 502, nil)
+	body, err := New().GetJSON(t.Context(), srv.URL, nil)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"ok":true}`, string(body))
+	assert.Equal(t, int32(3), requests.Load())
+	require.Len(t, slept, 2)
+	assert.GreaterOrEqual(t, slept[0], 250*time.Millisecond)
+	assert.LessOrEqual(t, slept[0], 500*time.Millisecond)
+	assert.GreaterOrEqual(t, slept[1], 500*time.Millisecond, "backoff grows")
+	assert.LessOrEqual(t, slept[1], time.Second)
+}
+
+func TestRetry_GivesUpAfterMaxRetries(t *testing.T) {
+	srv, requests := flakyServer(t, 100, 500, nil)
+	c := New()
+	c.Retry.MaxRetries = 2
+	_, err := c.GetJSON(t.Context(), srv.URL, nil)
+	require.Error(t, err)
+	assert.Equal(t, 500, err.(*APIError).StatusCode)
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func TestRetry_HonoursRetryAfter(t *testing.T) {
+	srv, requests := flakyServer(t, 1, 429, http.Header{"Retry-After": {"7"}})
+	_, err := New().GetJSON(t.Context(), srv.URL, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), requests.Load())
+	assert.Equal(t, []time.Duration{7 * time.Second}, slept)
+}
+
+func TestRetry_RetryAfterTooLongFailsFast(t *testing.T) {
+	srv, requests := flakyServer(t, 1, 503, http.Header{"Retry-After": {"3600"}})
+	_, err := New().GetJSON(t.Context(), srv.URL, nil)
+	require.Error(t, err)
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestRetry_PostNotRetriedByDefault(t *testing.T) {
+	srv, requests := flakyServer(t, 1, 503, nil)
+	_, err := New().PostJSON(t.Context(), srv.URL, map[string]int{"a": 1}, nil)
+	require.Error(t, err)
+	assert.Equal(t, int32(1), requests.Load(), "a write may have been applied; don't repeat it")
+
+	srv, requests = flakyServer(t, 1, 503, nil)
+	_, err = New().PostForm(t.Context(), srv.URL, url.Values{}, nil)
+	require.Error(t, err)
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestRetry_PostWithIdempotencyKey(t *testing.T) {
+	srv, requests := flakyServer(t, 1, 503, nil)
+	_, err := New().PostJSON(t.Context(), srv.URL, map[string]int{"a": 1}, map[string]string{IdempotencyKeyHeader: "k-1"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestRetry_PostJSONIdempotent(t *testing.T) {
+	srv, requests := flakyServer(t, 1, 504, nil)
+	_, err := New().PostJSONIdempotent(t.Context(), srv.URL, map[string]int{"a": 1}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestRetry_ClientErrorsNotRetried(t *testing.T) {
+	srv, requests := flakyServer(t, 1, 404, nil)
+	_, err := New().GetJSON(t.Context(), srv.URL, nil)
+	require.Error(t, err)
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestRetry_ConnectionFailure(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	addr := srv.URL
+	srv.Close()
+
+	slept = nil
+	c := New()
+	c.Retry.MaxRetries = 1
+	_, err := c.GetJSON(t.Context(), addr, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Connection failed")
+	assert.Len(t, slept, 1)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	d, ok := parseRetryAfter("120")
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Minute, d)
+
+	d, ok = parseRetryAfter(time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat))
+	assert.True(t, ok)
+	assert.InDelta(t, 30*time.Second, d, float64(2*time.Second))
+
+	_, ok = parseRetryAfter("soon")
+	assert.False(t, ok)
+}
+
+func TestRetry_StopsWhenContextCancelled(t *testing.T) {
+	srv, requests := flakyServer(t, 100, 503, nil)
+	ctx, cancel := context.WithCancel(t.Context())
+	orig := sleep
+	sleep = func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return orig(ctx, d)
+	}
+	t.Cleanup(func() { sleep = orig })
+
+	_, err := New().GetJSON(ctx, srv.URL, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int32(1), requests.Load(), "no retry after cancellation")
+}
@@ -0,0 +1,104 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Environment variables read by the CLI for TLSOptions; the matching flags
+// take precedence.
+const (
+	CAFileEnvVar     = "CHP_CA_FILE"
+	ClientCertEnvVar = "CHP_CLIENT_CERT"
+	ClientKeyEnvVar  = "CHP_CLIENT_KEY"
+)
+
+// TLSOptions configures certificate handling for clients created by New.
+type TLSOptions struct {
+	CAFile   string // PEM bundle trusted in addition to the system roots
+	CertFile string // PEM client certificate for mTLS
+	KeyFile  string // PEM private key for CertFile; defaults to CertFile
+	Insecure bool   // skip certificate verification for localhost and 127.0.0.1
+}
+
+var (
+	tlsConfig        = &tls.Config{}
+	insecureLoopback bool
+	insecureWarning  sync.Once
+)
+
+// ConfigureTLS applies opts to clients created after the call. Files are
+// read immediately, so a bad path or certificate is reported up front.
+func ConfigureTLS(opts TLSOptions) error {
+	cfg := &tls.Config{}
+	if opts.CAFile != "" {
+		data, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no PEM certificates found in CA file %s", opts.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if opts.CertFile == "" && opts.KeyFile != "" {
+		return errors.New("a client key was given without a client certificate")
+	}
+	if opts.CertFile != "" {
+		keyFile := opts.KeyFile
+		if keyFile == "" {
+			keyFile = opts.CertFile
+		}
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	tlsConfig = cfg
+	insecureLoopback = opts.Insecure
+	return nil
+}
+
+// newTransport returns a transport honouring HTTPS_PROXY, HTTP_PROXY and
+// NO_PROXY, with the given TLS settings.
+func newTransport(cfg *tls.Config) http.RoundTripper {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyFromEnvironment
+	t.TLSClientConfig = cfg
+	return cassetteTransport(t)
+}
+
+// warnInsecure warns, once per process, that verification is being skipped.
+func warnInsecure(host string) {
+	insecureWarning.Do(func() {
+		slog.Warn(fmt.Sprintf("TLS certificate verification is disabled for %s (--insecure).", host))
+	})
+}
+
+// certificateHint suggests a fix for a failed certificate verification.
+func certificateHint(err error, host string) string {
+	var verifyErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	if !errors.As(err, &verifyErr) && !errors.As(err, &unknownAuthority) {
+		return ""
+	}
+	if isLoopback(host) {
+		return "\nFor a local server with a self-signed certificate, use --insecure or --ca-file."
+	}
+	return "\nIf the server uses a private CA, pass its certificate with --ca-file or " + CAFileEnvVar + "."
+}
+
+func isLoopback(host string) bool {
+	return host == "localhost" || host == "127.0.0.1"
+}
@@ -0,0 +1,126 @@
+package httpclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// resetTLS restores the default TLS settings after the test.
+func resetTLS(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() { require.NoError(t, ConfigureTLS(TLSOptions{})) })
+}
+
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), blockType+".pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600))
+	return path
+}
+
+// newClientCert returns a self-signed client certificate and key as PEM files.
+func newClientCert(t *testing.T) (cert *x509.Certificate, certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "chp test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err = x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return cert, writePEM(t, "CERTIFICATE", der), writePEM(t, "PRIVATE KEY", keyDER)
+}
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(`{}`)) })
+}
+
+func TestTLS_LocalhostVerifiedByDefault(t *testing.T) {
+	resetTLS(t)
+	srv := httptest.NewTLSServer(okHandler())
+	defer srv.Close()
+
+	c := New()
+	c.Retry.MaxRetries = 0
+	_, err := c.GetJSON(t.Context(), srv.URL, nil)
+	require.Error(t, err, "self-signed localhost certificates are no longer trusted silently")
+	assert.Contains(t, err.Error(), "--insecure")
+
+	logs := captureLogs(t, slog.LevelWarn)
+	insecureWarning = sync.Once{}
+	require.NoError(t, ConfigureTLS(TLSOptions{Insecure: true}))
+	_, err = New().GetJSON(t.Context(), srv.URL, nil)
+	assert.NoError(t, err)
+	assert.Contains(t, logs.String(), "TLS certificate verification is disabled")
+}
+
+func TestTLS_CAFile(t *testing.T) {
+	resetTLS(t)
+	srv := httptest.NewTLSServer(okHandler())
+	defer srv.Close()
+
+	require.NoError(t, ConfigureTLS(TLSOptions{CAFile: writePEM(t, "CERTIFICATE", srv.Certificate().Raw)}))
+	_, err := New().GetJSON(t.Context(), srv.URL, nil)
+	assert.NoError(t, err)
+
+	err = ConfigureTLS(TLSOptions{CAFile: writePEM(t, "NOTHING", []byte("x"))})
+	assert.ErrorContains(t, err, "no PEM certificates")
+}
+
+func TestTLS_ClientCertificate(t *testing.T) {
+	resetTLS(t)
+	clientCert, certFile, keyFile := newClientCert(t)
+
+	srv := httptest.NewUnstartedServer(okHandler())
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: x509.NewCertPool()}
+	srv.TLS.ClientCAs.AddCert(clientCert)
+	srv.StartTLS()
+	defer srv.Close()
+	caFile := writePEM(t, "CERTIFICATE", srv.Certificate().Raw)
+
+	require.NoError(t, ConfigureTLS(TLSOptions{CAFile: caFile}))
+	c := New()
+	c.Retry.MaxRetries = 0
+	_, err := c.GetJSON(t.Context(), srv.URL, nil)
+	require.Error(t, err, "the gateway requires a client certificate")
+
+	require.NoError(t, ConfigureTLS(TLSOptions{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}))
+	_, err = New().GetJSON(t.Context(), srv.URL, nil)
+	assert.NoError(t, err)
+
+	assert.Error(t, ConfigureTLS(TLSOptions{KeyFile: keyFile}), "key without certificate")
+	assert.ErrorContains(t, ConfigureTLS(TLSOptions{CertFile: certFile}), "failed to load client certificate", "key not in the certificate file")
+}
+
+func TestTLS_ProxyFromEnvironment(t *testing.T) {
+	// http.ProxyFromEnvironment reads the environment once per process, so
+	// only the wiring is checked here.
+	transport := New().standard.Transport.(*http.Transport)
+	require.NotNil(t, transport.Proxy)
+}
@@ -3,11 +3,14 @@ package output
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
 
 	"github.com/fatih/color"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 )
 
 var (
@@ -24,12 +27,21 @@ var (
 	boldColor    = color.New(color.Bold)
 )
 
+// statusWriter receives Info and Success messages.
+var statusWriter io.Writer = os.Stdout
+
+// SetStatusWriter redirects Info and Success messages, e.g. to stderr for
+// commands whose stdout is meant to be piped.
+func SetStatusWriter(w io.Writer) {
+	statusWriter = w
+}
+
 func Info(msg string) {
-	fmt.Fprintf(os.Stdout, "%s %s\n", infoPrefix, msg)
+	fmt.Fprintf(statusWriter, "%s %s\n", infoPrefix, msg)
 }
 
 func Success(msg string) {
-	fmt.Fprintf(os.Stdout, "%s %s\n", successPrefix, msg)
+	fmt.Fprintf(statusWriter, "%s %s\n", successPrefix, msg)
 }
 
 func Warn(msg string) {
@@ -57,8 +69,35 @@ func IsTTY() bool {
 	return !color.NoColor
 }
 
-// PrintJSON pretty-prints a value as colored JSON to stdout.
+// protoJSON renders messages as a Twirp server does in its JSON variant:
+// field names as in the .proto, and zero values included.
+var protoJSON = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+
+// JSONValue returns v with any proto.Message converted to its decoded JSON
+// form (maps, slices, strings, float64...), so protobuf and JSON responses
+// can be handled alike. Other values are returned as is.
+func JSONValue(v any) (any, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return v, nil
+	}
+	data, err := protoJSON.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PrintJSON pretty-prints a value as colored JSON to stdout. Protobuf
+// messages are printed in Twirp's JSON form.
 func PrintJSON(v any) {
+	if converted, err := JSONValue(v); err == nil {
+		v = converted
+	}
 	data, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
 		fmt.Fprintln(os.Stdout, v)
@@ -0,0 +1,134 @@
+package twirp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/lollipopai/cli/internal/httpclient"
+)
+
+// ErrorCode is a Twirp error code, as sent in the "code" field of an error
+// response.
+type ErrorCode string
+
+// The Twirp error codes (https://twitchtv.github.io/twirp/docs/spec_v7.html).
+const (
+	Canceled           ErrorCode = "canceled"
+	Unknown            ErrorCode = "unknown"
+	InvalidArgument    ErrorCode = "invalid_argument"
+	Malformed          ErrorCode = "malformed"
+	DeadlineExceeded   ErrorCode = "deadline_exceeded"
+	NotFound           ErrorCode = "not_found"
+	BadRoute           ErrorCode = "bad_route"
+	AlreadyExists      ErrorCode = "already_exists"
+	PermissionDenied   ErrorCode = "permission_denied"
+	Unauthenticated    ErrorCode = "unauthenticated"
+	ResourceExhausted  ErrorCode = "resource_exhausted"
+	FailedPrecondition ErrorCode = "failed_precondition"
+	Aborted            ErrorCode = "aborted"
+	OutOfRange         ErrorCode = "out_of_range"
+	Unimplemented      ErrorCode = "unimplemented"
+	Internal           ErrorCode = "internal"
+	Unavailable        ErrorCode = "unavailable"
+	DataLoss           ErrorCode = "data_loss"
+)
+
+// Codes lists every Twirp error code in the order of the spec.
+var Codes = []ErrorCode{
+	Canceled, Unknown, InvalidArgument, Malformed, DeadlineExceeded, NotFound,
+	BadRoute, AlreadyExists, PermissionDenied, Unauthenticated, ResourceExhausted,
+	FailedPrecondition, Aborted, OutOfRange, Unimplemented, Internal, Unavailable,
+	DataLoss,
+}
+
+// Error is an error response from a Twirp service. It unwraps to the
+// underlying *httpclient.APIError.
+type Error struct {
+	Code       ErrorCode
+	Msg        string
+	Meta       map[string]string
+	HTTPStatus int
+
+	apiErr *httpclient.APIError
+}
+
+func (e *Error) Error() string {
+	if e.Msg == "" {
+		return string(e.Code)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Msg)
+}
+
+func (e *Error) Unwrap() error {
+	if e.apiErr == nil {
+		return nil
+	}
+	return e.apiErr
+}
+
+// decodeError turns an HTTP error response into an *Error. Errors without a
+// response (connection failures, cancellation) are returned unchanged.
+func decodeError(body []byte, err error) error {
+	var apiErr *httpclient.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode == 0 {
+		return err
+	}
+
+	var resp struct {
+		Code string         `json:"code"`
+		Msg  string         `json:"msg"`
+		Meta map[string]any `json:"meta"`
+	}
+	if json.Unmarshal(body, &resp) == nil && resp.Code != "" {
+		e := &Error{Code: ErrorCode(resp.Code), Msg: resp.Msg, HTTPStatus: apiErr.StatusCode, apiErr: apiErr}
+		for k, v := range resp.Meta {
+			if e.Meta == nil {
+				e.Meta = map[string]string{}
+			}
+			if s, ok := v.(string); ok {
+				e.Meta[k] = s
+			} else {
+				e.Meta[k] = fmt.Sprint(v)
+			}
+		}
+		return e
+	}
+
+	// Not a Twirp response: an intermediary such as a proxy or load balancer
+	// answered. Map its status as Twirp clients do.
+	msg := strings.TrimPrefix(apiErr.Message, fmt.Sprintf("HTTP %d: ", apiErr.StatusCode))
+	if msg == "" || len(msg) > 200 || strings.HasPrefix(strings.TrimSpace(msg), "<") {
+		msg = http.StatusText(apiErr.StatusCode)
+	}
+	return &Error{
+		Code:       codeFromStatus(apiErr.StatusCode),
+		Msg:        msg,
+		Meta:       map[string]string{"http_error_from_intermediary": "true", "status_code": fmt.Sprint(apiErr.StatusCode)},
+		HTTPStatus: apiErr.StatusCode,
+		apiErr:     apiErr,
+	}
+}
+
+// codeFromStatus maps the HTTP status of a non-Twirp error response to a
+// code, following the Twirp spec for responses from intermediaries.
+func codeFromStatus(status int) ErrorCode {
+	switch status {
+	case http.StatusBadRequest:
+		return Internal
+	case http.StatusUnauthorized:
+		return Unauthenticated
+	case http.StatusForbidden:
+		return PermissionDenied
+	case http.StatusNotFound:
+		return BadRoute
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return Unavailable
+	}
+	if status >= 300 && status < 400 {
+		return Internal
+	}
+	return Unknown
+}
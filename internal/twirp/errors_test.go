@@ -0,0 +1,79 @@
+package twirp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lollipopai/cli/internal/auth"
+	"github.com/lollipopai/cli/internal/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func callWithResponse(t *testing.T, status int, body string) error {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	client := httpclient.New()
+	client.Retry.MaxRetries = 0
+	caller := NewCaller(client, &auth.Credentials{BaseURL: srv.URL, OAuthAccessToken: "tok"})
+	_, err := caller.Call(t.Context(), "svc", "Update", nil)
+	return err
+}
+
+func TestCall_TwirpError(t *testing.T) {
+	err := callWithResponse(t, 404, `{"code":"not_found","msg":"recipe 42 not found","meta":{"id":"42","retryable":false}}`)
+
+	var twErr *Error
+	require.ErrorAs(t, err, &twErr)
+	assert.Equal(t, NotFound, twErr.Code)
+	assert.Equal(t, "recipe 42 not found", twErr.Msg)
+	assert.Equal(t, map[string]string{"id": "42", "retryable": "false"}, twErr.Meta)
+	assert.Equal(t, 404, twErr.HTTPStatus)
+	assert.Equal(t, "not_found: recipe 42 not found", err.Error())
+
+	var apiErr *httpclient.APIError
+	assert.True(t, errors.As(err, &apiErr), "still unwraps to the HTTP error")
+}
+
+func TestCall_IntermediaryError(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		code   ErrorCode
+		msg    string
+	}{
+		{502, "<html><body>Bad Gateway</body></html>", Unavailable, "Bad Gateway"},
+		{503, "upstream overloaded", Unavailable, "upstream overloaded"},
+		{404, "", BadRoute, "Not Found"},
+		{403, `{"error":"forbidden"}`, PermissionDenied, "forbidden"},
+		{400, "bad", Internal, "bad"},
+		{500, "boom", Unknown, "boom"},
+	}
+	for _, tt := range tests {
+		err := callWithResponse(t, tt.status, tt.body)
+		var twErr *Error
+		require.ErrorAs(t, err, &twErr, "status %d", tt.status)
+		assert.Equal(t, tt.code, twErr.Code, "status %d", tt.status)
+		assert.Equal(t, tt.msg, twErr.Msg, "status %d", tt.status)
+		assert.Equal(t, "true", twErr.Meta["http_error_from_intermediary"])
+	}
+}
+
+func TestCall_ConnectionErrorNotDecoded(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	client := httpclient.New()
+	client.Retry.MaxRetries = 0
+	caller := NewCaller(client, &auth.Credentials{BaseURL: srv.URL, OAuthAccessToken: "tok"})
+	_, err := caller.Call(t.Context(), "svc", "Update", nil)
+	require.Error(t, err)
+	assert.False(t, errors.As(err, new(*Error)))
+}
@@ -0,0 +1,150 @@
+package twirp
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/lollipopai/cli/internal/httpclient"
+)
+
+// Request is a Twirp call on its way to the server. Interceptors may change
+// it before passing it on, e.g. to add headers.
+type Request struct {
+	Service     string // e.g. "lollipop.proto.recipe.v1.RecipeV1"
+	Method      string // e.g. "Search"
+	URL         string
+	ContentType string
+	Body        []byte
+	Header      map[string]string
+
+	// Idempotent marks the call safe to send again, so the HTTP client
+	// retries its transient failures. Set by the built-in retry interceptor.
+	Idempotent bool
+}
+
+// Response is the body of a successful call, in the request's wire format.
+type Response struct {
+	Body []byte
+}
+
+// Handler sends a request on: to the next interceptor, or the server.
+type Handler func(ctx context.Context, req *Request) (*Response, error)
+
+// Interceptor wraps calls made by a Caller. It can act on the request,
+// call next to send it, and act on the response or error: error responses
+// arrive as *Error.
+type Interceptor func(ctx context.Context, req *Request, next Handler) (*Response, error)
+
+// chain returns a Handler running interceptors, first outermost, around h.
+func chain(interceptors []Interceptor, h Handler) Handler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], h
+		h = func(ctx context.Context, req *Request) (*Response, error) {
+			return interceptor(ctx, req, next)
+		}
+	}
+	return h
+}
+
+// Headers returns an interceptor that sends fixed headers with every call,
+// such as those given with chp -H. Authorization is always set by the
+// built-in auth interceptor.
+func Headers(headers map[string]string) Interceptor {
+	return func(ctx context.Context, req *Request, next Handler) (*Response, error) {
+		for k, v := range headers {
+			req.Header[k] = v
+		}
+		return next(ctx, req)
+	}
+}
+
+// RequestIDHeader carries a call's request ID, which servers log and echo
+// so a failed call can be traced.
+const RequestIDHeader = "X-Request-Id"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context whose calls are sent with id as their
+// request ID, e.g. to propagate the ID of a request being served.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns an interceptor that sends an X-Request-Id with every
+// call: the ID from WithRequestID, else one already set (e.g. with -H),
+// else a new random one. Retries and replays of a call keep its ID.
+func RequestID() Interceptor {
+	return func(ctx context.Context, req *Request, next Handler) (*Response, error) {
+		if id, ok := ctx.Value(requestIDKey{}).(string); ok && id != "" {
+			req.Header[RequestIDHeader] = id
+		} else if req.Header[RequestIDHeader] == "" {
+			req.Header[RequestIDHeader] = randomID()
+		}
+		return next(ctx, req)
+	}
+}
+
+// Logging returns an interceptor that logs every call at debug level, with
+// its request ID if any, duration and outcome. The HTTP client logs the
+// individual requests, including retries, beneath it.
+func Logging() Interceptor {
+	return func(ctx context.Context, req *Request, next Handler) (*Response, error) {
+		start := time.Now()
+		resp, err := next(ctx, req)
+		attrs := []any{"service", req.Service, "method", req.Method}
+		if id := req.Header[RequestIDHeader]; id != "" {
+			attrs = append(attrs, "request_id", id)
+		}
+		attrs = append(attrs, "duration", time.Since(start).Round(time.Millisecond))
+		if err != nil {
+			attrs = append(attrs, "code", CodeOf(err))
+		}
+		slog.Debug("twirp call", attrs...)
+		return resp, err
+	}
+}
+
+// CallInfo describes a finished call, as reported by Metrics.
+type CallInfo struct {
+	Service  string
+	Method   string
+	Duration time.Duration
+	Code     ErrorCode // "" if the call succeeded
+	Err      error
+}
+
+// Metrics returns an interceptor that reports every finished call to
+// observe, e.g. to count errors or record latency per method.
+func Metrics(observe func(CallInfo)) Interceptor {
+	return func(ctx context.Context, req *Request, next Handler) (*Response, error) {
+		start := time.Now()
+		resp, err := next(ctx, req)
+		info := CallInfo{Service: req.Service, Method: req.Method, Duration: time.Since(start), Err: err}
+		if err != nil {
+			info.Code = CodeOf(err)
+		}
+		observe(info)
+		return resp, err
+	}
+}
+
+// CodeOf returns the Twirp code describing err: an *Error's own code,
+// canceled or deadline_exceeded for context errors, unavailable for a
+// connection failure, otherwise unknown.
+func CodeOf(err error) ErrorCode {
+	var twErr *Error
+	var apiErr *httpclient.APIError
+	switch {
+	case errors.As(err, &twErr):
+		return twErr.Code
+	case errors.Is(err, context.Canceled):
+		return Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return DeadlineExceeded
+	case errors.As(err, &apiErr) && apiErr.StatusCode == 0:
+		return Unavailable
+	}
+	return Unknown
+}
@@ -0,0 +1,117 @@
+package twirp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lollipopai/cli/internal/auth"
+	"github.com/lollipopai/cli/internal/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCall_InterceptorOrder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	var order []string
+	trace := func(name string) Interceptor {
+		return func(ctx context.Context, req *Request, next Handler) (*Response, error) {
+			order = append(order, name+" in")
+			resp, err := next(ctx, req)
+			order = append(order, name+" out")
+			return resp, err
+		}
+	}
+	caller := NewCaller(httpclient.New(), &auth.Credentials{BaseURL: srv.URL, OAuthAccessToken: "tok"})
+	caller.Interceptors = []Interceptor{trace("a"), trace("b")}
+	_, err := caller.Call(t.Context(), "svc", "Method", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a in", "b in", "b out", "a out"}, order)
+}
+
+func TestCall_InterceptorSeesReplayOnce(t *testing.T) {
+	setupTestCreds(t)
+	var requestIDs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth/token":
+			json.NewEncoder(w).Encode(map[string]any{"access_token": "fresh", "expires_in": 3600})
+		case "/api/twirp/svc/Method":
+			requestIDs = append(requestIDs, r.Header.Get(RequestIDHeader))
+			assert.Equal(t, "on", r.Header.Get("X-Feature"))
+			if r.Header.Get("Authorization") != "Bearer fresh" {
+				w.WriteHeader(401)
+				return
+			}
+			w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer srv.Close()
+
+	var calls []CallInfo
+	caller := NewCaller(httpclient.New(), &auth.Credentials{
+		BaseURL:           srv.URL,
+		OAuthAccessToken:  "revoked",
+		OAuthRefreshToken: "refresh-tok",
+		OAuthClientID:     "client-123",
+	})
+	caller.Interceptors = []Interceptor{
+		Headers(map[string]string{"X-Feature": "on"}),
+		RequestID(),
+		Metrics(func(info CallInfo) { calls = append(calls, info) }),
+	}
+	_, err := caller.Call(t.Context(), "svc", "Method", nil)
+	require.NoError(t, err)
+
+	require.Len(t, requestIDs, 2, "original request plus the replay")
+	assert.Len(t, requestIDs[0], 32)
+	assert.Equal(t, requestIDs[0], requestIDs[1], "a replay keeps the request ID")
+	require.Len(t, calls, 1)
+	assert.Equal(t, "Method", calls[0].Method)
+	assert.Empty(t, calls[0].Code)
+}
+
+func TestRequestID_FromContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "req-42", r.Header.Get(RequestIDHeader))
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	caller := NewCaller(httpclient.New(), &auth.Credentials{BaseURL: srv.URL, OAuthAccessToken: "tok"})
+	caller.Interceptors = []Interceptor{RequestID()}
+	_, err := caller.Call(WithRequestID(t.Context(), "req-42"), "svc", "Method", nil)
+	require.NoError(t, err)
+}
+
+func TestMetrics_ErrorCode(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"code":"not_found","msg":"gone"}`))
+	}))
+	defer srv.Close()
+
+	var info CallInfo
+	caller := NewCaller(httpclient.New(), &auth.Credentials{BaseURL: srv.URL, OAuthAccessToken: "tok"})
+	caller.Interceptors = []Interceptor{Metrics(func(i CallInfo) { info = i })}
+	_, err := caller.Call(t.Context(), "svc", "Get", nil)
+	require.Error(t, err)
+	assert.Equal(t, NotFound, info.Code)
+	assert.Equal(t, err, info.Err)
+}
+
+func TestCodeOf(t *testing.T) {
+	assert.Equal(t, Canceled, CodeOf(context.Canceled))
+	assert.Equal(t, DeadlineExceeded, CodeOf(context.DeadlineExceeded))
+	assert.Equal(t, Unavailable, CodeOf(&httpclient.APIError{Message: "Connection failed"}))
+	assert.Equal(t, AlreadyExists, CodeOf(&Error{Code: AlreadyExists}))
+	assert.Equal(t, Unknown, CodeOf(assert.AnError))
+}
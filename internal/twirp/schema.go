@@ -0,0 +1,169 @@
+package twirp
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// ContentTypeProtobuf is the content type of Twirp's protobuf wire format.
+const ContentTypeProtobuf = "application/protobuf"
+
+// protoJSON converts protobuf responses to JSON as a Twirp server's JSON
+// variant would send them.
+var protoJSON = protojson.MarshalOptions{UseProtoNames: true}
+
+//go:embed descriptors/cherrypick.txtpb
+var bundledDescriptors []byte
+
+// Schema describes Twirp services as protobuf descriptors. A Caller with a
+// Schema sends the methods it describes in the protobuf wire format, using
+// dynamic messages, and returns their responses as proto.Message.
+type Schema struct {
+	files   *protoregistry.Files
+	bundled bool
+}
+
+var (
+	bundledOnce   sync.Once
+	bundledSchema *Schema
+	bundledErr    error
+)
+
+// BundledSchema returns the schema built into chp, covering the methods
+// wrapped by its named commands. It is written by hand from the API's JSON:
+// its field names and types match the API, but its field numbers are
+// placeholders, so it can't be used for the protobuf wire format against the
+// real API. Use LoadSchema with the API's own descriptors for that.
+func BundledSchema() (*Schema, error) {
+	bundledOnce.Do(func() {
+		var set descriptorpb.FileDescriptorSet
+		if err := prototext.Unmarshal(bundledDescriptors, &set); err != nil {
+			bundledErr = fmt.Errorf("bundled descriptors: %w", err)
+			return
+		}
+		bundledSchema, bundledErr = newSchema(&set)
+		if bundledSchema != nil {
+			bundledSchema.bundled = true
+		}
+	})
+	return bundledSchema, bundledErr
+}
+
+// LoadSchema reads a binary FileDescriptorSet, as written by
+// `buf build -o` or `protoc --include_imports --descriptor_set_out`.
+func LoadSchema(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("%s is not a FileDescriptorSet: %w", path, err)
+	}
+	schema, err := newSchema(&set)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return schema, nil
+}
+
+func newSchema(set *descriptorpb.FileDescriptorSet) (*Schema, error) {
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{files: files}, nil
+}
+
+// Bundled reports whether s is the schema built into chp, whose field
+// numbers are placeholders.
+func (s *Schema) Bundled() bool {
+	return s != nil && s.bundled
+}
+
+// Services returns the services the schema describes, sorted by name.
+func (s *Schema) Services() []protoreflect.ServiceDescriptor {
+	if s == nil {
+		return nil
+	}
+	var services []protoreflect.ServiceDescriptor
+	s.files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		for i := range fd.Services().Len() {
+			services = append(services, fd.Services().Get(i))
+		}
+		return true
+	})
+	slices.SortFunc(services, func(a, b protoreflect.ServiceDescriptor) int {
+		return strings.Compare(string(a.FullName()), string(b.FullName()))
+	})
+	return services
+}
+
+// Service returns the descriptor of a service, e.g.
+// "lollipop.proto.recipe.v1.RecipeV1", if the schema has it.
+func (s *Schema) Service(servicePath string) (protoreflect.ServiceDescriptor, bool) {
+	if s == nil {
+		return nil, false
+	}
+	d, err := s.files.FindDescriptorByName(protoreflect.FullName(servicePath))
+	if err != nil {
+		return nil, false
+	}
+	service, ok := d.(protoreflect.ServiceDescriptor)
+	return service, ok
+}
+
+// Method returns the descriptor of a method, e.g.
+// ("lollipop.proto.recipe.v1.RecipeV1", "Search"), if the schema has it.
+func (s *Schema) Method(servicePath, method string) (protoreflect.MethodDescriptor, bool) {
+	service, ok := s.Service(servicePath)
+	if !ok {
+		return nil, false
+	}
+	md := service.Methods().ByName(protoreflect.Name(method))
+	return md, md != nil
+}
+
+// marshalProto builds the protobuf request body for md from payload: a
+// proto.Message, or any value that marshals to JSON matching the input
+// message (field names as in the .proto or in lowerCamelCase).
+func marshalProto(md protoreflect.MethodDescriptor, payload any) ([]byte, error) {
+	if msg, ok := payload.(proto.Message); ok {
+		if got := msg.ProtoReflect().Descriptor().FullName(); got != md.Input().FullName() {
+			return nil, fmt.Errorf("%s takes %s, not %s", md.FullName(), md.Input().FullName(), got)
+		}
+		return proto.Marshal(msg)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	msg := dynamicpb.NewMessage(md.Input())
+	if err := protojson.Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("payload does not match %s: %w", md.Input().FullName(), err)
+	}
+	return proto.Marshal(msg)
+}
+
+// unmarshalProto parses a protobuf response body for md.
+func unmarshalProto(md protoreflect.MethodDescriptor, body []byte) (proto.Message, error) {
+	msg := dynamicpb.NewMessage(md.Output())
+	if err := proto.Unmarshal(body, msg); err != nil {
+		return nil, fmt.Errorf("invalid protobuf response for %s: %w", md.Output().FullName(), err)
+	}
+	return msg, nil
+}
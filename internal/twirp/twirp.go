@@ -1,18 +1,60 @@
 package twirp
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
 
 	"github.com/lollipopai/cli/internal/auth"
 	"github.com/lollipopai/cli/internal/httpclient"
-	"github.com/lollipopai/cli/internal/output"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
-// Caller makes authenticated Twirp RPC calls.
+// TokenSource supplies access tokens to a Caller, e.g. from a secrets
+// manager or another service's token cache.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// Caller makes authenticated Twirp RPC calls. It is safe for concurrent use;
+// token refreshes are single-flighted so a rotated refresh token is only
+// spent once.
 type Caller struct {
 	Client *httpclient.Client
 	Creds  *auth.Credentials
+
+	// Tokens, if set, supplies bearer tokens in place of Creds. It is asked
+	// for a token on every call, and again after a 401: if it then returns a
+	// different token the call is replayed once.
+	Tokens TokenSource
+
+	// BaseURL, if set, overrides the base URL of Creds.
+	BaseURL string
+
+	// IdempotencyKeys attaches a fresh Idempotency-Key to every write RPC,
+	// making it safe for the HTTP client to retry. Only enable it against
+	// servers that deduplicate on the key. Read RPCs are always retried.
+	IdempotencyKeys bool
+
+	// Schema, if set, switches the methods it describes to the protobuf wire
+	// format; their results are proto.Message rather than decoded JSON.
+	// Other methods still use JSON.
+	Schema *Schema
+
+	// Interceptors wrap every call, first outermost. They run outside the
+	// built-in retry and auth interceptors, so they see each call once
+	// however often it is sent.
+	Interceptors []Interceptor
+
+	mu sync.Mutex // guards Creds
 }
 
 // NewCaller creates a Caller with the given HTTP client and credentials.
@@ -20,43 +62,309 @@ func NewCaller(client *httpclient.Client, creds *auth.Credentials) *Caller {
 	return &Caller{Client: client, Creds: creds}
 }
 
-// Call invokes a Twirp RPC method. Auto-refreshes OAuth tokens when expiring.
+// Call invokes a Twirp RPC method. Auto-refreshes OAuth tokens when expiring,
+// and on a 401 refreshes once and replays the request. Error responses are
+// returned as *Error. Cancelling ctx aborts the request and returns ctx's
+// error.
 // servicePath is e.g. "lollipop.proto.recipe.v1.RecipeV1", method is e.g. "Search".
-func (c *Caller) Call(servicePath, method string, payload any) (any, error) {
-	// Auto-refresh if token is expiring
-	if c.Creds.IsOAuthTokenExpiring() {
-		if err := auth.RefreshOAuthToken(c.Client, c.Creds); err != nil {
-			output.Warn("OAuth token expired and refresh failed. Try: chp login")
-		}
+func (c *Caller) Call(ctx context.Context, servicePath, method string, payload any) (any, error) {
+	req, body, err := c.call(ctx, servicePath, method, payload)
+	if err != nil {
+		return nil, err
 	}
+	return req.decode(body)
+}
 
-	token, err := c.Creds.GetToken()
+// Invoke is Call for typed messages, such as those in pkg/cherrypick: in is
+// sent as the request and the response is decoded into out. Both are
+// structs whose JSON field names match the protobuf messages. Under a
+// context from WithRawResponse the response is also stored as Call returns
+// it.
+func (c *Caller) Invoke(ctx context.Context, servicePath, method string, in, out any) error {
+	req, body, err := c.call(ctx, servicePath, method, in)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	if raw, ok := ctx.Value(rawResponseKey{}).(*any); ok {
+		if *raw, err = req.decode(body); err != nil {
+			return err
+		}
+	}
+	return req.decodeInto(body, out)
+}
 
-	baseURL := c.Creds.GetBaseURL()
-	url := fmt.Sprintf("%s/api/twirp/%s/%s", baseURL, servicePath, method)
+type rawResponseKey struct{}
 
+// WithRawResponse returns a context under which Invoke also stores the
+// response in *raw, decoded as Call returns it, keeping fields the typed
+// message doesn't have.
+func WithRawResponse(ctx context.Context, raw *any) context.Context {
+	return context.WithValue(ctx, rawResponseKey{}, raw)
+}
+
+// call sends a request through the interceptors and returns the encoded
+// request and the response body.
+func (c *Caller) call(ctx context.Context, servicePath, method string, payload any) (request, []byte, error) {
 	if payload == nil {
 		payload = map[string]any{}
 	}
+	enc, err := c.encode(servicePath, method, payload)
+	if err != nil {
+		return request{}, nil, err
+	}
 
-	headers := map[string]string{
-		"Authorization": "Bearer " + token,
+	req := &Request{
+		Service:     servicePath,
+		Method:      method,
+		URL:         fmt.Sprintf("%s/api/twirp/%s/%s", c.baseURL(), servicePath, method),
+		ContentType: enc.contentType,
+		Body:        enc.body,
+		Header:      map[string]string{},
+	}
+	interceptors := append(slices.Clip(c.Interceptors), c.retry, c.authenticate)
+	resp, err := chain(interceptors, c.send)(ctx, req)
+	if err != nil {
+		return request{}, nil, err
 	}
+	return enc, resp.Body, nil
+}
+
+// retry is the built-in retry interceptor. Read methods are marked
+// idempotent, and with IdempotencyKeys writes get a key, so the HTTP client
+// retries their transient failures. The key is set once per call, so a
+// replay after a 401 is deduplicated too.
+func (c *Caller) retry(ctx context.Context, req *Request, next Handler) (*Response, error) {
+	if IsReadMethod(req.Method) {
+		req.Idempotent = true
+	} else if c.IdempotencyKeys && req.Header[httpclient.IdempotencyKeyHeader] == "" {
+		req.Header[httpclient.IdempotencyKeyHeader] = randomID()
+	}
+	return next(ctx, req)
+}
 
-	body, err := c.Client.PostJSON(url, payload, headers)
+// authenticate is the built-in auth interceptor. It refreshes an expiring
+// OAuth token before sending, and on a 401 refreshes once and replays the
+// request.
+func (c *Caller) authenticate(ctx context.Context, req *Request, next Handler) (*Response, error) {
+	token, expiring, err := c.currentToken(ctx)
 	if err != nil {
-		if apiErr, ok := err.(*httpclient.APIError); ok && apiErr.StatusCode == 401 {
-			return nil, fmt.Errorf("%s\nTry: chp login", apiErr.Message)
+		return nil, err
+	}
+
+	// Auto-refresh if token is expiring
+	if expiring {
+		if refreshed, err := c.refresh(ctx, token); ctx.Err() != nil {
+			return nil, ctx.Err()
+		} else if err != nil {
+			slog.Warn("OAuth token expired and refresh failed. Try: chp login", "err", err)
+		} else {
+			token = refreshed
+		}
+	}
+
+	resp, err := c.authorize(ctx, req, token, next)
+	var apiErr *httpclient.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == 401 {
+		// The server rejected a token we believed valid (revoked, clock skew):
+		// refresh once and replay.
+		if refreshed, refreshErr := c.refresh(ctx, token); refreshErr == nil {
+			resp, err = c.authorize(ctx, req, refreshed, next)
 		}
+	}
+	return resp, err
+}
+
+// authorize sends req with token. With a DPoP-bound token it is sent with
+// the DPoP scheme and a proof bound to it; otherwise as a bearer token.
+func (c *Caller) authorize(ctx context.Context, req *Request, token string, next Handler) (*Response, error) {
+	dpop := c.dpopFor(token)
+	scheme := "Bearer"
+	if dpop != nil {
+		scheme = auth.DPoPTokenType
+	}
+	delete(req.Header, "DPoP") // a proof for an earlier token
+	var resp *Response
+	_, err := dpop.Do("POST", req.URL, token, func(headers map[string]string) ([]byte, error) {
+		for k, v := range headers {
+			req.Header[k] = v
+		}
+		req.Header["Authorization"] = scheme + " " + token
+		var err error
+		resp, err = next(ctx, req)
 		return nil, err
+	})
+	return resp, err
+}
+
+// send posts a request to the server, the end of the interceptor chain.
+// Error responses are returned as *Error.
+func (c *Caller) send(ctx context.Context, req *Request) (*Response, error) {
+	post := c.Client.Post
+	if req.Idempotent {
+		post = c.Client.PostIdempotent
+	}
+	body, err := post(ctx, req.URL, req.ContentType, req.Body, req.Header)
+	if err != nil {
+		return nil, decodeError(body, err)
+	}
+	return &Response{Body: body}, nil
+}
+
+// request is an encoded request body, and how to decode its response.
+type request struct {
+	body        []byte
+	contentType string
+	method      protoreflect.MethodDescriptor // nil for JSON
+}
+
+// encode encodes payload as protobuf if the schema describes the method,
+// and as JSON otherwise.
+func (c *Caller) encode(servicePath, method string, payload any) (request, error) {
+	if md, ok := c.Schema.Method(servicePath, method); ok {
+		body, err := marshalProto(md, payload)
+		return request{body: body, contentType: ContentTypeProtobuf, method: md}, err
+	}
+	if c.Schema != nil {
+		slog.Debug("no protobuf descriptor for method, using JSON", "service", servicePath, "method", method)
 	}
+	return encodeJSON(payload)
+}
+
+func encodeJSON(payload any) (request, error) {
+	body, err := json.Marshal(payload)
+	return request{body: body, contentType: "application/json"}, err
+}
 
+func (r request) decode(body []byte) (any, error) {
+	if r.method != nil {
+		return unmarshalProto(r.method, body)
+	}
 	var result any
 	if err := json.Unmarshal(body, &result); err != nil {
 		return nil, fmt.Errorf("invalid JSON response: %w", err)
 	}
 	return result, nil
 }
+
+// decodeInto decodes a response into out. A protobuf response goes through
+// its JSON form, with field names as in the .proto.
+func (r request) decodeInto(body []byte, out any) error {
+	if r.method != nil {
+		msg, err := unmarshalProto(r.method, body)
+		if err != nil {
+			return err
+		}
+		if body, err = protoJSON.Marshal(msg); err != nil {
+			return err
+		}
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("invalid JSON response: %w", err)
+	}
+	return nil
+}
+
+// dpopFor returns the DPoP key to use with token, or nil for a bearer token.
+func (c *Caller) dpopFor(token string) *auth.DPoPKey {
+	if c.Tokens != nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if token != c.Creds.OAuthAccessToken || !c.Creds.UsesDPoP() {
+		return nil
+	}
+	key, err := c.Creds.DPoP()
+	if err != nil {
+		slog.Warn(fmt.Sprintf("%v; sending token without a DPoP proof.", err))
+		return nil
+	}
+	return key
+}
+
+// CallWithToken invokes a Twirp RPC method with an explicit bearer token,
+// bypassing stored credentials, CHP_TOKEN and refresh. Used to validate a
+// token before storing it.
+func CallWithToken(ctx context.Context, client *httpclient.Client, baseURL, servicePath, method string, payload any, token string) (any, error) {
+	caller := &Caller{Client: client, Tokens: staticToken(token), BaseURL: baseURL}
+	return caller.Call(ctx, servicePath, method, payload)
+}
+
+// staticToken is a TokenSource that always returns the same token.
+type staticToken string
+
+func (t staticToken) Token(context.Context) (string, error) {
+	return string(t), nil
+}
+
+// readMethodPrefixes start the names of RPCs that only read data.
+var readMethodPrefixes = []string{"Get", "List", "Search", "Show", "Current", "Summary", "Describe", "Find", "Count"}
+
+// IsReadMethod reports whether a Twirp method only reads data, judged by its
+// name (e.g. Get, GetBySlug, SummaryList), and so is safe to retry.
+func IsReadMethod(method string) bool {
+	for _, prefix := range readMethodPrefixes {
+		rest, ok := strings.CutPrefix(method, prefix)
+		if ok && (rest == "" || unicode.IsUpper(rune(rest[0]))) {
+			return true
+		}
+	}
+	return false
+}
+
+// randomID returns a random 128-bit ID, for idempotency keys and request
+// IDs.
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (c *Caller) baseURL() string {
+	if c.BaseURL != "" {
+		return c.BaseURL
+	}
+	if c.Creds == nil {
+		return auth.DefaultBaseURL
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Creds.GetBaseURL()
+}
+
+// currentToken returns the token to send and whether it is about to expire.
+func (c *Caller) currentToken(ctx context.Context) (string, bool, error) {
+	if c.Tokens != nil {
+		token, err := c.Tokens.Token(ctx)
+		return token, false, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	token, err := c.Creds.GetToken()
+	if err != nil {
+		return "", false, err
+	}
+	return token, c.Creds.IsOAuthTokenExpiring(), nil
+}
+
+// refresh replaces staleToken with a fresh one. If another call already
+// refreshed while this one waited for the lock, the newer token is returned
+// without hitting the token endpoint again.
+func (c *Caller) refresh(ctx context.Context, staleToken string) (string, error) {
+	if c.Tokens != nil {
+		token, err := c.Tokens.Token(ctx)
+		if err == nil && token == staleToken {
+			err = errors.New("token source returned the rejected token again")
+		}
+		return token, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if current, err := c.Creds.GetToken(); err == nil && current != staleToken {
+		return current, nil
+	}
+	if err := auth.RefreshOAuthToken(ctx, c.Client, c.Creds); err != nil {
+		return "", err
+	}
+	return c.Creds.OAuthAccessToken, nil
+}
@@ -1,10 +1,18 @@
 package twirp
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/lollipopai/cli/internal/auth"
 	"github.com/lollipopai/cli/internal/httpclient"
@@ -26,7 +34,7 @@ func TestCall_URLConstruction(t *testing.T) {
 		OAuthAccessToken: "test-token",
 	}
 	caller := NewCaller(httpclient.New(), creds)
-	_, err := caller.Call("lollipop.proto.recipe.v1.RecipeV1", "Search", map[string]any{"query": "curry"})
+	_, err := caller.Call(t.Context(), "lollipop.proto.recipe.v1.RecipeV1", "Search", map[string]any{"query": "curry"})
 	require.NoError(t, err)
 }
 
@@ -39,11 +47,11 @@ func TestCall_AuthHeader(t *testing.T) {
 
 	creds := &auth.Credentials{BaseURL: srv.URL, OAuthAccessToken: "my-token"}
 	caller := NewCaller(httpclient.New(), creds)
-	_, err := caller.Call("svc", "Method", nil)
+	_, err := caller.Call(t.Context(), "svc", "Method", nil)
 	require.NoError(t, err)
 }
 
-func TestCall_401Hint(t *testing.T) {
+func TestCall_401Unauthenticated(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(401)
 		w.Write([]byte(`{"error":"unauthorized"}`))
@@ -52,9 +60,11 @@ func TestCall_401Hint(t *testing.T) {
 
 	creds := &auth.Credentials{BaseURL: srv.URL, OAuthAccessToken: "expired-token"}
 	caller := NewCaller(httpclient.New(), creds)
-	_, err := caller.Call("svc", "Method", nil)
-	require.Error(t, err)
-	assert.Contains(t, err.Error(), "Try: chp login")
+	_, err := caller.Call(t.Context(), "svc", "Method", nil)
+	var twErr *Error
+	require.ErrorAs(t, err, &twErr)
+	assert.Equal(t, Unauthenticated, twErr.Code)
+	assert.Equal(t, 401, twErr.HTTPStatus)
 }
 
 func TestCall_NilPayload(t *testing.T) {
@@ -69,7 +79,7 @@ func TestCall_NilPayload(t *testing.T) {
 
 	creds := &auth.Credentials{BaseURL: srv.URL, OAuthAccessToken: "tok"}
 	caller := NewCaller(httpclient.New(), creds)
-	result, err := caller.Call("svc", "Method", nil)
+	result, err := caller.Call(t.Context(), "svc", "Method", nil)
 	require.NoError(t, err)
 	assert.NotNil(t, result)
 }
@@ -77,7 +87,7 @@ func TestCall_NilPayload(t *testing.T) {
 func TestCall_NoToken(t *testing.T) {
 	creds := &auth.Credentials{BaseURL: "http://localhost:9999"}
 	caller := NewCaller(httpclient.New(), creds)
-	_, err := caller.Call("svc", "Method", nil)
+	_, err := caller.Call(t.Context(), "svc", "Method", nil)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "not logged in")
 }
@@ -93,10 +103,283 @@ func TestCall_ParsesResponse(t *testing.T) {
 
 	creds := &auth.Credentials{BaseURL: srv.URL, OAuthAccessToken: "tok"}
 	caller := NewCaller(httpclient.New(), creds)
-	result, err := caller.Call("svc", "Get", nil)
+	result, err := caller.Call(t.Context(), "svc", "Get", nil)
 	require.NoError(t, err)
 
 	m, ok := result.(map[string]any)
 	require.True(t, ok)
 	assert.Equal(t, "Chicken Tikka", m["name"])
 }
+
+func setupTestCreds(t *testing.T) {
+	t.Helper()
+	origDir, origFile := auth.ConfigDir, auth.CredentialsFile
+	auth.ConfigDir = t.TempDir()
+	auth.CredentialsFile = filepath.Join(auth.ConfigDir, "credentials.json")
+	t.Setenv(auth.TokenEnvVar, "")
+	t.Cleanup(func() {
+		auth.ConfigDir, auth.CredentialsFile = origDir, origFile
+	})
+}
+
+// refreshingServer rejects every token except "fresh-token" and issues
+// "fresh-token" from /oauth/token, counting refreshes.
+func refreshingServer(t *testing.T, refreshes *atomic.Int32) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/oauth-authorization-server":
+			w.WriteHeader(404)
+		case "/oauth/token":
+			refreshes.Add(1)
+			json.NewEncoder(w).Encode(map[string]any{
+				"access_token":  "fresh-token",
+				"refresh_token": "rotated-refresh",
+				"expires_in":    3600,
+			})
+		default:
+			if r.Header.Get("Authorization") != "Bearer fresh-token" {
+				w.WriteHeader(401)
+				w.Write([]byte(`{"code":"unauthenticated","msg":"token revoked"}`))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"ok": true})
+		}
+	}))
+}
+
+func TestCall_401RefreshesAndRetries(t *testing.T) {
+	setupTestCreds(t)
+	var refreshes atomic.Int32
+	srv := refreshingServer(t, &refreshes)
+	defer srv.Close()
+
+	creds := &auth.Credentials{
+		BaseURL:           srv.URL,
+		OAuthAccessToken:  "revoked-token",
+		OAuthRefreshToken: "refresh-tok",
+		OAuthClientID:     "client-123",
+	}
+	caller := NewCaller(httpclient.New(), creds)
+	result, err := caller.Call(t.Context(), "svc", "Method", nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"ok": true}, result)
+	assert.Equal(t, int32(1), refreshes.Load())
+	assert.Equal(t, "fresh-token", creds.OAuthAccessToken)
+}
+
+func TestCall_401RetriesOnlyOnce(t *testing.T) {
+	setupTestCreds(t)
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth/token":
+			json.NewEncoder(w).Encode(map[string]any{"access_token": "still-bad"})
+		case "/api/twirp/svc/Method":
+			calls++
+			w.WriteHeader(401)
+			w.Write([]byte(`{"error":"unauthorized"}`))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer srv.Close()
+
+	creds := &auth.Credentials{
+		BaseURL:           srv.URL,
+		OAuthAccessToken:  "bad",
+		OAuthRefreshToken: "refresh-tok",
+		OAuthClientID:     "client-123",
+	}
+	_, err := NewCaller(httpclient.New(), creds).Call(t.Context(), "svc", "Method", nil)
+	var twErr *Error
+	require.ErrorAs(t, err, &twErr)
+	assert.Equal(t, Unauthenticated, twErr.Code)
+	assert.Equal(t, 2, calls, "original request plus one replay")
+}
+
+func TestCall_TokenSourceNotReplayedWithSameToken(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		assert.Equal(t, "Bearer from-source", r.Header.Get("Authorization"))
+		w.WriteHeader(401)
+		w.Write([]byte(`{"code":"unauthenticated","msg":"revoked"}`))
+	}))
+	defer srv.Close()
+
+	caller := &Caller{Client: httpclient.New(), Tokens: staticToken("from-source"), BaseURL: srv.URL}
+	_, err := caller.Call(t.Context(), "svc", "Method", nil)
+	var twErr *Error
+	require.ErrorAs(t, err, &twErr)
+	assert.Equal(t, Unauthenticated, twErr.Code)
+	assert.Equal(t, 1, calls, "no replay when the source has nothing newer")
+}
+
+func TestCall_ConcurrentRefreshIsSingleFlight(t *testing.T) {
+	setupTestCreds(t)
+	var refreshes atomic.Int32
+	srv := refreshingServer(t, &refreshes)
+	defer srv.Close()
+
+	creds := &auth.Credentials{
+		BaseURL:           srv.URL,
+		OAuthAccessToken:  "revoked-token",
+		OAuthRefreshToken: "refresh-tok",
+		OAuthClientID:     "client-123",
+	}
+	caller := NewCaller(httpclient.New(), creds)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = caller.Call(t.Context(), "svc", "Method", nil)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), refreshes.Load(), "refresh token must only be spent once")
+}
+
+func TestCall_EnvTokenOverridesAndIsNotRefreshed(t *testing.T) {
+	setupTestCreds(t)
+	t.Setenv(auth.TokenEnvVar, "env-token")
+	var refreshes atomic.Int32
+	srv := refreshingServer(t, &refreshes)
+	defer srv.Close()
+
+	creds := &auth.Credentials{
+		BaseURL:           srv.URL,
+		OAuthAccessToken:  "stored-token",
+		OAuthRefreshToken: "refresh-token",
+		OAuthClientID:     "client-1",
+	}
+	caller := NewCaller(httpclient.New(), creds)
+	_, err := caller.Call(t.Context(), "svc", "Method", nil)
+	require.Error(t, err, "a rejected CHP_TOKEN must not be swapped for stored credentials")
+	assert.Equal(t, int32(0), refreshes.Load())
+}
+
+func TestCallWithToken(t *testing.T) {
+	t.Setenv(auth.TokenEnvVar, "env-token")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/twirp/lollipop.proto.user.v1.UserV1/Current", r.URL.Path)
+		assert.Equal(t, "Bearer explicit-token", r.Header.Get("Authorization"))
+		json.NewEncoder(w).Encode(map[string]string{"email": "a@example.com"})
+	}))
+	defer srv.Close()
+
+	result, err := CallWithToken(t.Context(), httpclient.New(), srv.URL, "lollipop.proto.user.v1.UserV1", "Current", nil, "explicit-token")
+	require.NoError(t, err)
+	assert.Equal(t, "a@example.com", result.(map[string]any)["email"])
+}
+
+func TestCall_DPoPBoundToken(t *testing.T) {
+	setupTestCreds(t)
+	key, err := auth.GenerateDPoPKey()
+	require.NoError(t, err)
+
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		assert.Equal(t, "DPoP bound-token", r.Header.Get("Authorization"))
+		parts := strings.Split(r.Header.Get("DPoP"), ".")
+		require.Len(t, parts, 3)
+		payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+		require.NoError(t, err)
+		var claims map[string]any
+		require.NoError(t, json.Unmarshal(payload, &claims))
+		sum := sha256.Sum256([]byte("bound-token"))
+		assert.Equal(t, base64.RawURLEncoding.EncodeToString(sum[:]), claims["ath"])
+		assert.Equal(t, "http://"+r.Host+r.URL.Path, claims["htu"])
+
+		if claims["nonce"] != "n-1" {
+			w.Header().Set("DPoP-Nonce", "n-1")
+			w.Header().Set("WWW-Authenticate", `DPoP error="use_dpop_nonce"`)
+			w.WriteHeader(401)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{})
+	}))
+	defer srv.Close()
+
+	creds := &auth.Credentials{
+		BaseURL:          srv.URL,
+		OAuthAccessToken: "bound-token",
+		OAuthTokenType:   "DPoP",
+		DPoPPrivateKey:   key,
+	}
+	caller := NewCaller(httpclient.New(), creds)
+	_, err = caller.Call(t.Context(), "svc", "Method", nil)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), requests.Load(), "nonce challenge is answered once")
+}
+
+func TestIsReadMethod(t *testing.T) {
+	for _, m := range []string{"Get", "GetBySlug", "SummaryList", "Show", "Search", "Current"} {
+		assert.True(t, IsReadMethod(m), m)
+	}
+	for _, m := range []string{"AddRecipe", "Book", "Clear", "Getaway", "Listen", "SetQuantity"} {
+		assert.False(t, IsReadMethod(m), m)
+	}
+}
+
+func TestCall_RetriesOnlyReadsAndKeyedWrites(t *testing.T) {
+	setupTestCreds(t)
+	var requests atomic.Int32
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(httpclient.IdempotencyKeyHeader))
+		if requests.Add(1)%2 == 1 {
+			w.WriteHeader(503)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	client := httpclient.New()
+	client.Retry.BaseDelay = time.Millisecond
+	creds := &auth.Credentials{BaseURL: srv.URL, JWT: "tok"}
+
+	_, err := NewCaller(client, creds).Call(t.Context(), "svc", "GetThing", nil)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), requests.Load(), "read RPCs are retried")
+
+	requests.Store(0)
+	_, err = NewCaller(client, creds).Call(t.Context(), "svc", "AddThing", nil)
+	require.Error(t, err)
+	assert.Equal(t, int32(1), requests.Load(), "writes without a key are not")
+
+	requests.Store(0)
+	keys = nil
+	caller := NewCaller(client, creds)
+	caller.IdempotencyKeys = true
+	_, err = caller.Call(t.Context(), "svc", "AddThing", nil)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.NotEmpty(t, keys[0])
+	assert.Equal(t, keys[0], keys[1], "the retry reuses the key")
+}
+
+func TestCall_Cancelled(t *testing.T) {
+	setupTestCreds(t)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	caller := NewCaller(httpclient.New(), &auth.Credentials{BaseURL: srv.URL, JWT: "tok"})
+	_, err := caller.Call(ctx, "svc", "GetThing", nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,144 @@
+// Code generated by pkg/cherrypick/gen.go from lollipop/proto/basket/v1/basket.proto. DO NOT EDIT.
+
+// Package basketv1 is a typed client for the lollipop.proto.basket.v1 Twirp services.
+package basketv1
+
+import (
+	"context"
+
+	"github.com/lollipopai/cli/pkg/cherrypick"
+)
+
+// BasketItem is the lollipop.proto.basket.v1.BasketItem message.
+type BasketItem struct {
+	ProductID  string           `json:"product_id,omitempty"`
+	Name       string           `json:"name,omitempty"`
+	Quantity   int32            `json:"quantity,omitempty"`
+	PricePence cherrypick.Int64 `json:"price_pence,omitempty"`
+	RecipeIDs  []string         `json:"recipe_ids,omitempty"`
+}
+
+// Basket is the lollipop.proto.basket.v1.Basket message.
+type Basket struct {
+	ID         string           `json:"id,omitempty"`
+	Items      []*BasketItem    `json:"items,omitempty"`
+	RecipeIDs  []string         `json:"recipe_ids,omitempty"`
+	TotalPence cherrypick.Int64 `json:"total_pence,omitempty"`
+}
+
+// ShowRequest is the lollipop.proto.basket.v1.ShowRequest message.
+type ShowRequest struct {
+}
+
+// ClearRequest is the lollipop.proto.basket.v1.ClearRequest message.
+type ClearRequest struct {
+}
+
+// RecipeRequest is the lollipop.proto.basket.v1.RecipeRequest message.
+type RecipeRequest struct {
+	RecipeID string `json:"recipe_id,omitempty"`
+}
+
+// ProductRequest is the lollipop.proto.basket.v1.ProductRequest message.
+type ProductRequest struct {
+	ProductID string `json:"product_id,omitempty"`
+	Quantity  int32  `json:"quantity,omitempty"`
+}
+
+// BasketV1Path is the Twirp service path of BasketV1.
+const BasketV1Path = "lollipop.proto.basket.v1.BasketV1"
+
+// BasketV1Client calls the lollipop.proto.basket.v1.BasketV1 service.
+type BasketV1Client struct {
+	caller cherrypick.Invoker
+}
+
+// NewBasketV1Client returns a client that makes its calls through caller, usually a
+// *cherrypick.Client.
+func NewBasketV1Client(caller cherrypick.Invoker) *BasketV1Client {
+	return &BasketV1Client{caller: caller}
+}
+
+// Show calls lollipop.proto.basket.v1.BasketV1/Show. A nil req sends an empty request.
+func (c *BasketV1Client) Show(ctx context.Context, req *ShowRequest) (*Basket, error) {
+	if req == nil {
+		req = &ShowRequest{}
+	}
+	resp := &Basket{}
+	if err := c.caller.Invoke(ctx, BasketV1Path, "Show", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// AddRecipe calls lollipop.proto.basket.v1.BasketV1/AddRecipe. A nil req sends an empty request.
+func (c *BasketV1Client) AddRecipe(ctx context.Context, req *RecipeRequest) (*Basket, error) {
+	if req == nil {
+		req = &RecipeRequest{}
+	}
+	resp := &Basket{}
+	if err := c.caller.Invoke(ctx, BasketV1Path, "AddRecipe", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// RemoveRecipe calls lollipop.proto.basket.v1.BasketV1/RemoveRecipe. A nil req sends an empty request.
+func (c *BasketV1Client) RemoveRecipe(ctx context.Context, req *RecipeRequest) (*Basket, error) {
+	if req == nil {
+		req = &RecipeRequest{}
+	}
+	resp := &Basket{}
+	if err := c.caller.Invoke(ctx, BasketV1Path, "RemoveRecipe", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// AddProduct calls lollipop.proto.basket.v1.BasketV1/AddProduct. A nil req sends an empty request.
+func (c *BasketV1Client) AddProduct(ctx context.Context, req *ProductRequest) (*Basket, error) {
+	if req == nil {
+		req = &ProductRequest{}
+	}
+	resp := &Basket{}
+	if err := c.caller.Invoke(ctx, BasketV1Path, "AddProduct", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// RemoveProduct calls lollipop.proto.basket.v1.BasketV1/RemoveProduct. A nil req sends an empty request.
+func (c *BasketV1Client) RemoveProduct(ctx context.Context, req *ProductRequest) (*Basket, error) {
+	if req == nil {
+		req = &ProductRequest{}
+	}
+	resp := &Basket{}
+	if err := c.caller.Invoke(ctx, BasketV1Path, "RemoveProduct", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// SetQuantity calls lollipop.proto.basket.v1.BasketV1/SetQuantity. A nil req sends an empty request.
+func (c *BasketV1Client) SetQuantity(ctx context.Context, req *ProductRequest) (*Basket, error) {
+	if req == nil {
+		req = &ProductRequest{}
+	}
+	resp := &Basket{}
+	if err := c.caller.Invoke(ctx, BasketV1Path, "SetQuantity", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Clear calls lollipop.proto.basket.v1.BasketV1/Clear. A nil req sends an empty request.
+func (c *BasketV1Client) Clear(ctx context.Context, req *ClearRequest) (*Basket, error) {
+	if req == nil {
+		req = &ClearRequest{}
+	}
+	resp := &Basket{}
+	if err := c.caller.Invoke(ctx, BasketV1Path, "Clear", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,71 @@
+package basketv1_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	basketv1 "github.com/lollipopai/cli/pkg/cherrypick/basket/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// invoker records the call it is asked to make and answers with resp.
+type invoker struct {
+	resp   string
+	method string
+	req    string
+}
+
+func (i *invoker) Invoke(ctx context.Context, servicePath, method string, in, out any) error {
+	i.method = servicePath + "/" + method
+	req, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	i.req = string(req)
+	return json.Unmarshal([]byte(i.resp), out)
+}
+
+func TestBasketV1Client(t *testing.T) {
+	inv := &invoker{resp: `{"id":"b1","items":[{"product_id":"7834128","quantity":2,"price_pence":350,"recipe_ids":["r1"]}],"recipe_ids":["r1"],"total_pence":"700"}`}
+	client := basketv1.NewBasketV1Client(inv)
+	want := &basketv1.Basket{
+		ID:         "b1",
+		Items:      []*basketv1.BasketItem{{ProductID: "7834128", Quantity: 2, PricePence: 350, RecipeIDs: []string{"r1"}}},
+		RecipeIDs:  []string{"r1"},
+		TotalPence: 700,
+	}
+
+	for _, tt := range []struct {
+		method string
+		call   func(context.Context) (*basketv1.Basket, error)
+		req    string
+	}{
+		{"Show", func(ctx context.Context) (*basketv1.Basket, error) { return client.Show(ctx, nil) }, `{}`},
+		{"AddRecipe", func(ctx context.Context) (*basketv1.Basket, error) {
+			return client.AddRecipe(ctx, &basketv1.RecipeRequest{RecipeID: "r1"})
+		}, `{"recipe_id":"r1"}`},
+		{"RemoveRecipe", func(ctx context.Context) (*basketv1.Basket, error) {
+			return client.RemoveRecipe(ctx, &basketv1.RecipeRequest{RecipeID: "r1"})
+		}, `{"recipe_id":"r1"}`},
+		{"AddProduct", func(ctx context.Context) (*basketv1.Basket, error) {
+			return client.AddProduct(ctx, &basketv1.ProductRequest{ProductID: "7834128", Quantity: 2})
+		}, `{"product_id":"7834128","quantity":2}`},
+		{"RemoveProduct", func(ctx context.Context) (*basketv1.Basket, error) {
+			return client.RemoveProduct(ctx, &basketv1.ProductRequest{ProductID: "7834128"})
+		}, `{"product_id":"7834128"}`},
+		{"SetQuantity", func(ctx context.Context) (*basketv1.Basket, error) {
+			return client.SetQuantity(ctx, &basketv1.ProductRequest{ProductID: "7834128", Quantity: 3})
+		}, `{"product_id":"7834128","quantity":3}`},
+		{"Clear", func(ctx context.Context) (*basketv1.Basket, error) { return client.Clear(ctx, nil) }, `{}`},
+	} {
+		t.Run(tt.method, func(t *testing.T) {
+			got, err := tt.call(t.Context())
+			require.NoError(t, err)
+			assert.Equal(t, basketv1.BasketV1Path+"/"+tt.method, inv.method)
+			assert.JSONEq(t, tt.req, inv.req)
+			assert.Equal(t, want, got)
+		})
+	}
+}
@@ -0,0 +1,20 @@
+// Package cherrypick is a Go client for the Cherrypick API, the one chp is
+// built on. New returns a Client; the typed clients for each service are in
+// one package per proto package, recipe/v1, basket/v1 and so on:
+//
+//	client, err := cherrypick.New(cherrypick.WithTokenSource(cherrypick.StaticToken(token)))
+//	if err != nil { ... }
+//	basket, err := basketv1.NewBasketV1Client(client).Show(ctx, nil)
+//
+// The typed clients are generated from the descriptors in
+// internal/twirp/descriptors, so request fields are checked at compile time
+// rather than by the server. Those descriptors are written by hand from the
+// API's JSON, not generated from its .proto files: the typed messages are
+// sent as JSON, and WithProtobuf needs the API's own descriptor set.
+//
+// Every call takes a context and returns errors rather than exiting; error
+// responses are returned as *Error. Notices, such as a token refresh, are
+// logged with log/slog rather than printed.
+package cherrypick
+
+//go:generate go run gen.go
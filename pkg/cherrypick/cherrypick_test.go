@@ -0,0 +1,104 @@
+package cherrypick_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lollipopai/cli/internal/auth"
+	"github.com/lollipopai/cli/internal/httpclient"
+	"github.com/lollipopai/cli/internal/twirp"
+	"github.com/lollipopai/cli/pkg/cherrypick"
+	basketv1 "github.com/lollipopai/cli/pkg/cherrypick/basket/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const basketJSON = `{"id":"b1","items":[{"product_id":"7834128","name":"Eggs","quantity":2,"price_pence":"350"}],"total_pence":"350"}`
+
+// basketServer is a stand-in BasketV1 server answering in the request's
+// wire format. It records the request as JSON.
+func basketServer(t *testing.T, schema *twirp.Schema, got *map[string]any) *httptest.Server {
+	t.Helper()
+	md, ok := schema.Method(basketv1.BasketV1Path, "AddProduct")
+	require.True(t, ok)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/twirp/"+basketv1.BasketV1Path+"/AddProduct", r.URL.Path)
+		body, _ := io.ReadAll(r.Body)
+		resp := dynamicpb.NewMessage(md.Output())
+		require.NoError(t, protojson.Unmarshal([]byte(basketJSON), resp))
+
+		if r.Header.Get("Content-Type") == twirp.ContentTypeProtobuf {
+			req := dynamicpb.NewMessage(md.Input())
+			require.NoError(t, proto.Unmarshal(body, req))
+			body, _ = protojson.MarshalOptions{UseProtoNames: true}.Marshal(req)
+			out, _ := proto.Marshal(resp)
+			w.Write(out)
+		} else {
+			w.Write([]byte(basketJSON))
+		}
+		require.NoError(t, json.Unmarshal(body, got))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestTypedClient(t *testing.T) {
+	schema, err := twirp.BundledSchema()
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name   string
+		schema *twirp.Schema
+	}{{"json", nil}, {"protobuf", schema}} {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]any
+			srv := basketServer(t, schema, &got)
+			caller := twirp.NewCaller(httpclient.New(), &auth.Credentials{BaseURL: srv.URL, OAuthAccessToken: "tok"})
+			caller.Schema = tt.schema
+
+			basket, err := basketv1.NewBasketV1Client(caller).AddProduct(t.Context(), &basketv1.ProductRequest{ProductID: "7834128", Quantity: 2})
+			require.NoError(t, err)
+			assert.Equal(t, map[string]any{"product_id": "7834128", "quantity": float64(2)}, got)
+			assert.Equal(t, &basketv1.Basket{
+				ID:         "b1",
+				Items:      []*basketv1.BasketItem{{ProductID: "7834128", Name: "Eggs", Quantity: 2, PricePence: 350}},
+				TotalPence: 350,
+			}, basket)
+		})
+	}
+}
+
+func TestTypedClient_NilRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.JSONEq(t, `{}`, string(body))
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	caller := twirp.NewCaller(httpclient.New(), &auth.Credentials{BaseURL: srv.URL, OAuthAccessToken: "tok"})
+	basket, err := basketv1.NewBasketV1Client(caller).Show(t.Context(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, &basketv1.Basket{}, basket)
+}
+
+func TestInt64_AcceptsNumbersAndStrings(t *testing.T) {
+	for _, body := range []string{`{"total_pence":"1234"}`, `{"total_pence":1234}`} {
+		var basket basketv1.Basket
+		require.NoError(t, json.Unmarshal([]byte(body), &basket), body)
+		assert.Equal(t, cherrypick.Int64(1234), basket.TotalPence, body)
+	}
+
+	out, err := json.Marshal(&basketv1.Basket{TotalPence: 1234})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"total_pence":"1234"}`, string(out))
+
+	var basket basketv1.Basket
+	assert.Error(t, json.Unmarshal([]byte(`{"total_pence":"12.5"}`), &basket))
+}
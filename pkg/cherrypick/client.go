@@ -0,0 +1,181 @@
+package cherrypick
+
+import (
+	"cmp"
+	"context"
+	"errors"
+
+	"github.com/lollipopai/cli/internal/auth"
+	"github.com/lollipopai/cli/internal/config"
+	"github.com/lollipopai/cli/internal/httpclient"
+	"github.com/lollipopai/cli/internal/twirp"
+)
+
+// Invoker makes the Twirp calls of the typed clients. *Client implements
+// it; tests can substitute their own.
+type Invoker interface {
+	Invoke(ctx context.Context, servicePath, method string, in, out any) error
+}
+
+// TokenSource supplies access tokens, e.g. from a secrets manager. It is
+// asked for a token on every call, and again after a 401: if it then
+// returns a different token the call is replayed once.
+type TokenSource = twirp.TokenSource
+
+// StaticToken is a TokenSource that always returns the same token.
+type StaticToken string
+
+// Token returns t.
+func (t StaticToken) Token(context.Context) (string, error) {
+	return string(t), nil
+}
+
+// TokenFunc adapts a function to a TokenSource.
+type TokenFunc func(ctx context.Context) (string, error)
+
+// Token calls f.
+func (f TokenFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// Client calls the Cherrypick API. It is safe for concurrent use. Pass it
+// to the typed clients, e.g. basketv1.NewBasketV1Client(client).
+type Client struct {
+	caller *twirp.Caller
+}
+
+// Option configures a Client.
+type Option func(*options)
+
+type options struct {
+	baseURL          string
+	tokens           TokenSource
+	credentialStore  string
+	credentialHelper *string
+	retries          *int
+	protobuf         bool
+	descriptorSet    string
+	interceptors     []Interceptor
+}
+
+// WithBaseURL sets the API base URL. The default is the one stored with
+// the credentials, else that of the Cherrypick API.
+func WithBaseURL(url string) Option {
+	return func(o *options) { o.baseURL = url }
+}
+
+// WithTokenSource authenticates calls with tokens from ts instead of the
+// credentials stored by chp.
+func WithTokenSource(ts TokenSource) Option {
+	return func(o *options) { o.tokens = ts }
+}
+
+// WithCredentialStore reads the credentials chp stored from the named store,
+// "file", "encrypted-file" or "keyring", instead of the one set with
+// "chp config set credential-store".
+func WithCredentialStore(name string) Option {
+	return func(o *options) { o.credentialStore = name }
+}
+
+// WithCredentialHelper gets tokens from a git-style credential helper
+// command instead of the one set with "chp config set credential-helper".
+// "" uses none.
+func WithCredentialHelper(command string) Option {
+	return func(o *options) { o.credentialHelper = &command }
+}
+
+// WithRetries sets how many times transient failures (connection errors,
+// 429, 5xx) are retried on reads and idempotent writes.
+func WithRetries(n int) Option {
+	return func(o *options) { o.retries = &n }
+}
+
+// WithProtobuf sends calls in Twirp's protobuf wire format, using the
+// API's descriptors in the binary FileDescriptorSet at descriptorSet.
+// Methods the descriptors don't cover use JSON. The descriptors bundled with
+// chp can't be used for this, as their field numbers are not the API's, so
+// New fails if descriptorSet is "".
+func WithProtobuf(descriptorSet string) Option {
+	return func(o *options) {
+		o.protobuf = true
+		o.descriptorSet = descriptorSet
+	}
+}
+
+// New returns a Client. Without WithTokenSource it uses the credentials
+// stored by "chp login" for the active profile (see CHP_PROFILE), or
+// CHP_TOKEN if set, read through the credential store and helper chp is
+// configured with. OAuth tokens are refreshed as chp refreshes them. The
+// store and helper are process-wide, as they are for chp.
+func New(opts ...Option) (*Client, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	client := httpclient.New()
+	if o.retries != nil {
+		client.Retry.MaxRetries = max(*o.retries, 0)
+	}
+	caller := twirp.NewCaller(client, nil)
+	if o.tokens != nil {
+		caller.Tokens = o.tokens
+	} else {
+		if err := o.useCredentialConfig(); err != nil {
+			return nil, err
+		}
+		caller.Creds = auth.LoadCredentials()
+	}
+	caller.BaseURL = o.baseURL
+	caller.Interceptors = o.interceptors
+
+	if o.protobuf {
+		if o.descriptorSet == "" {
+			return nil, errors.New("the protobuf wire format needs the API's descriptors: pass a FileDescriptorSet to WithProtobuf")
+		}
+		var err error
+		if caller.Schema, err = twirp.LoadSchema(o.descriptorSet); err != nil {
+			return nil, err
+		}
+	}
+	return &Client{caller: caller}, nil
+}
+
+// useCredentialConfig selects the credential store and helper given as
+// options, else those in chp's config file.
+func (o *options) useCredentialConfig() error {
+	cfg := config.Load()
+	// Switching to the store already in use would forget its passphrase.
+	if store := cmp.Or(o.credentialStore, cfg.CredentialStore, auth.StoreFile); store != auth.CredentialStore() {
+		if err := auth.SetCredentialStore(store); err != nil {
+			return err
+		}
+	}
+	auth.CredentialHelper = cfg.CredentialHelper
+	if o.credentialHelper != nil {
+		auth.CredentialHelper = *o.credentialHelper
+	}
+	return nil
+}
+
+// Invoke calls a method with a typed request and decodes the response into
+// out. servicePath is e.g. "lollipop.proto.recipe.v1.RecipeV1". Error
+// responses are returned as *Error.
+func (c *Client) Invoke(ctx context.Context, servicePath, method string, in, out any) error {
+	return c.caller.Invoke(ctx, servicePath, method, in, out)
+}
+
+// WithRawResponse returns a context under which a typed call also stores
+// the response in *raw as Call would return it: the decoded JSON, or a
+// proto.Message with WithProtobuf. Fields the typed messages don't have are
+// kept, e.g. for printing the response as the API sent it.
+func WithRawResponse(ctx context.Context, raw *any) context.Context {
+	return twirp.WithRawResponse(ctx, raw)
+}
+
+// Call calls a method with any payload that marshals to JSON, for methods
+// without a typed client. The result is the decoded JSON, or a
+// proto.Message with WithProtobuf.
+func (c *Client) Call(ctx context.Context, servicePath, method string, payload any) (any, error) {
+	return c.caller.Call(ctx, servicePath, method, payload)
+}
@@ -0,0 +1,149 @@
+package cherrypick_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/lollipopai/cli/internal/auth"
+	"github.com/lollipopai/cli/internal/config"
+	"github.com/lollipopai/cli/pkg/cherrypick"
+	userv1 "github.com/lollipopai/cli/pkg/cherrypick/user/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// userServer answers UserV1.Current for the bearer token "good" and rejects
+// any other token.
+func userServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/twirp/"+userv1.UserV1Path+"/Current", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer good" {
+			w.WriteHeader(401)
+			w.Write([]byte(`{"code":"unauthenticated","msg":"bad token"}`))
+			return
+		}
+		w.Write([]byte(`{"id":"u1","email":"cook@example.com"}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClient_StaticToken(t *testing.T) {
+	srv := userServer(t)
+	client, err := cherrypick.New(cherrypick.WithBaseURL(srv.URL), cherrypick.WithTokenSource(cherrypick.StaticToken("good")))
+	require.NoError(t, err)
+
+	user, err := userv1.NewUserV1Client(client).Current(t.Context(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, &userv1.User{ID: "u1", Email: "cook@example.com"}, user)
+}
+
+func TestClient_UsesConfiguredCredentialHelper(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("credential helper test uses sh")
+	}
+	tmp := t.TempDir()
+	origDir, origFile, origHelper := auth.ConfigDir, auth.CredentialsFile, auth.CredentialHelper
+	t.Cleanup(func() { auth.ConfigDir, auth.CredentialsFile, auth.CredentialHelper = origDir, origFile, origHelper })
+	auth.ConfigDir = tmp
+	auth.CredentialsFile = filepath.Join(tmp, "credentials.json")
+	t.Setenv(auth.ProfileEnvVar, "")
+	t.Setenv(auth.TokenEnvVar, "")
+
+	helper := filepath.Join(tmp, "helper.sh")
+	require.NoError(t, os.WriteFile(helper, []byte("#!/bin/sh\ncat >/dev/null\n[ \"$1\" = get ] && echo access_token=good\n"), 0700))
+	require.NoError(t, config.Save(&config.Config{CredentialHelper: helper}))
+
+	srv := userServer(t)
+	client, err := cherrypick.New(cherrypick.WithBaseURL(srv.URL))
+	require.NoError(t, err)
+	_, err = userv1.NewUserV1Client(client).Current(t.Context(), nil)
+	require.NoError(t, err, "token comes from the helper set with chp config set")
+}
+
+func TestClient_TokenSourceAskedAgainAfter401(t *testing.T) {
+	srv := userServer(t)
+	tokens := []string{"stale", "good"}
+	source := cherrypick.TokenFunc(func(ctx context.Context) (string, error) {
+		token := tokens[0]
+		tokens = tokens[1:]
+		return token, nil
+	})
+	client, err := cherrypick.New(cherrypick.WithBaseURL(srv.URL), cherrypick.WithTokenSource(source))
+	require.NoError(t, err)
+
+	_, err = userv1.NewUserV1Client(client).Current(t.Context(), nil)
+	require.NoError(t, err)
+	assert.Empty(t, tokens)
+}
+
+func TestClient_ReturnsError(t *testing.T) {
+	srv := userServer(t)
+	client, err := cherrypick.New(cherrypick.WithBaseURL(srv.URL), cherrypick.WithTokenSource(cherrypick.StaticToken("bad")), cherrypick.WithRetries(0))
+	require.NoError(t, err)
+
+	_, err = userv1.NewUserV1Client(client).Current(t.Context(), nil)
+	var twErr *cherrypick.Error
+	require.ErrorAs(t, err, &twErr)
+	assert.Equal(t, cherrypick.Unauthenticated, twErr.Code)
+	assert.Equal(t, "bad token", twErr.Msg)
+
+	var httpErr *cherrypick.HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, 401, httpErr.StatusCode)
+}
+
+func TestClient_Protobuf(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/protobuf", r.Header.Get("Content-Type"))
+		w.Header().Set("Content-Type", "application/protobuf")
+	}))
+	defer srv.Close()
+
+	// The bundled descriptors stand in for the API's own here.
+	text, err := os.ReadFile("../../internal/twirp/descriptors/cherrypick.txtpb")
+	require.NoError(t, err)
+	var set descriptorpb.FileDescriptorSet
+	require.NoError(t, prototext.Unmarshal(text, &set))
+	data, err := proto.Marshal(&set)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "cherrypick.binpb")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	client, err := cherrypick.New(cherrypick.WithBaseURL(srv.URL), cherrypick.WithTokenSource(cherrypick.StaticToken("good")), cherrypick.WithProtobuf(path))
+	require.NoError(t, err)
+	user, err := userv1.NewUserV1Client(client).Current(t.Context(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, &userv1.User{}, user)
+
+	_, err = cherrypick.New(cherrypick.WithProtobuf("/nonexistent/set.binpb"))
+	assert.Error(t, err)
+	_, err = cherrypick.New(cherrypick.WithTokenSource(cherrypick.StaticToken("good")), cherrypick.WithProtobuf(""))
+	assert.ErrorContains(t, err, "needs the API's descriptors", "the bundled descriptors' field numbers are placeholders")
+}
+
+func TestClient_WithRawResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"u1","email":"cook@example.com","household_id":"h1"}`))
+	}))
+	defer srv.Close()
+	client, err := cherrypick.New(cherrypick.WithBaseURL(srv.URL), cherrypick.WithTokenSource(cherrypick.StaticToken("good")))
+	require.NoError(t, err)
+
+	var raw any
+	user, err := userv1.NewUserV1Client(client).Current(cherrypick.WithRawResponse(t.Context(), &raw), nil)
+	require.NoError(t, err)
+	assert.Equal(t, &userv1.User{ID: "u1", Email: "cook@example.com"}, user)
+	assert.Equal(t, map[string]any{"id": "u1", "email": "cook@example.com", "household_id": "h1"}, raw, "fields the typed message lacks are kept")
+}
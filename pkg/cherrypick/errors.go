@@ -0,0 +1,44 @@
+package cherrypick
+
+import (
+	"github.com/lollipopai/cli/internal/httpclient"
+	"github.com/lollipopai/cli/internal/twirp"
+)
+
+// Error is an error response from a Cherrypick service. Calls return it for
+// every response the server or an intermediary rejected; check its Code
+// with errors.As:
+//
+//	var twErr *cherrypick.Error
+//	if errors.As(err, &twErr) && twErr.Code == cherrypick.NotFound { ... }
+type Error = twirp.Error
+
+// ErrorCode is a Twirp error code.
+type ErrorCode = twirp.ErrorCode
+
+// HTTPError is the HTTP failure an Error unwraps to. Connection failures
+// are returned as an *HTTPError with StatusCode 0; cancelling the context
+// returns the context's error.
+type HTTPError = httpclient.APIError
+
+// The Twirp error codes (https://twitchtv.github.io/twirp/docs/spec_v7.html).
+const (
+	Canceled           = twirp.Canceled
+	Unknown            = twirp.Unknown
+	InvalidArgument    = twirp.InvalidArgument
+	Malformed          = twirp.Malformed
+	DeadlineExceeded   = twirp.DeadlineExceeded
+	NotFound           = twirp.NotFound
+	BadRoute           = twirp.BadRoute
+	AlreadyExists      = twirp.AlreadyExists
+	PermissionDenied   = twirp.PermissionDenied
+	Unauthenticated    = twirp.Unauthenticated
+	ResourceExhausted  = twirp.ResourceExhausted
+	FailedPrecondition = twirp.FailedPrecondition
+	Aborted            = twirp.Aborted
+	OutOfRange         = twirp.OutOfRange
+	Unimplemented      = twirp.Unimplemented
+	Internal           = twirp.Internal
+	Unavailable        = twirp.Unavailable
+	DataLoss           = twirp.DataLoss
+)
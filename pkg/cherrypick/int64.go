@@ -0,0 +1,34 @@
+package cherrypick
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// Int64 is a 64-bit integer field of a typed message. Protobuf JSON writes
+// these as strings but accepts numbers too, and servers send either, so
+// Int64 decodes both. It is sent as a string.
+type Int64 int64
+
+// MarshalJSON writes n as a JSON string.
+func (n Int64) MarshalJSON() ([]byte, error) {
+	return strconv.AppendQuote(nil, strconv.FormatInt(int64(n), 10)), nil
+}
+
+// UnmarshalJSON reads a JSON string or number holding an integer.
+func (n *Int64) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	s := string(data)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid 64-bit integer %s", data)
+	}
+	*n = Int64(v)
+	return nil
+}
@@ -0,0 +1,63 @@
+package cherrypick
+
+import (
+	"context"
+
+	"github.com/lollipopai/cli/internal/twirp"
+)
+
+// Interceptor wraps every call made by a Client: it can act on the request,
+// call next to send it, and act on the response or error. Interceptors run
+// outside the built-in retry and auth steps, so they see each call once
+// however often it is sent.
+type Interceptor = twirp.Interceptor
+
+// Request is a call on its way to the server, as seen by interceptors.
+type Request = twirp.Request
+
+// Response is the body of a successful call.
+type Response = twirp.Response
+
+// Handler sends a request on, to the next interceptor or the server.
+type Handler = twirp.Handler
+
+// CallInfo describes a finished call, as reported by Metrics.
+type CallInfo = twirp.CallInfo
+
+// RequestIDHeader is the header RequestID sets.
+const RequestIDHeader = twirp.RequestIDHeader
+
+// WithInterceptors adds interceptors to the Client, first outermost.
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(o *options) { o.interceptors = append(o.interceptors, interceptors...) }
+}
+
+// Headers returns an interceptor that sends fixed headers with every call.
+func Headers(headers map[string]string) Interceptor {
+	return twirp.Headers(headers)
+}
+
+// RequestID returns an interceptor that sends an X-Request-Id with every
+// call: the ID from WithRequestID, else one already set, else a new random
+// one.
+func RequestID() Interceptor {
+	return twirp.RequestID()
+}
+
+// WithRequestID returns a context whose calls carry id as their request
+// ID, e.g. to propagate the ID of a request being served.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return twirp.WithRequestID(ctx, id)
+}
+
+// Logging returns an interceptor that logs every call to log/slog at debug
+// level.
+func Logging() Interceptor {
+	return twirp.Logging()
+}
+
+// Metrics returns an interceptor that reports every finished call to
+// observe.
+func Metrics(observe func(CallInfo)) Interceptor {
+	return twirp.Metrics(observe)
+}
@@ -0,0 +1,89 @@
+// Code generated by pkg/cherrypick/gen.go from lollipop/proto/order/v1/order.proto. DO NOT EDIT.
+
+// Package orderv1 is a typed client for the lollipop.proto.order.v1 Twirp services.
+package orderv1
+
+import (
+	"context"
+
+	"github.com/lollipopai/cli/pkg/cherrypick"
+)
+
+// OrderItem is the lollipop.proto.order.v1.OrderItem message.
+type OrderItem struct {
+	ProductUID string           `json:"product_uid,omitempty"`
+	Name       string           `json:"name,omitempty"`
+	Quantity   int32            `json:"quantity,omitempty"`
+	PricePence cherrypick.Int64 `json:"price_pence,omitempty"`
+}
+
+// Order is the lollipop.proto.order.v1.Order message.
+type Order struct {
+	ID           string           `json:"id,omitempty"`
+	Status       string           `json:"status,omitempty"`
+	PlacedAt     string           `json:"placed_at,omitempty"`
+	DeliverySlot string           `json:"delivery_slot,omitempty"`
+	TotalPence   cherrypick.Int64 `json:"total_pence,omitempty"`
+	Items        []*OrderItem     `json:"items,omitempty"`
+}
+
+// OrderSummary is the lollipop.proto.order.v1.OrderSummary message.
+type OrderSummary struct {
+	ID         string           `json:"id,omitempty"`
+	Status     string           `json:"status,omitempty"`
+	PlacedAt   string           `json:"placed_at,omitempty"`
+	TotalPence cherrypick.Int64 `json:"total_pence,omitempty"`
+	ItemCount  int32            `json:"item_count,omitempty"`
+}
+
+// GetRequest is the lollipop.proto.order.v1.GetRequest message.
+type GetRequest struct {
+	ID string `json:"id,omitempty"`
+}
+
+// SummaryListRequest is the lollipop.proto.order.v1.SummaryListRequest message.
+type SummaryListRequest struct {
+}
+
+// SummaryListResponse is the lollipop.proto.order.v1.SummaryListResponse message.
+type SummaryListResponse struct {
+	Orders []*OrderSummary `json:"orders,omitempty"`
+}
+
+// OrderV1Path is the Twirp service path of OrderV1.
+const OrderV1Path = "lollipop.proto.order.v1.OrderV1"
+
+// OrderV1Client calls the lollipop.proto.order.v1.OrderV1 service.
+type OrderV1Client struct {
+	caller cherrypick.Invoker
+}
+
+// NewOrderV1Client returns a client that makes its calls through caller, usually a
+// *cherrypick.Client.
+func NewOrderV1Client(caller cherrypick.Invoker) *OrderV1Client {
+	return &OrderV1Client{caller: caller}
+}
+
+// Get calls lollipop.proto.order.v1.OrderV1/Get. A nil req sends an empty request.
+func (c *OrderV1Client) Get(ctx context.Context, req *GetRequest) (*Order, error) {
+	if req == nil {
+		req = &GetRequest{}
+	}
+	resp := &Order{}
+	if err := c.caller.Invoke(ctx, OrderV1Path, "Get", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// SummaryList calls lollipop.proto.order.v1.OrderV1/SummaryList. A nil req sends an empty request.
+func (c *OrderV1Client) SummaryList(ctx context.Context, req *SummaryListRequest) (*SummaryListResponse, error) {
+	if req == nil {
+		req = &SummaryListRequest{}
+	}
+	resp := &SummaryListResponse{}
+	if err := c.caller.Invoke(ctx, OrderV1Path, "SummaryList", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
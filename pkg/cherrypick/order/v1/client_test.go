@@ -0,0 +1,66 @@
+package orderv1_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	orderv1 "github.com/lollipopai/cli/pkg/cherrypick/order/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// invoker records the call it is asked to make and answers with resp.
+type invoker struct {
+	resp   string
+	method string
+	req    string
+}
+
+func (i *invoker) Invoke(ctx context.Context, servicePath, method string, in, out any) error {
+	i.method = servicePath + "/" + method
+	req, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	i.req = string(req)
+	return json.Unmarshal([]byte(i.resp), out)
+}
+
+func TestOrderV1Client(t *testing.T) {
+	for _, tt := range []struct {
+		method string
+		call   func(context.Context, *orderv1.OrderV1Client) (any, error)
+		req    string
+		resp   string
+		want   any
+	}{
+		{
+			method: "Get",
+			call: func(ctx context.Context, c *orderv1.OrderV1Client) (any, error) {
+				return c.Get(ctx, &orderv1.GetRequest{ID: "42"})
+			},
+			req:  `{"id":"42"}`,
+			resp: `{"id":"42","status":"delivered","total_pence":1234,"items":[{"product_uid":"7834128","quantity":2,"price_pence":"350"}]}`,
+			want: &orderv1.Order{ID: "42", Status: "delivered", TotalPence: 1234, Items: []*orderv1.OrderItem{{ProductUID: "7834128", Quantity: 2, PricePence: 350}}},
+		},
+		{
+			method: "SummaryList",
+			call: func(ctx context.Context, c *orderv1.OrderV1Client) (any, error) {
+				return c.SummaryList(ctx, nil)
+			},
+			req:  `{}`,
+			resp: `{"orders":[{"id":"42","total_pence":"1234","item_count":3}]}`,
+			want: &orderv1.SummaryListResponse{Orders: []*orderv1.OrderSummary{{ID: "42", TotalPence: 1234, ItemCount: 3}}},
+		},
+	} {
+		t.Run(tt.method, func(t *testing.T) {
+			inv := &invoker{resp: tt.resp}
+			got, err := tt.call(t.Context(), orderv1.NewOrderV1Client(inv))
+			require.NoError(t, err)
+			assert.Equal(t, orderv1.OrderV1Path+"/"+tt.method, inv.method)
+			assert.JSONEq(t, tt.req, inv.req)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
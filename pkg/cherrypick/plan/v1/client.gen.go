@@ -0,0 +1,123 @@
+// Code generated by pkg/cherrypick/gen.go from lollipop/proto/plan/v1/plan.proto. DO NOT EDIT.
+
+// Package planv1 is a typed client for the lollipop.proto.plan.v1 Twirp services.
+package planv1
+
+import (
+	"context"
+
+	"github.com/lollipopai/cli/pkg/cherrypick"
+)
+
+// PlanRecipe is the lollipop.proto.plan.v1.PlanRecipe message.
+type PlanRecipe struct {
+	RecipeID string `json:"recipe_id,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Servings int32  `json:"servings,omitempty"`
+}
+
+// Plan is the lollipop.proto.plan.v1.Plan message.
+type Plan struct {
+	ID       string        `json:"id,omitempty"`
+	Name     string        `json:"name,omitempty"`
+	StartsOn string        `json:"starts_on,omitempty"`
+	Recipes  []*PlanRecipe `json:"recipes,omitempty"`
+}
+
+// ListRequest is the lollipop.proto.plan.v1.ListRequest message.
+type ListRequest struct {
+}
+
+// ListResponse is the lollipop.proto.plan.v1.ListResponse message.
+type ListResponse struct {
+	Plans []*Plan `json:"plans,omitempty"`
+}
+
+// GetRequest is the lollipop.proto.plan.v1.GetRequest message.
+type GetRequest struct {
+	ID string `json:"id,omitempty"`
+}
+
+// ShowRequest is the lollipop.proto.plan.v1.ShowRequest message.
+type ShowRequest struct {
+}
+
+// RecipeRequest is the lollipop.proto.plan.v1.RecipeRequest message.
+type RecipeRequest struct {
+	PlanID   string `json:"plan_id,omitempty"`
+	RecipeID string `json:"recipe_id,omitempty"`
+}
+
+// PlanV1Path is the Twirp service path of PlanV1.
+const PlanV1Path = "lollipop.proto.plan.v1.PlanV1"
+
+// PlanV1Client calls the lollipop.proto.plan.v1.PlanV1 service.
+type PlanV1Client struct {
+	caller cherrypick.Invoker
+}
+
+// NewPlanV1Client returns a client that makes its calls through caller, usually a
+// *cherrypick.Client.
+func NewPlanV1Client(caller cherrypick.Invoker) *PlanV1Client {
+	return &PlanV1Client{caller: caller}
+}
+
+// List calls lollipop.proto.plan.v1.PlanV1/List. A nil req sends an empty request.
+func (c *PlanV1Client) List(ctx context.Context, req *ListRequest) (*ListResponse, error) {
+	if req == nil {
+		req = &ListRequest{}
+	}
+	resp := &ListResponse{}
+	if err := c.caller.Invoke(ctx, PlanV1Path, "List", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Get calls lollipop.proto.plan.v1.PlanV1/Get. A nil req sends an empty request.
+func (c *PlanV1Client) Get(ctx context.Context, req *GetRequest) (*Plan, error) {
+	if req == nil {
+		req = &GetRequest{}
+	}
+	resp := &Plan{}
+	if err := c.caller.Invoke(ctx, PlanV1Path, "Get", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Show calls lollipop.proto.plan.v1.PlanV1/Show. A nil req sends an empty request.
+func (c *PlanV1Client) Show(ctx context.Context, req *ShowRequest) (*Plan, error) {
+	if req == nil {
+		req = &ShowRequest{}
+	}
+	resp := &Plan{}
+	if err := c.caller.Invoke(ctx, PlanV1Path, "Show", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// AddRecipe calls lollipop.proto.plan.v1.PlanV1/AddRecipe. A nil req sends an empty request.
+func (c *PlanV1Client) AddRecipe(ctx context.Context, req *RecipeRequest) (*Plan, error) {
+	if req == nil {
+		req = &RecipeRequest{}
+	}
+	resp := &Plan{}
+	if err := c.caller.Invoke(ctx, PlanV1Path, "AddRecipe", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// RemoveRecipe calls lollipop.proto.plan.v1.PlanV1/RemoveRecipe. A nil req sends an empty request.
+func (c *PlanV1Client) RemoveRecipe(ctx context.Context, req *RecipeRequest) (*Plan, error) {
+	if req == nil {
+		req = &RecipeRequest{}
+	}
+	resp := &Plan{}
+	if err := c.caller.Invoke(ctx, PlanV1Path, "RemoveRecipe", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,93 @@
+package planv1_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	planv1 "github.com/lollipopai/cli/pkg/cherrypick/plan/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// invoker records the call it is asked to make and answers with resp.
+type invoker struct {
+	resp   string
+	method string
+	req    string
+}
+
+func (i *invoker) Invoke(ctx context.Context, servicePath, method string, in, out any) error {
+	i.method = servicePath + "/" + method
+	req, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	i.req = string(req)
+	return json.Unmarshal([]byte(i.resp), out)
+}
+
+func TestPlanV1Client(t *testing.T) {
+	for _, tt := range []struct {
+		method string
+		call   func(context.Context, *planv1.PlanV1Client) (any, error)
+		req    string
+		resp   string
+		want   any
+	}{
+		{
+			method: "List",
+			call: func(ctx context.Context, c *planv1.PlanV1Client) (any, error) {
+				return c.List(ctx, nil)
+			},
+			req:  `{}`,
+			resp: `{"plans":[{"id":"p1","name":"This week"}]}`,
+			want: &planv1.ListResponse{Plans: []*planv1.Plan{{ID: "p1", Name: "This week"}}},
+		},
+		{
+			method: "Get",
+			call: func(ctx context.Context, c *planv1.PlanV1Client) (any, error) {
+				return c.Get(ctx, &planv1.GetRequest{ID: "p1"})
+			},
+			req:  `{"id":"p1"}`,
+			resp: `{"id":"p1","recipes":[{"recipe_id":"r1","servings":2}]}`,
+			want: &planv1.Plan{ID: "p1", Recipes: []*planv1.PlanRecipe{{RecipeID: "r1", Servings: 2}}},
+		},
+		{
+			method: "Show",
+			call: func(ctx context.Context, c *planv1.PlanV1Client) (any, error) {
+				return c.Show(ctx, nil)
+			},
+			req:  `{}`,
+			resp: `{"id":"p1","starts_on":"2026-10-19"}`,
+			want: &planv1.Plan{ID: "p1", StartsOn: "2026-10-19"},
+		},
+		{
+			method: "AddRecipe",
+			call: func(ctx context.Context, c *planv1.PlanV1Client) (any, error) {
+				return c.AddRecipe(ctx, &planv1.RecipeRequest{PlanID: "p1", RecipeID: "r1"})
+			},
+			req:  `{"plan_id":"p1","recipe_id":"r1"}`,
+			resp: `{"id":"p1"}`,
+			want: &planv1.Plan{ID: "p1"},
+		},
+		{
+			method: "RemoveRecipe",
+			call: func(ctx context.Context, c *planv1.PlanV1Client) (any, error) {
+				return c.RemoveRecipe(ctx, &planv1.RecipeRequest{PlanID: "p1", RecipeID: "r1"})
+			},
+			req:  `{"plan_id":"p1","recipe_id":"r1"}`,
+			resp: `{"id":"p1"}`,
+			want: &planv1.Plan{ID: "p1"},
+		},
+	} {
+		t.Run(tt.method, func(t *testing.T) {
+			inv := &invoker{resp: tt.resp}
+			got, err := tt.call(t.Context(), planv1.NewPlanV1Client(inv))
+			require.NoError(t, err)
+			assert.Equal(t, planv1.PlanV1Path+"/"+tt.method, inv.method)
+			assert.JSONEq(t, tt.req, inv.req)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,70 @@
+// Code generated by pkg/cherrypick/gen.go from lollipop/proto/playlist/v1/playlist.proto. DO NOT EDIT.
+
+// Package playlistv1 is a typed client for the lollipop.proto.playlist.v1 Twirp services.
+package playlistv1
+
+import (
+	"context"
+
+	"github.com/lollipopai/cli/pkg/cherrypick"
+)
+
+// Playlist is the lollipop.proto.playlist.v1.Playlist message.
+type Playlist struct {
+	ID          string   `json:"id,omitempty"`
+	Title       string   `json:"title,omitempty"`
+	Description string   `json:"description,omitempty"`
+	RecipeIDs   []string `json:"recipe_ids,omitempty"`
+}
+
+// ListRequest is the lollipop.proto.playlist.v1.ListRequest message.
+type ListRequest struct {
+}
+
+// ListResponse is the lollipop.proto.playlist.v1.ListResponse message.
+type ListResponse struct {
+	Playlists []*Playlist `json:"playlists,omitempty"`
+}
+
+// GetRequest is the lollipop.proto.playlist.v1.GetRequest message.
+type GetRequest struct {
+	ID string `json:"id,omitempty"`
+}
+
+// PlaylistV1Path is the Twirp service path of PlaylistV1.
+const PlaylistV1Path = "lollipop.proto.playlist.v1.PlaylistV1"
+
+// PlaylistV1Client calls the lollipop.proto.playlist.v1.PlaylistV1 service.
+type PlaylistV1Client struct {
+	caller cherrypick.Invoker
+}
+
+// NewPlaylistV1Client returns a client that makes its calls through caller, usually a
+// *cherrypick.Client.
+func NewPlaylistV1Client(caller cherrypick.Invoker) *PlaylistV1Client {
+	return &PlaylistV1Client{caller: caller}
+}
+
+// List calls lollipop.proto.playlist.v1.PlaylistV1/List. A nil req sends an empty request.
+func (c *PlaylistV1Client) List(ctx context.Context, req *ListRequest) (*ListResponse, error) {
+	if req == nil {
+		req = &ListRequest{}
+	}
+	resp := &ListResponse{}
+	if err := c.caller.Invoke(ctx, PlaylistV1Path, "List", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Get calls lollipop.proto.playlist.v1.PlaylistV1/Get. A nil req sends an empty request.
+func (c *PlaylistV1Client) Get(ctx context.Context, req *GetRequest) (*Playlist, error) {
+	if req == nil {
+		req = &GetRequest{}
+	}
+	resp := &Playlist{}
+	if err := c.caller.Invoke(ctx, PlaylistV1Path, "Get", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
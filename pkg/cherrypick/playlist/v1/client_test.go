@@ -0,0 +1,66 @@
+package playlistv1_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	playlistv1 "github.com/lollipopai/cli/pkg/cherrypick/playlist/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// invoker records the call it is asked to make and answers with resp.
+type invoker struct {
+	resp   string
+	method string
+	req    string
+}
+
+func (i *invoker) Invoke(ctx context.Context, servicePath, method string, in, out any) error {
+	i.method = servicePath + "/" + method
+	req, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	i.req = string(req)
+	return json.Unmarshal([]byte(i.resp), out)
+}
+
+func TestPlaylistV1Client(t *testing.T) {
+	for _, tt := range []struct {
+		method string
+		call   func(context.Context, *playlistv1.PlaylistV1Client) (any, error)
+		req    string
+		resp   string
+		want   any
+	}{
+		{
+			method: "List",
+			call: func(ctx context.Context, c *playlistv1.PlaylistV1Client) (any, error) {
+				return c.List(ctx, nil)
+			},
+			req:  `{}`,
+			resp: `{"playlists":[{"id":"pl1","title":"Quick dinners"}]}`,
+			want: &playlistv1.ListResponse{Playlists: []*playlistv1.Playlist{{ID: "pl1", Title: "Quick dinners"}}},
+		},
+		{
+			method: "Get",
+			call: func(ctx context.Context, c *playlistv1.PlaylistV1Client) (any, error) {
+				return c.Get(ctx, &playlistv1.GetRequest{ID: "pl1"})
+			},
+			req:  `{"id":"pl1"}`,
+			resp: `{"id":"pl1","recipe_ids":["r1","r2"]}`,
+			want: &playlistv1.Playlist{ID: "pl1", RecipeIDs: []string{"r1", "r2"}},
+		},
+	} {
+		t.Run(tt.method, func(t *testing.T) {
+			inv := &invoker{resp: tt.resp}
+			got, err := tt.call(t.Context(), playlistv1.NewPlaylistV1Client(inv))
+			require.NoError(t, err)
+			assert.Equal(t, playlistv1.PlaylistV1Path+"/"+tt.method, inv.method)
+			assert.JSONEq(t, tt.req, inv.req)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
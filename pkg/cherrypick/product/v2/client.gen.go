@@ -0,0 +1,80 @@
+// Code generated by pkg/cherrypick/gen.go from lollipop/proto/product/v2/product.proto. DO NOT EDIT.
+
+// Package productv2 is a typed client for the lollipop.proto.product.v2 Twirp services.
+package productv2
+
+import (
+	"context"
+
+	"github.com/lollipopai/cli/pkg/cherrypick"
+)
+
+// Product is the lollipop.proto.product.v2.Product message.
+type Product struct {
+	ID            string           `json:"id,omitempty"`
+	SainsburysUID string           `json:"sainsburys_uid,omitempty"`
+	Name          string           `json:"name,omitempty"`
+	Brand         string           `json:"brand,omitempty"`
+	PricePence    cherrypick.Int64 `json:"price_pence,omitempty"`
+	UnitPrice     string           `json:"unit_price,omitempty"`
+	Size          string           `json:"size,omitempty"`
+	ImageURL      string           `json:"image_url,omitempty"`
+	Available     bool             `json:"available,omitempty"`
+	Categories    []string         `json:"categories,omitempty"`
+}
+
+// SearchRequest is the lollipop.proto.product.v2.SearchRequest message.
+type SearchRequest struct {
+	Keyword  string `json:"keyword,omitempty"`
+	Page     int32  `json:"page,omitempty"`
+	PageSize int32  `json:"page_size,omitempty"`
+}
+
+// SearchResponse is the lollipop.proto.product.v2.SearchResponse message.
+type SearchResponse struct {
+	Products []*Product `json:"products,omitempty"`
+	Total    int32      `json:"total,omitempty"`
+}
+
+// GetRequest is the lollipop.proto.product.v2.GetRequest message.
+type GetRequest struct {
+	ID string `json:"id,omitempty"`
+}
+
+// ProductV2Path is the Twirp service path of ProductV2.
+const ProductV2Path = "lollipop.proto.product.v2.ProductV2"
+
+// ProductV2Client calls the lollipop.proto.product.v2.ProductV2 service.
+type ProductV2Client struct {
+	caller cherrypick.Invoker
+}
+
+// NewProductV2Client returns a client that makes its calls through caller, usually a
+// *cherrypick.Client.
+func NewProductV2Client(caller cherrypick.Invoker) *ProductV2Client {
+	return &ProductV2Client{caller: caller}
+}
+
+// Search calls lollipop.proto.product.v2.ProductV2/Search. A nil req sends an empty request.
+func (c *ProductV2Client) Search(ctx context.Context, req *SearchRequest) (*SearchResponse, error) {
+	if req == nil {
+		req = &SearchRequest{}
+	}
+	resp := &SearchResponse{}
+	if err := c.caller.Invoke(ctx, ProductV2Path, "Search", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Get calls lollipop.proto.product.v2.ProductV2/Get. A nil req sends an empty request.
+func (c *ProductV2Client) Get(ctx context.Context, req *GetRequest) (*Product, error) {
+	if req == nil {
+		req = &GetRequest{}
+	}
+	resp := &Product{}
+	if err := c.caller.Invoke(ctx, ProductV2Path, "Get", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
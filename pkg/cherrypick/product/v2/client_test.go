@@ -0,0 +1,66 @@
+package productv2_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	productv2 "github.com/lollipopai/cli/pkg/cherrypick/product/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// invoker records the call it is asked to make and answers with resp.
+type invoker struct {
+	resp   string
+	method string
+	req    string
+}
+
+func (i *invoker) Invoke(ctx context.Context, servicePath, method string, in, out any) error {
+	i.method = servicePath + "/" + method
+	req, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	i.req = string(req)
+	return json.Unmarshal([]byte(i.resp), out)
+}
+
+func TestProductV2Client(t *testing.T) {
+	for _, tt := range []struct {
+		method string
+		call   func(context.Context, *productv2.ProductV2Client) (any, error)
+		req    string
+		resp   string
+		want   any
+	}{
+		{
+			method: "Search",
+			call: func(ctx context.Context, c *productv2.ProductV2Client) (any, error) {
+				return c.Search(ctx, &productv2.SearchRequest{Keyword: "eggs", PageSize: 10})
+			},
+			req:  `{"keyword":"eggs","page_size":10}`,
+			resp: `{"products":[{"sainsburys_uid":"7834128","name":"Eggs","price_pence":"350","available":true}],"total":1}`,
+			want: &productv2.SearchResponse{Products: []*productv2.Product{{SainsburysUID: "7834128", Name: "Eggs", PricePence: 350, Available: true}}, Total: 1},
+		},
+		{
+			method: "Get",
+			call: func(ctx context.Context, c *productv2.ProductV2Client) (any, error) {
+				return c.Get(ctx, &productv2.GetRequest{ID: "7834128"})
+			},
+			req:  `{"id":"7834128"}`,
+			resp: `{"id":"p1","sainsburys_uid":"7834128","categories":["dairy"]}`,
+			want: &productv2.Product{ID: "p1", SainsburysUID: "7834128", Categories: []string{"dairy"}},
+		},
+	} {
+		t.Run(tt.method, func(t *testing.T) {
+			inv := &invoker{resp: tt.resp}
+			got, err := tt.call(t.Context(), productv2.NewProductV2Client(inv))
+			require.NoError(t, err)
+			assert.Equal(t, productv2.ProductV2Path+"/"+tt.method, inv.method)
+			assert.JSONEq(t, tt.req, inv.req)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,88 @@
+// Code generated by pkg/cherrypick/gen.go from lollipop/proto/recipe/v1/recipe.proto. DO NOT EDIT.
+
+// Package recipev1 is a typed client for the lollipop.proto.recipe.v1 Twirp services.
+package recipev1
+
+import (
+	"context"
+
+	"github.com/lollipopai/cli/pkg/cherrypick"
+)
+
+// Ingredient is the lollipop.proto.recipe.v1.Ingredient message.
+type Ingredient struct {
+	Name       string `json:"name,omitempty"`
+	Quantity   string `json:"quantity,omitempty"`
+	ProductUID string `json:"product_uid,omitempty"`
+}
+
+// Recipe is the lollipop.proto.recipe.v1.Recipe message.
+type Recipe struct {
+	ID               string        `json:"id,omitempty"`
+	Slug             string        `json:"slug,omitempty"`
+	Title            string        `json:"title,omitempty"`
+	Description      string        `json:"description,omitempty"`
+	Servings         int32         `json:"servings,omitempty"`
+	TotalTimeMinutes int32         `json:"total_time_minutes,omitempty"`
+	ImageURL         string        `json:"image_url,omitempty"`
+	Tags             []string      `json:"tags,omitempty"`
+	Ingredients      []*Ingredient `json:"ingredients,omitempty"`
+	Steps            []string      `json:"steps,omitempty"`
+}
+
+// SearchRequest is the lollipop.proto.recipe.v1.SearchRequest message.
+type SearchRequest struct {
+	Query    string `json:"query,omitempty"`
+	Page     int32  `json:"page,omitempty"`
+	PageSize int32  `json:"page_size,omitempty"`
+}
+
+// SearchResponse is the lollipop.proto.recipe.v1.SearchResponse message.
+type SearchResponse struct {
+	Recipes []*Recipe `json:"recipes,omitempty"`
+	Total   int32     `json:"total,omitempty"`
+}
+
+// GetBySlugRequest is the lollipop.proto.recipe.v1.GetBySlugRequest message.
+type GetBySlugRequest struct {
+	Slug string `json:"slug,omitempty"`
+	ID   string `json:"id,omitempty"`
+}
+
+// RecipeV1Path is the Twirp service path of RecipeV1.
+const RecipeV1Path = "lollipop.proto.recipe.v1.RecipeV1"
+
+// RecipeV1Client calls the lollipop.proto.recipe.v1.RecipeV1 service.
+type RecipeV1Client struct {
+	caller cherrypick.Invoker
+}
+
+// NewRecipeV1Client returns a client that makes its calls through caller, usually a
+// *cherrypick.Client.
+func NewRecipeV1Client(caller cherrypick.Invoker) *RecipeV1Client {
+	return &RecipeV1Client{caller: caller}
+}
+
+// Search calls lollipop.proto.recipe.v1.RecipeV1/Search. A nil req sends an empty request.
+func (c *RecipeV1Client) Search(ctx context.Context, req *SearchRequest) (*SearchResponse, error) {
+	if req == nil {
+		req = &SearchRequest{}
+	}
+	resp := &SearchResponse{}
+	if err := c.caller.Invoke(ctx, RecipeV1Path, "Search", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetBySlug calls lollipop.proto.recipe.v1.RecipeV1/GetBySlug. A nil req sends an empty request.
+func (c *RecipeV1Client) GetBySlug(ctx context.Context, req *GetBySlugRequest) (*Recipe, error) {
+	if req == nil {
+		req = &GetBySlugRequest{}
+	}
+	resp := &Recipe{}
+	if err := c.caller.Invoke(ctx, RecipeV1Path, "GetBySlug", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
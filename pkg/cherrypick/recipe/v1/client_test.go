@@ -0,0 +1,66 @@
+package recipev1_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	recipev1 "github.com/lollipopai/cli/pkg/cherrypick/recipe/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// invoker records the call it is asked to make and answers with resp.
+type invoker struct {
+	resp   string
+	method string
+	req    string
+}
+
+func (i *invoker) Invoke(ctx context.Context, servicePath, method string, in, out any) error {
+	i.method = servicePath + "/" + method
+	req, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	i.req = string(req)
+	return json.Unmarshal([]byte(i.resp), out)
+}
+
+func TestRecipeV1Client(t *testing.T) {
+	for _, tt := range []struct {
+		method string
+		call   func(context.Context, *recipev1.RecipeV1Client) (any, error)
+		req    string
+		resp   string
+		want   any
+	}{
+		{
+			method: "Search",
+			call: func(ctx context.Context, c *recipev1.RecipeV1Client) (any, error) {
+				return c.Search(ctx, &recipev1.SearchRequest{Query: "curry"})
+			},
+			req:  `{"query":"curry"}`,
+			resp: `{"recipes":[{"id":"r1","slug":"chicken-tikka","servings":4}],"total":1}`,
+			want: &recipev1.SearchResponse{Recipes: []*recipev1.Recipe{{ID: "r1", Slug: "chicken-tikka", Servings: 4}}, Total: 1},
+		},
+		{
+			method: "GetBySlug",
+			call: func(ctx context.Context, c *recipev1.RecipeV1Client) (any, error) {
+				return c.GetBySlug(ctx, &recipev1.GetBySlugRequest{Slug: "chicken-tikka"})
+			},
+			req:  `{"slug":"chicken-tikka"}`,
+			resp: `{"id":"r1","ingredients":[{"name":"Chicken","product_uid":"7834128"}],"steps":["Cook"]}`,
+			want: &recipev1.Recipe{ID: "r1", Ingredients: []*recipev1.Ingredient{{Name: "Chicken", ProductUID: "7834128"}}, Steps: []string{"Cook"}},
+		},
+	} {
+		t.Run(tt.method, func(t *testing.T) {
+			inv := &invoker{resp: tt.resp}
+			got, err := tt.call(t.Context(), recipev1.NewRecipeV1Client(inv))
+			require.NoError(t, err)
+			assert.Equal(t, recipev1.RecipeV1Path+"/"+tt.method, inv.method)
+			assert.JSONEq(t, tt.req, inv.req)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
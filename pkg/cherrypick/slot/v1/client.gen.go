@@ -0,0 +1,84 @@
+// Code generated by pkg/cherrypick/gen.go from lollipop/proto/slot/v1/slot.proto. DO NOT EDIT.
+
+// Package slotv1 is a typed client for the lollipop.proto.slot.v1 Twirp services.
+package slotv1
+
+import (
+	"context"
+
+	"github.com/lollipopai/cli/pkg/cherrypick"
+)
+
+// Slot is the lollipop.proto.slot.v1.Slot message.
+type Slot struct {
+	ID         string           `json:"id,omitempty"`
+	StartsAt   string           `json:"starts_at,omitempty"`
+	EndsAt     string           `json:"ends_at,omitempty"`
+	PricePence cherrypick.Int64 `json:"price_pence,omitempty"`
+	Available  bool             `json:"available,omitempty"`
+	Booked     bool             `json:"booked,omitempty"`
+}
+
+// ListRequest is the lollipop.proto.slot.v1.ListRequest message.
+type ListRequest struct {
+}
+
+// ListResponse is the lollipop.proto.slot.v1.ListResponse message.
+type ListResponse struct {
+	Slots []*Slot `json:"slots,omitempty"`
+}
+
+// SlotRequest is the lollipop.proto.slot.v1.SlotRequest message.
+type SlotRequest struct {
+	ID string `json:"id,omitempty"`
+}
+
+// SlotV1Path is the Twirp service path of SlotV1.
+const SlotV1Path = "lollipop.proto.slot.v1.SlotV1"
+
+// SlotV1Client calls the lollipop.proto.slot.v1.SlotV1 service.
+type SlotV1Client struct {
+	caller cherrypick.Invoker
+}
+
+// NewSlotV1Client returns a client that makes its calls through caller, usually a
+// *cherrypick.Client.
+func NewSlotV1Client(caller cherrypick.Invoker) *SlotV1Client {
+	return &SlotV1Client{caller: caller}
+}
+
+// List calls lollipop.proto.slot.v1.SlotV1/List. A nil req sends an empty request.
+func (c *SlotV1Client) List(ctx context.Context, req *ListRequest) (*ListResponse, error) {
+	if req == nil {
+		req = &ListRequest{}
+	}
+	resp := &ListResponse{}
+	if err := c.caller.Invoke(ctx, SlotV1Path, "List", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Get calls lollipop.proto.slot.v1.SlotV1/Get. A nil req sends an empty request.
+func (c *SlotV1Client) Get(ctx context.Context, req *SlotRequest) (*Slot, error) {
+	if req == nil {
+		req = &SlotRequest{}
+	}
+	resp := &Slot{}
+	if err := c.caller.Invoke(ctx, SlotV1Path, "Get", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Book calls lollipop.proto.slot.v1.SlotV1/Book. A nil req sends an empty request.
+func (c *SlotV1Client) Book(ctx context.Context, req *SlotRequest) (*Slot, error) {
+	if req == nil {
+		req = &SlotRequest{}
+	}
+	resp := &Slot{}
+	if err := c.caller.Invoke(ctx, SlotV1Path, "Book", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
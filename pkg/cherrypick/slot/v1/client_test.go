@@ -0,0 +1,75 @@
+package slotv1_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	slotv1 "github.com/lollipopai/cli/pkg/cherrypick/slot/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// invoker records the call it is asked to make and answers with resp.
+type invoker struct {
+	resp   string
+	method string
+	req    string
+}
+
+func (i *invoker) Invoke(ctx context.Context, servicePath, method string, in, out any) error {
+	i.method = servicePath + "/" + method
+	req, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	i.req = string(req)
+	return json.Unmarshal([]byte(i.resp), out)
+}
+
+func TestSlotV1Client(t *testing.T) {
+	for _, tt := range []struct {
+		method string
+		call   func(context.Context, *slotv1.SlotV1Client) (any, error)
+		req    string
+		resp   string
+		want   any
+	}{
+		{
+			method: "List",
+			call: func(ctx context.Context, c *slotv1.SlotV1Client) (any, error) {
+				return c.List(ctx, nil)
+			},
+			req:  `{}`,
+			resp: `{"slots":[{"id":"5","price_pence":"450","available":true}]}`,
+			want: &slotv1.ListResponse{Slots: []*slotv1.Slot{{ID: "5", PricePence: 450, Available: true}}},
+		},
+		{
+			method: "Get",
+			call: func(ctx context.Context, c *slotv1.SlotV1Client) (any, error) {
+				return c.Get(ctx, &slotv1.SlotRequest{ID: "5"})
+			},
+			req:  `{"id":"5"}`,
+			resp: `{"id":"5","starts_at":"2026-10-19T18:00:00Z"}`,
+			want: &slotv1.Slot{ID: "5", StartsAt: "2026-10-19T18:00:00Z"},
+		},
+		{
+			method: "Book",
+			call: func(ctx context.Context, c *slotv1.SlotV1Client) (any, error) {
+				return c.Book(ctx, &slotv1.SlotRequest{ID: "5"})
+			},
+			req:  `{"id":"5"}`,
+			resp: `{"id":"5","booked":true}`,
+			want: &slotv1.Slot{ID: "5", Booked: true},
+		},
+	} {
+		t.Run(tt.method, func(t *testing.T) {
+			inv := &invoker{resp: tt.resp}
+			got, err := tt.call(t.Context(), slotv1.NewSlotV1Client(inv))
+			require.NoError(t, err)
+			assert.Equal(t, slotv1.SlotV1Path+"/"+tt.method, inv.method)
+			assert.JSONEq(t, tt.req, inv.req)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
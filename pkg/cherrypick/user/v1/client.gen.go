@@ -0,0 +1,47 @@
+// Code generated by pkg/cherrypick/gen.go from lollipop/proto/user/v1/user.proto. DO NOT EDIT.
+
+// Package userv1 is a typed client for the lollipop.proto.user.v1 Twirp services.
+package userv1
+
+import (
+	"context"
+
+	"github.com/lollipopai/cli/pkg/cherrypick"
+)
+
+// User is the lollipop.proto.user.v1.User message.
+type User struct {
+	ID    string `json:"id,omitempty"`
+	Email string `json:"email,omitempty"`
+	Name  string `json:"name,omitempty"`
+}
+
+// CurrentRequest is the lollipop.proto.user.v1.CurrentRequest message.
+type CurrentRequest struct {
+}
+
+// UserV1Path is the Twirp service path of UserV1.
+const UserV1Path = "lollipop.proto.user.v1.UserV1"
+
+// UserV1Client calls the lollipop.proto.user.v1.UserV1 service.
+type UserV1Client struct {
+	caller cherrypick.Invoker
+}
+
+// NewUserV1Client returns a client that makes its calls through caller, usually a
+// *cherrypick.Client.
+func NewUserV1Client(caller cherrypick.Invoker) *UserV1Client {
+	return &UserV1Client{caller: caller}
+}
+
+// Current calls lollipop.proto.user.v1.UserV1/Current. A nil req sends an empty request.
+func (c *UserV1Client) Current(ctx context.Context, req *CurrentRequest) (*User, error) {
+	if req == nil {
+		req = &CurrentRequest{}
+	}
+	resp := &User{}
+	if err := c.caller.Invoke(ctx, UserV1Path, "Current", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,57 @@
+package userv1_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	userv1 "github.com/lollipopai/cli/pkg/cherrypick/user/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// invoker records the call it is asked to make and answers with resp.
+type invoker struct {
+	resp   string
+	method string
+	req    string
+}
+
+func (i *invoker) Invoke(ctx context.Context, servicePath, method string, in, out any) error {
+	i.method = servicePath + "/" + method
+	req, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	i.req = string(req)
+	return json.Unmarshal([]byte(i.resp), out)
+}
+
+func TestUserV1Client(t *testing.T) {
+	for _, tt := range []struct {
+		method string
+		call   func(context.Context, *userv1.UserV1Client) (any, error)
+		req    string
+		resp   string
+		want   any
+	}{
+		{
+			method: "Current",
+			call: func(ctx context.Context, c *userv1.UserV1Client) (any, error) {
+				return c.Current(ctx, nil)
+			},
+			req:  `{}`,
+			resp: `{"id":"u1","email":"cook@example.com","name":"Cook"}`,
+			want: &userv1.User{ID: "u1", Email: "cook@example.com", Name: "Cook"},
+		},
+	} {
+		t.Run(tt.method, func(t *testing.T) {
+			inv := &invoker{resp: tt.resp}
+			got, err := tt.call(t.Context(), userv1.NewUserV1Client(inv))
+			require.NoError(t, err)
+			assert.Equal(t, userv1.UserV1Path+"/"+tt.method, inv.method)
+			assert.JSONEq(t, tt.req, inv.req)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}